@@ -0,0 +1,177 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/feedback"
+	"github.com/luzhnov-aleksei/kinobot/limiter"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+)
+
+// Раздел хранилища с заблокированными пользователями, ключ - ID пользователя
+const bansBucket = "bans"
+
+// Префикс callback-данных кнопок администратора
+const CallbackPrefix = "ad:"
+
+// Telegram ID администраторов бота
+var admins = make(map[int64]bool)
+
+// Блокировка пользователя
+type Ban struct {
+	UserID int64     `json:"userId"`
+	Reason string    `json:"reason,omitempty"`
+	By     int64     `json:"by"`
+	At     time.Time `json:"at"`
+}
+
+// Разбор списка ID администраторов через запятую (переменная окружения ADMIN_IDS)
+func ParseIDs(value string) ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка в ID администратора %q: %v", part, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Назначение администраторов
+func SetAdmins(ids []int64) {
+	admins = make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		admins[id] = true
+	}
+}
+
+// Является ли пользователь администратором бота
+func IsAdmin(userID int64) bool {
+	return admins[userID]
+}
+
+// Заблокирован ли пользователь
+func IsBanned(userID int64) bool {
+	var ban Ban
+	return storage.Get(bansBucket, strconv.FormatInt(userID, 10), &ban)
+}
+
+// Обработка команд администратора в личке. Возвращает false, если это не команда
+// администратора и сообщение нужно обработать как обычно.
+func HandleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) bool {
+	if !IsAdmin(message.From.ID) {
+		return false
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	switch message.Command() {
+	case "botstats":
+		sendMessage(bot, message.Chat.ID, FormatStats(ComputeStats(time.Now())))
+	case "broadcast":
+		handleBroadcastCommand(bot, message)
+	case "ban":
+		handleBanCommand(bot, message, args)
+	case "unban":
+		handleUnbanCommand(bot, message, args)
+	case "quota":
+		handleQuotaCommand(bot, message, args)
+	case "tickets":
+		sendMessage(bot, message.Chat.ID, feedback.FormatOpenTickets())
+	case "cache":
+		sendMessage(bot, message.Chat.ID, fmt.Sprintf("🧹 Кэш очищен, удалено фильмов: %d.", movies.ClearCache()))
+	default:
+		return false
+	}
+	return true
+}
+
+// /ban <id> [причина]
+func handleBanCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, args []string) {
+	chatID := message.Chat.ID
+	userID, ok := parseUserID(args)
+	if !ok {
+		sendMessage(bot, chatID, "Использование: /ban <ID пользователя> [причина]")
+		return
+	}
+	if IsAdmin(userID) {
+		sendMessage(bot, chatID, "Нельзя заблокировать администратора.")
+		return
+	}
+
+	ban := Ban{UserID: userID, Reason: strings.Join(args[1:], " "), By: message.From.ID, At: time.Now()}
+	if err := storage.Put(bansBucket, strconv.FormatInt(userID, 10), ban); err != nil {
+		log.Println("Ошибка при сохранении блокировки:", err)
+		sendMessage(bot, chatID, "Не удалось заблокировать пользователя.")
+		return
+	}
+	log.Printf("Администратор [%d] заблокировал пользователя [%d]: %s", message.From.ID, userID, ban.Reason)
+	sendMessage(bot, chatID, fmt.Sprintf("🚫 Пользователь %d заблокирован.", userID))
+}
+
+// /unban <id>
+func handleUnbanCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, args []string) {
+	chatID := message.Chat.ID
+	userID, ok := parseUserID(args)
+	if !ok {
+		sendMessage(bot, chatID, "Использование: /unban <ID пользователя>")
+		return
+	}
+	if !IsBanned(userID) {
+		sendMessage(bot, chatID, fmt.Sprintf("Пользователь %d не заблокирован.", userID))
+		return
+	}
+	if err := storage.Delete(bansBucket, strconv.FormatInt(userID, 10)); err != nil {
+		log.Println("Ошибка при снятии блокировки:", err)
+	}
+	sendMessage(bot, chatID, fmt.Sprintf("✅ Пользователь %d разблокирован.", userID))
+}
+
+// /quota <id> [reset | лимит]
+func handleQuotaCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, args []string) {
+	chatID := message.Chat.ID
+	userID, ok := parseUserID(args)
+	if !ok || len(args) > 2 {
+		sendMessage(bot, chatID, "Использование: /quota <ID> - текущий лимит, /quota <ID> reset - сбросить счётчик, /quota <ID> <лимит> - изменить лимит в день")
+		return
+	}
+
+	if len(args) == 2 {
+		if args[1] == "reset" {
+			limiter.Reset(userID)
+		} else {
+			limit, err := strconv.Atoi(args[1])
+			if err != nil || limit < 1 || limit > 1000 {
+				sendMessage(bot, chatID, "Лимит должен быть числом от 1 до 1000.")
+				return
+			}
+			limiter.SetLimit(userID, limit)
+		}
+	}
+	count, limit := limiter.Usage(userID)
+	sendMessage(bot, chatID, fmt.Sprintf("Пользователь %d: использовано %d из %d сообщений за сутки.", userID, count, limit))
+}
+
+func parseUserID(args []string) (int64, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	return userID, err == nil
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}
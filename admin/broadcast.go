@@ -0,0 +1,90 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+)
+
+// Рассылки, ожидающие подтверждения, ключ - ID администратора
+var (
+	broadcastMu       sync.Mutex
+	pendingBroadcasts = make(map[int64]string)
+)
+
+// /broadcast <текст> - предпросмотр рассылки с кнопками подтверждения
+func handleBroadcastCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	text := strings.TrimSpace(message.CommandArguments())
+	if text == "" {
+		sendMessage(bot, chatID, "Использование: /broadcast <текст объявления>")
+		return
+	}
+
+	broadcastMu.Lock()
+	pendingBroadcasts[message.From.ID] = text
+	broadcastMu.Unlock()
+
+	preview := tgbotapi.NewMessage(chatID, fmt.Sprintf("📣 Предпросмотр рассылки (получателей: %d):\n\n%s", len(recipients()), text))
+	preview.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ Отправить", CallbackPrefix+"broadcast:send"),
+		tgbotapi.NewInlineKeyboardButtonData("❌ Отменить", CallbackPrefix+"broadcast:cancel"),
+	))
+	sender.Post(bot, preview)
+}
+
+// Обработка кнопок администратора
+func HandleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	if !IsAdmin(callback.From.ID) {
+		return
+	}
+	action := strings.TrimPrefix(callback.Data, CallbackPrefix+"broadcast:")
+
+	broadcastMu.Lock()
+	text, ok := pendingBroadcasts[callback.From.ID]
+	delete(pendingBroadcasts, callback.From.ID)
+	broadcastMu.Unlock()
+
+	status := "Рассылка отменена."
+	if !ok {
+		status = "Эта рассылка уже отправлена или отменена."
+	} else if action == "send" {
+		status = "📣 Рассылка запущена."
+	}
+	edit := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, callback.Message.Text+"\n\n"+status)
+	sender.Post(bot, edit)
+
+	if ok && action == "send" {
+		// Рассылка идёт в фоне, темп задаёт очередь отправки
+		go broadcast(bot, callback.Message.Chat.ID, text)
+	}
+}
+
+// Пользователи, которым можно отправить рассылку: писали в личку и не заблокированы
+func recipients() []int64 {
+	var ids []int64
+	for _, user := range Users() {
+		if user.Private && !IsBanned(user.ID) {
+			ids = append(ids, user.ID)
+		}
+	}
+	return ids
+}
+
+func broadcast(bot *tgbotapi.BotAPI, adminChatID int64, text string) {
+	ids := recipients()
+	delivered := 0
+	for _, id := range ids {
+		if _, err := sender.Send(bot, tgbotapi.NewMessage(id, text)); err != nil {
+			log.Printf("Ошибка при отправке рассылки пользователю [%d]: %v", id, err)
+			continue
+		}
+		delivered++
+	}
+	log.Printf("Рассылка завершена: доставлено %d из %d", delivered, len(ids))
+	sendMessage(bot, adminChatID, fmt.Sprintf("📣 Рассылка завершена: доставлено %d из %d.", delivered, len(ids)))
+}
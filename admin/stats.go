@@ -0,0 +1,190 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/media"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+)
+
+const (
+	// Пользователи бота, ключ - ID пользователя
+	usersBucket = "users"
+	// Счётчики по дням, ключ - дата
+	dailyBucket = "daily_stats"
+)
+
+// Бесплатный лимит запросов к Kinopoisk API в день
+const apiDailyLimit = 200
+
+// Пользователь, который писал боту
+type User struct {
+	ID        int64  `json:"id"`
+	FirstName string `json:"firstName"`
+	UserName  string `json:"userName,omitempty"`
+	// Писал ли пользователь боту в личку: только таким можно отправить рассылку
+	Private   bool      `json:"private"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// Счётчики за день
+type Daily struct {
+	Searches int `json:"searches"`
+}
+
+var statsMu sync.Mutex
+
+// Учёт активности пользователя. Запись обновляется не чаще раза в день,
+// чтобы не переписывать хранилище на каждое сообщение.
+func RecordActivity(from *tgbotapi.User, chat *tgbotapi.Chat) {
+	if from == nil || from.IsBot {
+		return
+	}
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	now := time.Now()
+	key := strconv.FormatInt(from.ID, 10)
+	var user User
+	exists := storage.Get(usersBucket, key, &user)
+	private := chat != nil && chat.IsPrivate()
+	if exists && sameDay(user.LastSeen, now) && (user.Private || !private) &&
+		user.FirstName == from.FirstName && user.UserName == from.UserName {
+		return
+	}
+
+	if !exists {
+		user = User{ID: from.ID, FirstSeen: now}
+	}
+	user.FirstName = from.FirstName
+	user.UserName = from.UserName
+	user.Private = user.Private || private
+	user.LastSeen = now
+	if err := storage.Put(usersBucket, key, user); err != nil {
+		log.Println("Ошибка при сохранении пользователя:", err)
+	}
+}
+
+// Учёт поискового запроса. Счётчик пишется на диск отложенно, чтобы поиск не переписывал хранилище.
+func RecordSearch() {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+
+	key := dayKey(time.Now())
+	var daily Daily
+	storage.Get(dailyBucket, key, &daily)
+	daily.Searches++
+	if err := storage.PutLazy(dailyBucket, key, daily); err != nil {
+		log.Println("Ошибка при сохранении статистики поиска:", err)
+	}
+}
+
+// Запись о пользователе, если он писал боту
+func LoadUser(userID int64) (*User, bool) {
+	user := &User{}
+	ok := storage.Get(usersBucket, strconv.FormatInt(userID, 10), user)
+	return user, ok
+}
+
+// Удаление записи о пользователе. Блокировка, если она есть, сохраняется.
+func Forget(userID int64) error {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	return storage.Delete(usersBucket, strconv.FormatInt(userID, 10))
+}
+
+// Все известные пользователи
+func Users() []User {
+	var users []User
+	for _, key := range storage.Keys(usersBucket) {
+		var user User
+		if storage.Get(usersBucket, key, &user) {
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+// Статистика бота для администратора
+type Stats struct {
+	Users         int
+	PrivateUsers  int
+	ActiveToday   int
+	ActiveWeek    int
+	SearchesToday int
+	SearchesWeek  int
+	APIRequests   int
+	Banned        int
+	DeadLetters   int
+	CachedMovies  int
+	CachedPosters int
+}
+
+// Подсчёт статистики на момент now
+func ComputeStats(now time.Time) Stats {
+	stats := Stats{
+		APIRequests:  api.RequestsToday(),
+		Banned:       len(storage.Keys(bansBucket)),
+		DeadLetters:  len(sender.DeadLetters()),
+		CachedMovies: movies.CacheSize(),
+		// Изображения, уже загруженные в Telegram и отправляемые по file_id
+		CachedPosters: media.CacheSize(),
+	}
+
+	weekAgo := now.AddDate(0, 0, -7)
+	for _, user := range Users() {
+		stats.Users++
+		if user.Private {
+			stats.PrivateUsers++
+		}
+		if sameDay(user.LastSeen, now) {
+			stats.ActiveToday++
+		}
+		if user.LastSeen.After(weekAgo) {
+			stats.ActiveWeek++
+		}
+	}
+
+	for days := 0; days < 7; days++ {
+		var daily Daily
+		storage.Get(dailyBucket, dayKey(now.AddDate(0, 0, -days)), &daily)
+		if days == 0 {
+			stats.SearchesToday = daily.Searches
+		}
+		stats.SearchesWeek += daily.Searches
+	}
+	return stats
+}
+
+// Текст статистики для /stats
+func FormatStats(stats Stats) string {
+	var sb strings.Builder
+	sb.WriteString("📈 Статистика бота\n\n")
+	sb.WriteString(fmt.Sprintf("Пользователей: %d (писали в личку: %d)\n", stats.Users, stats.PrivateUsers))
+	sb.WriteString(fmt.Sprintf("Активны сегодня: %d, за 7 дней: %d\n", stats.ActiveToday, stats.ActiveWeek))
+	sb.WriteString(fmt.Sprintf("Поисков сегодня: %d, за 7 дней: %d\n", stats.SearchesToday, stats.SearchesWeek))
+	sb.WriteString(fmt.Sprintf("Запросов к API сегодня: %d из %d\n", stats.APIRequests, apiDailyLimit))
+	sb.WriteString(fmt.Sprintf("Заблокировано: %d\n", stats.Banned))
+	sb.WriteString(fmt.Sprintf("Недоставленных сообщений: %d\n", stats.DeadLetters))
+	sb.WriteString(fmt.Sprintf("Фильмов в кэше: %d, изображений в Telegram: %d\n\n", stats.CachedMovies, stats.CachedPosters))
+	sb.WriteString("/stats - ваша статистика просмотров")
+	return sb.String()
+}
+
+func dayKey(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func sameDay(a, b time.Time) bool {
+	return dayKey(a) == dayKey(b)
+}
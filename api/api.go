@@ -9,13 +9,18 @@ import (
 	"net/url"
 	"os"
 	"sort"
+	"strconv"
+	"sync"
+	"time"
 )
 
 type Cinema struct {
-	ID               uint32 `json:"id"`
-	Name             string `json:"name"`
-	Year             uint16 `json:"year"`
-	TypeNumber       int    `json:"typeNumber"`
+	ID         uint32 `json:"id"`
+	Name       string `json:"name"`
+	Year       uint16 `json:"year"`
+	TypeNumber int    `json:"typeNumber"`
+	// Тип строкой: movie, tv-series, tv-show и т.д., см. MovieType
+	Type             string `json:"type,omitempty"`
 	AgeRating        uint16 `json:"ageRating"`
 	Description      string `json:"description"`
 	ShortDescription string `json:"shortDescription"`
@@ -36,6 +41,106 @@ type Cinema struct {
 		Imdb float32 `json:"imdb"`
 		Kp   float32 `json:"kp"`
 	} `json:"rating"`
+	// Число голосов за рейтинги
+	Votes struct {
+		Imdb int `json:"imdb"`
+		Kp   int `json:"kp"`
+	} `json:"votes"`
+	// Похожие фильмы, есть только в подробной информации
+	SimilarMovies []LinkedMovie `json:"similarMovies,omitempty"`
+	// Оригинальное название (для зарубежных фильмов)
+	AlternativeName string `json:"alternativeName,omitempty"`
+	// Статус производства: announced, filming, completed и т.д.
+	Status   string `json:"status,omitempty"`
+	IsSeries bool   `json:"isSeries,omitempty"`
+	// Длительность серии в минутах
+	SeriesLength uint16 `json:"seriesLength,omitempty"`
+	// Годы выхода сериала, у идущего сериала End пустой
+	ReleaseYears []struct {
+		Start uint16 `json:"start"`
+		End   uint16 `json:"end"`
+	} `json:"releaseYears,omitempty"`
+	Premiere *struct {
+		World   string `json:"world,omitempty"`
+		Russia  string `json:"russia,omitempty"`
+		Digital string `json:"digital,omitempty"`
+	} `json:"premiere,omitempty"`
+	SeasonsInfo []struct {
+		Number        int `json:"number"`
+		EpisodesCount int `json:"episodesCount"`
+	} `json:"seasonsInfo,omitempty"`
+	// Где посмотреть онлайн, есть только в подробной информации
+	Watchability Watchability `json:"watchability,omitempty"`
+	// Съёмочная группа и актёры, есть только в подробной информации
+	Persons []MoviePerson `json:"persons,omitempty"`
+	// Идентификаторы фильма в других базах
+	ExternalID struct {
+		Imdb string `json:"imdb,omitempty"`
+	} `json:"externalId,omitempty"`
+}
+
+// Участник фильма: актёр (Description - роль), режиссёр и т.д.
+type MoviePerson struct {
+	ID           int    `json:"id"`
+	Photo        string `json:"photo,omitempty"`
+	Name         string `json:"name"`
+	EnName       string `json:"enName,omitempty"`
+	Description  string `json:"description,omitempty"`
+	Profession   string `json:"profession,omitempty"`
+	EnProfession string `json:"enProfession,omitempty"`
+}
+
+// Онлайн-кинотеатры, в которых доступен фильм
+type Watchability struct {
+	Items []WatchItem `json:"items"`
+}
+
+// Онлайн-кинотеатр и ссылка на фильм в нём
+type WatchItem struct {
+	Name string `json:"name"`
+	Logo *struct {
+		URL string `json:"url,omitempty"`
+	} `json:"logo,omitempty"`
+	URL string `json:"url"`
+}
+
+// Названия онлайн-кинотеатров
+func (w Watchability) Names() []string {
+	names := make([]string, 0, len(w.Items))
+	for _, item := range w.Items {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+// Краткая информация о связанном фильме (похожие, сиквелы)
+type LinkedMovie struct {
+	ID     uint32 `json:"id"`
+	Name   string `json:"name"`
+	Year   uint16 `json:"year"`
+	Type   string `json:"type"`
+	Poster *struct {
+		URL string `json:"url,omitempty"`
+	} `json:"poster,omitempty"`
+	Rating struct {
+		Imdb float32 `json:"imdb"`
+		Kp   float32 `json:"kp"`
+	} `json:"rating"`
+}
+
+// Преобразование связанного фильма в Cinema с теми полями, что известны
+func (m LinkedMovie) Cinema() Cinema {
+	movie := Cinema{
+		ID:         m.ID,
+		Name:       m.Name,
+		Year:       m.Year,
+		TypeNumber: int(ParseType(m.Type)),
+		Type:       m.Type,
+		Poster:     m.Poster,
+	}
+	movie.Rating.Kp = m.Rating.Kp
+	movie.Rating.Imdb = m.Rating.Imdb
+	return movie
 }
 
 // Сортировка по рейтингу KП
@@ -45,25 +150,254 @@ func (a ByKpRating) Len() int           { return len(a) }
 func (a ByKpRating) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ByKpRating) Less(i, j int) bool { return a[i].Rating.Kp > a[j].Rating.Kp }
 
+// Базовый URL Kinopoisk API, в тестах подменяется адресом локального сервера
+var BaseURL = "https://api.kinopoisk.dev/v1.4"
+
+// HTTP-клиент для запросов к API, в тестах подменяется на фикстуры
+var Client = http.DefaultClient
+
 // Запрос списка фильмов по названию
 func RequestMovies(apiURL string, query string) ([]Cinema, error) {
+	escapedName := url.QueryEscape(query)
+	fullURL := fmt.Sprintf("%s?page=1&limit=8&query=%s", apiURL, escapedName)
+
+	var results struct {
+		Movies []Cinema `json:"docs"`
+	}
+	if err := doRequest(fullURL, &results); err != nil {
+		return nil, err
+	}
+
+	// Сортировка фильмов по рейтингу KП по убыванию
+	sort.Sort(ByKpRating(results.Movies))
+
+	return results.Movies, nil
+}
+
+// Запрос подробной информации о фильме по ID
+func RequestMovie(apiURL string, id uint32) (*Cinema, error) {
+	fullURL := fmt.Sprintf("%s/%d", apiURL, id)
+
+	var movie Cinema
+	if err := doRequest(fullURL, &movie); err != nil {
+		return nil, err
+	}
+
+	return &movie, nil
+}
+
+// Запрос фильмов по фильтрам (жанр, рейтинг, год и т.д.) в формате параметров API
+func FilterMovies(apiURL string, params url.Values) ([]Cinema, error) {
+	fullURL := fmt.Sprintf("%s?%s", apiURL, params.Encode())
+
+	var results struct {
+		Movies []Cinema `json:"docs"`
+	}
+	if err := doRequest(fullURL, &results); err != nil {
+		return nil, err
+	}
+
+	return results.Movies, nil
+}
+
+// Запрос нескольких фильмов по ID одним запросом
+func RequestMoviesByIDs(apiURL string, ids []uint32) ([]Cinema, error) {
+	params := url.Values{}
+	params.Set("page", "1")
+	params.Set("limit", strconv.Itoa(len(ids)))
+	for _, id := range ids {
+		params.Add("id", strconv.FormatUint(uint64(id), 10))
+	}
+	return FilterMovies(apiURL, params)
+}
+
+// Запрос нескольких фильмов по ID IMDb (tt0816692) одним запросом
+func RequestMoviesByIMDbIDs(apiURL string, ids []string) ([]Cinema, error) {
+	params := url.Values{}
+	params.Set("page", "1")
+	params.Set("limit", strconv.Itoa(len(ids)))
+	for _, id := range ids {
+		params.Add("externalId.imdb", id)
+	}
+	return FilterMovies(apiURL, params)
+}
+
+// Изображение фильма: кадр, фон или дополнительный постер
+type Image struct {
+	URL        string `json:"url"`
+	PreviewURL string `json:"previewUrl,omitempty"`
+	Type       string `json:"type"`
+}
+
+// Типы изображений для галереи: кадры, фоны и обложки (альтернативные постеры)
+var ImageTypes = []string{"still", "backdrops", "cover"}
+
+// Запрос страницы изображений фильма. Возвращает изображения и число страниц.
+func RequestImages(apiURL string, movieID uint32, page, limit int) ([]Image, int, error) {
+	params := url.Values{}
+	params.Set("page", strconv.Itoa(page))
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("movieId", strconv.FormatUint(uint64(movieID), 10))
+	for _, imageType := range ImageTypes {
+		params.Add("type", imageType)
+	}
+	fullURL := fmt.Sprintf("%s?%s", apiURL, params.Encode())
+
+	var results struct {
+		Images []Image `json:"docs"`
+		Pages  int     `json:"pages"`
+	}
+	if err := doRequest(fullURL, &results); err != nil {
+		return nil, 0, err
+	}
+
+	return results.Images, results.Pages, nil
+}
+
+// Серия сезона
+type Episode struct {
+	Number  int    `json:"number"`
+	Name    string `json:"name"`
+	EnName  string `json:"enName,omitempty"`
+	AirDate string `json:"airDate,omitempty"`
+}
+
+// Сезон сериала с сериями
+type Season struct {
+	Number        int       `json:"number"`
+	EpisodesCount int       `json:"episodesCount"`
+	Episodes      []Episode `json:"episodes"`
+}
+
+// Запрос сезонов сериала с сериями, по возрастанию номера сезона
+func RequestSeasons(apiURL string, movieID uint32) ([]Season, error) {
+	params := url.Values{}
+	params.Set("page", "1")
+	params.Set("limit", "250")
+	params.Set("movieId", strconv.FormatUint(uint64(movieID), 10))
+	params.Set("sortField", "number")
+	params.Set("sortType", "1")
+	fullURL := fmt.Sprintf("%s?%s", apiURL, params.Encode())
+
+	var results struct {
+		Seasons []Season `json:"docs"`
+	}
+	if err := doRequest(fullURL, &results); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results.Seasons, func(i, j int) bool {
+		return results.Seasons[i].Number < results.Seasons[j].Number
+	})
+	for _, season := range results.Seasons {
+		sort.Slice(season.Episodes, func(i, j int) bool {
+			return season.Episodes[i].Number < season.Episodes[j].Number
+		})
+	}
+	return results.Seasons, nil
+}
+
+// Человек: актёр, режиссёр и т.д. Фильмография есть только в подробной информации.
+type Person struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	EnName     string `json:"enName,omitempty"`
+	Photo      string `json:"photo,omitempty"`
+	Birthday   string `json:"birthday,omitempty"`
+	Death      string `json:"death,omitempty"`
+	Age        int    `json:"age,omitempty"`
+	BirthPlace []struct {
+		Value string `json:"value"`
+	} `json:"birthPlace,omitempty"`
+	Profession []struct {
+		Value string `json:"value"`
+	} `json:"profession,omitempty"`
+	Movies []PersonMovie `json:"movies,omitempty"`
+}
+
+// Фильм в фильмографии человека
+type PersonMovie struct {
+	ID              uint32  `json:"id"`
+	Name            string  `json:"name"`
+	AlternativeName string  `json:"alternativeName,omitempty"`
+	Rating          float32 `json:"rating,omitempty"`
+	// Роль актёра
+	Description  string `json:"description,omitempty"`
+	EnProfession string `json:"enProfession,omitempty"`
+}
+
+// Поиск людей по имени
+func RequestPersons(apiURL string, query string) ([]Person, error) {
+	fullURL := fmt.Sprintf("%s?page=1&limit=8&query=%s", apiURL, url.QueryEscape(query))
+
+	var results struct {
+		Persons []Person `json:"docs"`
+	}
+	if err := doRequest(fullURL, &results); err != nil {
+		return nil, err
+	}
+	return results.Persons, nil
+}
+
+// Подробная информация о человеке с фильмографией
+func RequestPerson(apiURL string, id int) (*Person, error) {
+	fullURL := fmt.Sprintf("%s/%d", apiURL, id)
+
+	var person Person
+	if err := doRequest(fullURL, &person); err != nil {
+		return nil, err
+	}
+	return &person, nil
+}
+
+// Счётчик запросов к API за текущие сутки (UTC), бесплатный лимит - 200 в день
+var (
+	counterMu    sync.Mutex
+	counterDay   string
+	requestCount int
+)
+
+// Количество запросов к API за сегодня
+func RequestsToday() int {
+	counterMu.Lock()
+	defer counterMu.Unlock()
+
+	if counterDay != time.Now().UTC().Format("2006-01-02") {
+		return 0
+	}
+	return requestCount
+}
+
+func countRequest() {
+	counterMu.Lock()
+	defer counterMu.Unlock()
+
+	today := time.Now().UTC().Format("2006-01-02")
+	if counterDay != today {
+		counterDay = today
+		requestCount = 0
+	}
+	requestCount++
+}
+
+// Выполнение GET-запроса к API и разбор JSON-ответа в result
+func doRequest(fullURL string, result interface{}) error {
 	apiKey := os.Getenv("API_KEY")
 	if apiKey == "" {
-		return nil, errors.New("переменная окружения API_KEY не задана")
+		return errors.New("переменная окружения API_KEY не задана")
 	}
-	escapedName := url.QueryEscape(query)
-	fullURL := fmt.Sprintf("%s?page=1&limit=8&query=%s", apiURL, escapedName)
 	req, err := http.NewRequest("GET", fullURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка создания запроса: %v", err)
+		return fmt.Errorf("ошибка создания запроса: %v", err)
 	}
 
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("X-API-KEY", apiKey)
 
-	res, err := http.DefaultClient.Do(req)
+	countRequest()
+	res, err := Client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при отправке запроса: %v", err)
+		return fmt.Errorf("ошибка при отправке запроса: %v", err)
 	}
 	defer res.Body.Close()
 
@@ -76,29 +410,22 @@ func RequestMovies(apiURL string, query string) ([]Cinema, error) {
 		}
 		body, err := io.ReadAll(res.Body)
 		if err != nil {
-			return nil, fmt.Errorf("ошибка при чтении тела ответа об ошибке: %v", err)
+			return fmt.Errorf("ошибка при чтении тела ответа об ошибке: %v", err)
 		}
 		if err := json.Unmarshal(body, &errorMsg); err != nil {
-			return nil, fmt.Errorf("ошибка при разборе JSON ошибки: %v", err)
+			return fmt.Errorf("ошибка при разборе JSON ошибки: %v", err)
 		}
-		return nil, fmt.Errorf("API вернул ошибку: %d - %s", errorMsg.StatusCode, errorMsg.Message)
+		return fmt.Errorf("API вернул ошибку: %d - %s", errorMsg.StatusCode, errorMsg.Message)
 	}
 
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при чтении ответа: %v", err)
+		return fmt.Errorf("ошибка при чтении ответа: %v", err)
 	}
 
-	var results struct {
-		Movies []Cinema `json:"docs"`
-	}
-	err = json.Unmarshal(body, &results)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка при разборе JSON: %v", err)
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("ошибка при разборе JSON: %v", err)
 	}
 
-	// Сортировка фильмов по рейтингу KП по убыванию
-	sort.Sort(ByKpRating(results.Movies))
-
-	return results.Movies, nil
+	return nil
 }
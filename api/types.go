@@ -0,0 +1,111 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Тип фильма по классификации Кинопоиска. Значения совпадают с typeNumber API.
+type MovieType int
+
+const (
+	// Тип не указан или API вернул неизвестное значение
+	TypeUnknown        MovieType = 0
+	TypeMovie          MovieType = 1
+	TypeTVSeries       MovieType = 2
+	TypeCartoon        MovieType = 3
+	TypeAnime          MovieType = 4
+	TypeAnimatedSeries MovieType = 5
+	TypeTVShow         MovieType = 6
+)
+
+// Все известные типы по порядку номеров
+var MovieTypes = []MovieType{TypeMovie, TypeTVSeries, TypeCartoon, TypeAnime, TypeAnimatedSeries, TypeTVShow}
+
+// Значения поля type в API - они же значения фильтра type в запросах
+var typeSlugs = map[MovieType]string{
+	TypeMovie:          "movie",
+	TypeTVSeries:       "tv-series",
+	TypeCartoon:        "cartoon",
+	TypeAnime:          "anime",
+	TypeAnimatedSeries: "animated-series",
+	TypeTVShow:         "tv-show",
+}
+
+// Названия типов, которые понимает ParseType помимо значений API и номеров:
+// так пользователи пишут тип в фильтрах
+var typeAliases = map[string]MovieType{
+	"фильм":        TypeMovie,
+	"фильмы":       TypeMovie,
+	"кино":         TypeMovie,
+	"сериал":       TypeTVSeries,
+	"сериалы":      TypeTVSeries,
+	"series":       TypeTVSeries,
+	"мультфильм":   TypeCartoon,
+	"мультфильмы":  TypeCartoon,
+	"мульт":        TypeCartoon,
+	"мультик":      TypeCartoon,
+	"мультики":     TypeCartoon,
+	"аниме":        TypeAnime,
+	"мультсериал":  TypeAnimatedSeries,
+	"мультсериалы": TypeAnimatedSeries,
+	"шоу":          TypeTVShow,
+	"тв-шоу":       TypeTVShow,
+	"tv show":      TypeTVShow,
+}
+
+// Тип по значению поля type, номеру typeNumber или названию. Неизвестное значение - TypeUnknown.
+func ParseType(value string) MovieType {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if number, err := strconv.Atoi(value); err == nil {
+		return TypeFromNumber(number)
+	}
+	for movieType, slug := range typeSlugs {
+		if slug == value {
+			return movieType
+		}
+	}
+	return typeAliases[value]
+}
+
+// Тип по номеру typeNumber
+func TypeFromNumber(number int) MovieType {
+	if _, ok := typeSlugs[MovieType(number)]; ok {
+		return MovieType(number)
+	}
+	return TypeUnknown
+}
+
+// Значение для поля type и фильтра type в запросах к API, у неизвестного типа - пустое
+func (t MovieType) Slug() string {
+	return typeSlugs[t]
+}
+
+// Тип известен
+func (t MovieType) Known() bool {
+	return t != TypeUnknown
+}
+
+// Многосерийный тип: сериал, мультсериал или шоу
+func (t MovieType) IsSeries() bool {
+	return t == TypeTVSeries || t == TypeAnimatedSeries || t == TypeTVShow
+}
+
+// Тип фильма: по номеру, а если номер не указан или неизвестен - по полю type
+func (c *Cinema) MovieType() MovieType {
+	if movieType := TypeFromNumber(c.TypeNumber); movieType.Known() {
+		return movieType
+	}
+	return ParseType(c.Type)
+}
+
+// Фильтр по типам для запросов с фильтрами (FilterMovies). Неизвестные типы пропускаются.
+func TypeFilter(types ...MovieType) []string {
+	var values []string
+	for _, movieType := range types {
+		if slug := movieType.Slug(); slug != "" {
+			values = append(values, slug)
+		}
+	}
+	return values
+}
@@ -0,0 +1,237 @@
+package compare
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/render"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+)
+
+// Префикс callback-данных кнопок сравнения: cm:t:<ID фильма> - отметить фильм,
+// cm:go - сравнить отмеченные, cm:card:<ID фильма> - карточка фильма из таблицы
+const CallbackPrefix = "cm:"
+
+// Сколько фильмов помещается в таблицу сравнения
+const (
+	MinTitles = 2
+	MaxTitles = 3
+)
+
+// Отметки фильма в списке выбора
+const (
+	checked   = "✅ "
+	unchecked = "⬜ "
+)
+
+// Если за сутки к API ушло столько запросов, сравнение по запросам не начинается, а карточки
+// не из кэша не открываются: каждый запрос - это поиск и подробная информация о фильме,
+// а остаток лимита нужен поиску
+var APIReserveThreshold = 150
+
+// Разделители запросов: точка с запятой, перевод строки или «vs»
+var separators = regexp.MustCompile(`(?i);|\n|\s+vs\.?\s+`)
+
+const usage = "Напишите 2-3 названия через точку с запятой, например:\n/compare Интерстеллар; Начало\n\n" +
+	"Или сначала найдите фильмы, а затем отправьте /compare без запросов и отметьте нужные в списке."
+
+// Запросы из аргументов команды
+func ParseQueries(args string) []string {
+	var queries []string
+	for _, query := range separators.Split(args, -1) {
+		if query = strings.TrimSpace(query); query != "" {
+			queries = append(queries, query)
+		}
+	}
+	return queries
+}
+
+// Обработка команды /compare: сравнение фильмов по запросам или выбор из текущего списка
+func HandleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	queries := ParseQueries(message.CommandArguments())
+
+	switch {
+	case len(queries) == 0:
+		sendPicker(bot, message)
+		return
+	case len(queries) < MinTitles:
+		sendMessage(bot, chatID, usage)
+		return
+	case len(queries) > MaxTitles:
+		sendMessage(bot, chatID, fmt.Sprintf("Сравнить можно не больше %d фильмов за раз.", MaxTitles))
+		return
+	case api.RequestsToday()+2*len(queries) > APIReserveThreshold:
+		sendMessage(bot, chatID, "Лимит запросов к Кинопоиску на сегодня почти исчерпан. "+
+			"Сравнить можно уже найденные фильмы: отправьте /compare без запросов.")
+		return
+	}
+
+	ids := make([]uint32, 0, len(queries))
+	for i, query := range queries {
+		found, err := api.RequestMovies(api.BaseURL+"/movie/search", query)
+		if err != nil {
+			sendMessage(bot, chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+			return
+		}
+		if len(found) == 0 {
+			sendMessage(bot, chatID, fmt.Sprintf("По запросу «%s» ничего не найдено.", query))
+			return
+		}
+		for j, id := range ids {
+			if id == found[0].ID {
+				sendMessage(bot, chatID, fmt.Sprintf("По запросам «%s» и «%s» нашёлся один и тот же фильм, уточните один из них.", queries[j], queries[i]))
+				return
+			}
+		}
+		ids = append(ids, found[0].ID)
+	}
+	sendComparison(bot, message.Chat, ids)
+}
+
+// Список текущих результатов поиска с отметками для выбора фильмов
+func sendPicker(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	selection := movies.Selection(message.Chat, message.From.ID)
+	if len(selection) < MinTitles {
+		sendMessage(bot, message.Chat.ID, usage)
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, movie := range selection {
+		text := fmt.Sprintf("%s%s (%d)", unchecked, movie.Name, movie.Year)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(text, fmt.Sprintf("%st:%d", CallbackPrefix, movie.ID))))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⚖️ Сравнить", CallbackPrefix+"go")))
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Отметьте %d-%d фильма для сравнения:", MinTitles, MaxTitles))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	sender.Post(bot, msg)
+}
+
+// Обработка кнопок списка выбора. Отметки хранятся в самих кнопках сообщения.
+func HandleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	message := callback.Message
+	chatID := message.Chat.ID
+	action := strings.TrimPrefix(callback.Data, CallbackPrefix)
+	if strings.HasPrefix(action, "card:") {
+		sendCard(bot, chatID, strings.TrimPrefix(action, "card:"))
+		return
+	}
+	if message.ReplyMarkup == nil {
+		return
+	}
+	keyboard := *message.ReplyMarkup
+
+	if action == "go" {
+		ids := checkedIDs(keyboard)
+		if len(ids) < MinTitles {
+			sendMessage(bot, chatID, fmt.Sprintf("Отметьте хотя бы %d фильма.", MinTitles))
+			return
+		}
+		sendComparison(bot, message.Chat, ids)
+		return
+	}
+
+	for _, row := range keyboard.InlineKeyboard {
+		for i, button := range row {
+			if button.CallbackData == nil || *button.CallbackData != callback.Data {
+				continue
+			}
+			if strings.HasPrefix(button.Text, checked) {
+				row[i].Text = unchecked + strings.TrimPrefix(button.Text, checked)
+			} else if len(checkedIDs(keyboard)) >= MaxTitles {
+				sendMessage(bot, chatID, fmt.Sprintf("Можно отметить не больше %d фильмов.", MaxTitles))
+				return
+			} else {
+				row[i].Text = checked + strings.TrimPrefix(button.Text, unchecked)
+			}
+		}
+	}
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, message.MessageID, keyboard)
+	sender.Post(bot, edit)
+}
+
+// Карточка фильма из таблицы сравнения. Фильмы таблицы уже в кэше, поэтому
+// результаты поиска пользователя для этого не нужны и не перезаписываются.
+func sendCard(bot *tgbotapi.BotAPI, chatID int64, id string) {
+	movieID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return
+	}
+	if movie, ok := movies.CachedMovie(uint32(movieID)); ok {
+		movies.SendMovieCard(bot, chatID, movie)
+		return
+	}
+	if api.RequestsToday() >= APIReserveThreshold {
+		sendMessage(bot, chatID, "Лимит запросов к Кинопоиску на сегодня почти исчерпан, карточку можно будет открыть завтра.")
+		return
+	}
+	movie, err := movies.GetMovie(uint32(movieID))
+	if err != nil {
+		sendMessage(bot, chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+		return
+	}
+	movies.SendMovieCard(bot, chatID, movie)
+}
+
+// ID отмеченных фильмов по порядку списка
+func checkedIDs(keyboard tgbotapi.InlineKeyboardMarkup) []uint32 {
+	var ids []uint32
+	for _, row := range keyboard.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData == nil || !strings.HasPrefix(button.Text, checked) {
+				continue
+			}
+			id, err := strconv.ParseUint(strings.TrimPrefix(*button.CallbackData, CallbackPrefix+"t:"), 10, 32)
+			if err == nil {
+				ids = append(ids, uint32(id))
+			}
+		}
+	}
+	return ids
+}
+
+// Таблица сравнения по подробной информации о фильмах. Карточки открываются
+// кнопками под таблицей.
+func sendComparison(bot *tgbotapi.BotAPI, chat *tgbotapi.Chat, ids []uint32) {
+	compared := make([]api.Cinema, 0, len(ids))
+	items := make([]render.CardData, 0, len(ids))
+	for _, id := range ids {
+		movie, err := movies.GetMovie(id)
+		if err != nil {
+			sendMessage(bot, chat.ID, fmt.Sprintf("Произошла ошибка: %s", err))
+			return
+		}
+		compared = append(compared, *movie)
+		items = append(items, movies.CardData(movie))
+	}
+
+	card, err := render.Render(render.LayoutCompare, render.DefaultLocale, items...)
+	if err != nil {
+		sendMessage(bot, chat.ID, fmt.Sprintf("Произошла ошибка: %s", err))
+		return
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	for _, movie := range compared {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("🎬 "+movie.Name, fmt.Sprintf("%scard:%d", CallbackPrefix, movie.ID)))
+	}
+
+	msg := tgbotapi.NewMessage(chat.ID, card.Text)
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(row)
+	sender.Post(bot, msg)
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}
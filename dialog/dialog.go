@@ -0,0 +1,240 @@
+package dialog
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+)
+
+// Раздел хранилища с активными диалогами, ключ - "chatID:userID"
+const bucket = "dialogs"
+
+// Состояние, возвращаемое шагом для завершения диалога
+const End = ""
+
+// Время ожидания ответа, если у диалога не задано своё
+var DefaultTimeout = 10 * time.Minute
+
+// Шаг диалога: обрабатывает сообщение в текущем состоянии и возвращает следующее состояние
+// (то же - повторить шаг, End - завершить диалог)
+type Step func(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *Session) string
+
+// Описание многошагового диалога
+type Dialog struct {
+	Name string
+	// Начальное состояние
+	Start string
+	// Объявленные состояния и их шаги
+	States map[string]Step
+	// Разрешённые переходы: из состояния в перечисленные. Повтор шага и End разрешены всегда.
+	Transitions map[string][]string
+	// Время ожидания ответа пользователя, после него диалог сбрасывается
+	Timeout time.Duration
+	// Ответ на /cancel
+	CancelText string
+}
+
+// Активный диалог пользователя в чате
+type Session struct {
+	Dialog    string            `json:"dialog"`
+	State     string            `json:"state"`
+	ChatID    int64             `json:"chatId"`
+	UserID    int64             `json:"userId"`
+	Data      map[string]string `json:"data,omitempty"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+var (
+	mu      sync.Mutex
+	dialogs = make(map[string]*Dialog)
+)
+
+// Регистрация диалога. Диалоги хранятся по имени, чтобы сессии переживали перезапуск бота.
+func Register(d *Dialog) {
+	if _, ok := d.States[d.Start]; !ok {
+		panic(fmt.Sprintf("диалог %q: начальное состояние %q не объявлено", d.Name, d.Start))
+	}
+	for from, targets := range d.Transitions {
+		for _, to := range append([]string{from}, targets...) {
+			if _, ok := d.States[to]; !ok {
+				panic(fmt.Sprintf("диалог %q: переход %q -> %q в необъявленное состояние", d.Name, from, to))
+			}
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	dialogs[d.Name] = d
+}
+
+func key(chatID, userID int64) string {
+	return fmt.Sprintf("%d:%d", chatID, userID)
+}
+
+// Запуск диалога для пользователя в чате, предыдущий диалог при этом сбрасывается
+func Begin(chatID, userID int64, name string, data map[string]string) error {
+	mu.Lock()
+	d, ok := dialogs[name]
+	mu.Unlock()
+	if !ok {
+		return fmt.Errorf("ошибка при запуске диалога: диалог %q не зарегистрирован", name)
+	}
+	if data == nil {
+		data = make(map[string]string)
+	}
+	session := &Session{Dialog: d.Name, State: d.Start, ChatID: chatID, UserID: userID, Data: data, UpdatedAt: time.Now()}
+	if err := storage.Put(bucket, key(chatID, userID), session); err != nil {
+		return fmt.Errorf("ошибка при сохранении диалога: %v", err)
+	}
+	return nil
+}
+
+// Активный диалог пользователя в чате
+func Active(chatID, userID int64) (*Session, bool) {
+	session := &Session{}
+	if !storage.Get(bucket, key(chatID, userID), session) {
+		return nil, false
+	}
+	mu.Lock()
+	d, ok := dialogs[session.Dialog]
+	mu.Unlock()
+	if !ok || expired(d, session, time.Now()) {
+		return nil, false
+	}
+	return session, true
+}
+
+// Сброс диалога пользователя в чате
+func Cancel(chatID, userID int64) {
+	if err := storage.Delete(bucket, key(chatID, userID)); err != nil {
+		log.Println("Ошибка при удалении диалога:", err)
+	}
+}
+
+// Сброс всех диалогов пользователя во всех чатах
+func Forget(userID int64) error {
+	suffix := fmt.Sprintf(":%d", userID)
+	for _, key := range storage.Keys(bucket) {
+		if strings.HasSuffix(key, suffix) {
+			if err := storage.Delete(bucket, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Удаление сессий, которые истекли или относятся к незарегистрированным диалогам.
+// Возвращает число удалённых сессий.
+func PurgeExpired(now time.Time) int {
+	purged := 0
+	for _, key := range storage.Keys(bucket) {
+		session := &Session{}
+		if !storage.Get(bucket, key, session) {
+			continue
+		}
+		mu.Lock()
+		d, ok := dialogs[session.Dialog]
+		mu.Unlock()
+		if ok && !expired(d, session, now) {
+			continue
+		}
+		if err := storage.Delete(bucket, key); err != nil {
+			log.Println("Ошибка при удалении диалога:", err)
+			continue
+		}
+		purged++
+	}
+	return purged
+}
+
+// Разрешён ли переход между состояниями диалога
+func (d *Dialog) allowed(from, to string) bool {
+	if to == from {
+		return true
+	}
+	for _, target := range d.Transitions[from] {
+		if target == to {
+			return true
+		}
+	}
+	return false
+}
+
+func expired(d *Dialog, session *Session, now time.Time) bool {
+	timeout := d.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	return now.Sub(session.UpdatedAt) > timeout
+}
+
+// Передача сообщения активному диалогу. Возвращает true, если сообщение обработано диалогом.
+// /cancel завершает диалог, другие команды сбрасывают его и обрабатываются как обычно.
+func Handle(bot *tgbotapi.BotAPI, message *tgbotapi.Message) bool {
+	if message.From == nil {
+		return false
+	}
+	chatID, userID := message.Chat.ID, message.From.ID
+	session := &Session{}
+	if !storage.Get(bucket, key(chatID, userID), session) {
+		return false
+	}
+
+	mu.Lock()
+	d, ok := dialogs[session.Dialog]
+	mu.Unlock()
+	var step Step
+	if ok {
+		step, ok = d.States[session.State]
+	}
+	if !ok {
+		log.Printf("Диалог %q в состоянии %q больше не существует, сбрасываем", session.Dialog, session.State)
+		Cancel(chatID, userID)
+		return false
+	}
+	if expired(d, session, time.Now()) {
+		log.Printf("Диалог %q пользователя [%d] сброшен по таймауту", session.Dialog, userID)
+		Cancel(chatID, userID)
+		return false
+	}
+
+	if message.Command() == "cancel" {
+		Cancel(chatID, userID)
+		text := d.CancelText
+		if text == "" {
+			text = "Действие отменено."
+		}
+		sender.Post(bot, tgbotapi.NewMessage(chatID, text))
+		return true
+	}
+	if message.IsCommand() {
+		Cancel(chatID, userID)
+		return false
+	}
+
+	if session.Data == nil {
+		session.Data = make(map[string]string)
+	}
+	next := step(bot, message, session)
+	if next == End {
+		Cancel(chatID, userID)
+		return true
+	}
+	if !d.allowed(session.State, next) {
+		log.Printf("Диалог %q: недопустимый переход %q -> %q, диалог завершён", d.Name, session.State, next)
+		Cancel(chatID, userID)
+		return true
+	}
+	session.State = next
+	session.UpdatedAt = time.Now()
+	if err := storage.Put(bucket, key(chatID, userID), session); err != nil {
+		log.Println("Ошибка при сохранении диалога:", err)
+	}
+	return true
+}
@@ -0,0 +1,360 @@
+package diary
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+)
+
+// Раздел хранилища с дневниками, ключ - ID пользователя
+const bucket = "diary"
+
+// Префикс callback-данных кнопок оценки и страниц дневника
+const CallbackPrefix = "dy:"
+
+const (
+	// Записей на одной странице /diary
+	pageSize = 10
+	// Максимальная длина заметки в символах
+	maxNoteLength = 200
+)
+
+// Сколько после оценки можно ответить на сообщение заметкой
+var NoteTimeout = time.Hour
+
+// Запись дневника: просмотренный фильм с личной оценкой
+type Entry struct {
+	Movie api.Cinema `json:"movie"`
+	Score int        `json:"score"`
+	Date  time.Time  `json:"date"`
+	Note  string     `json:"note,omitempty"`
+}
+
+// Дневник просмотров пользователя
+type Diary struct {
+	UserID  int64   `json:"userId"`
+	Entries []Entry `json:"entries"`
+}
+
+// Сообщение, ответом на которое пользователь добавляет заметку
+type pendingNote struct {
+	userID  int64
+	movieID uint32
+	expires time.Time
+}
+
+var (
+	mu sync.Mutex
+	// Ключ - "chatID:messageID" сообщения о сохранённой оценке
+	pendingNotes = make(map[string]pendingNote)
+)
+
+func init() {
+	movies.AddCardRow(func(chatID int64, movie *api.Cinema) []tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⭐ Оценить",
+			fmt.Sprintf("%sask:%d:0", CallbackPrefix, movie.ID)))
+	})
+}
+
+// Кнопки оценки 1-10 вместо кнопок карточки и возврат к ним
+func rateKeyboard(movieID uint32) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, from := range []int{1, 6} {
+		var row []tgbotapi.InlineKeyboardButton
+		for score := from; score < from+5; score++ {
+			data := fmt.Sprintf("%srate:%d:%d", CallbackPrefix, movieID, score)
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(strconv.Itoa(score), data))
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("◀️ Назад",
+		fmt.Sprintf("%sback:%d:0", CallbackPrefix, movieID))))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// Дневник пользователя (пустой, если его ещё нет)
+func Load(userID int64) *Diary {
+	diary := &Diary{UserID: userID}
+	storage.Get(bucket, strconv.FormatInt(userID, 10), diary)
+	return diary
+}
+
+func save(diary *Diary) error {
+	return storage.Put(bucket, strconv.FormatInt(diary.UserID, 10), diary)
+}
+
+// Поиск записи по ID фильма
+func (d *Diary) Find(movieID uint32) *Entry {
+	for i := range d.Entries {
+		if d.Entries[i].Movie.ID == movieID {
+			return &d.Entries[i]
+		}
+	}
+	return nil
+}
+
+// Добавление записей (импорт), фильмы, которые уже есть в дневнике, пропускаются.
+// Возвращает число добавленных записей.
+func Import(userID int64, entries []Entry) (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	diary := Load(userID)
+	added := 0
+	for _, entry := range entries {
+		if diary.Find(entry.Movie.ID) != nil {
+			continue
+		}
+		diary.Entries = append(diary.Entries, entry)
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	if err := save(diary); err != nil {
+		return 0, fmt.Errorf("ошибка при сохранении дневника: %v", err)
+	}
+	return added, nil
+}
+
+// Удаление дневника пользователя
+func Forget(userID int64) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for key, pending := range pendingNotes {
+		if pending.userID == userID {
+			delete(pendingNotes, key)
+		}
+	}
+	return storage.Delete(bucket, strconv.FormatInt(userID, 10))
+}
+
+// Записи от последних к первым
+func (d *Diary) Timeline() []Entry {
+	entries := append([]Entry(nil), d.Entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date.After(entries[j].Date)
+	})
+	return entries
+}
+
+// Обработка кнопок: dy:ask:<id>:0 - показать оценки, dy:back:<id>:0 - вернуть кнопки карточки,
+// dy:rate:<id>:<оценка> и dy:page:<владелец>:<страница>
+func HandleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	parts := strings.Split(strings.TrimPrefix(callback.Data, CallbackPrefix), ":")
+	if len(parts) != 3 {
+		return
+	}
+	first, err1 := strconv.ParseInt(parts[1], 10, 64)
+	second, err2 := strconv.Atoi(parts[2])
+	if err1 != nil || err2 != nil {
+		return
+	}
+
+	switch parts[0] {
+	case "ask":
+		editCardKeyboard(bot, callback, rateKeyboard(uint32(first)))
+	case "back":
+		restoreCardKeyboard(bot, callback, uint32(first))
+	case "rate":
+		rate(bot, callback, uint32(first), second)
+	case "page":
+		// Листать дневник может только его владелец
+		if first != callback.From.ID {
+			return
+		}
+		text, keyboard := formatPage(Load(first), second)
+		edit := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+		edit.ReplyMarkup = keyboard
+		sender.Post(bot, edit)
+	}
+}
+
+func editCardKeyboard(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery, keyboard tgbotapi.InlineKeyboardMarkup) {
+	edit := tgbotapi.NewEditMessageReplyMarkup(callback.Message.Chat.ID, callback.Message.MessageID, keyboard)
+	sender.Post(bot, edit)
+}
+
+// Возврат обычных кнопок карточки вместо кнопок оценки
+func restoreCardKeyboard(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery, movieID uint32) {
+	movie, err := movies.GetMovie(movieID)
+	if err != nil {
+		log.Println("Ошибка при возврате кнопок карточки:", err)
+		return
+	}
+	editCardKeyboard(bot, callback, *movies.CardKeyboard(callback.Message.Chat.ID, movie))
+}
+
+func rate(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery, movieID uint32, score int) {
+	chatID := callback.Message.Chat.ID
+	if score < 1 || score > 10 {
+		return
+	}
+
+	movie, err := movies.GetMovie(movieID)
+	if err != nil {
+		sendMessage(bot, chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+		return
+	}
+
+	mu.Lock()
+	diary := Load(callback.From.ID)
+	if entry := diary.Find(movieID); entry != nil {
+		entry.Score = score
+		entry.Date = time.Now()
+	} else {
+		diary.Entries = append(diary.Entries, Entry{Movie: *movie, Score: score, Date: time.Now()})
+	}
+	err = save(diary)
+	mu.Unlock()
+
+	if err != nil {
+		log.Println("Ошибка при сохранении дневника:", err)
+		sendMessage(bot, chatID, "Не удалось сохранить оценку, попробуйте позже.")
+		return
+	}
+	editCardKeyboard(bot, callback, *movies.CardKeyboard(chatID, movie))
+
+	text := fmt.Sprintf("⭐ %s, оценка %d/10 для «%s» сохранена в дневник /diary.\n"+
+		"Ответьте на это сообщение, чтобы добавить короткую заметку.", callback.From.FirstName, score, movie.Name)
+	sentMsg, err := sender.Send(bot, tgbotapi.NewMessage(chatID, text))
+	if err != nil {
+		log.Println("Ошибка при отправке подтверждения оценки:", err)
+		return
+	}
+
+	mu.Lock()
+	dropExpiredNotes(time.Now())
+	pendingNotes[noteKey(chatID, sentMsg.MessageID)] = pendingNote{
+		userID:  callback.From.ID,
+		movieID: movieID,
+		expires: time.Now().Add(NoteTimeout),
+	}
+	mu.Unlock()
+}
+
+func noteKey(chatID int64, messageID int) string {
+	return fmt.Sprintf("%d:%d", chatID, messageID)
+}
+
+// Удаление заметок, время ответа на которые истекло, вызывается под mu
+func dropExpiredNotes(now time.Time) {
+	for key, pending := range pendingNotes {
+		if now.After(pending.expires) {
+			delete(pendingNotes, key)
+		}
+	}
+}
+
+// Заметка к оценке: ответ на сообщение о сохранённой оценке.
+// Возвращает true, если сообщение обработано как заметка.
+func HandleNoteReply(bot *tgbotapi.BotAPI, message *tgbotapi.Message) bool {
+	if message.ReplyToMessage == nil || message.Text == "" || message.IsCommand() {
+		return false
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	dropExpiredNotes(time.Now())
+	key := noteKey(message.Chat.ID, message.ReplyToMessage.MessageID)
+	pending, ok := pendingNotes[key]
+	if !ok || pending.userID != message.From.ID {
+		return false
+	}
+
+	diary := Load(pending.userID)
+	entry := diary.Find(pending.movieID)
+	if entry == nil {
+		return false
+	}
+	entry.Note = truncate(strings.TrimSpace(message.Text), maxNoteLength)
+	if err := save(diary); err != nil {
+		log.Println("Ошибка при сохранении заметки:", err)
+		return true
+	}
+	delete(pendingNotes, key)
+
+	sendMessage(bot, message.Chat.ID, fmt.Sprintf("📝 Заметка к «%s» сохранена.", entry.Movie.Name))
+	return true
+}
+
+// Обработка команды /diary
+func HandleDiaryCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	text, keyboard := formatPage(Load(message.From.ID), 0)
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+	sender.Post(bot, msg)
+}
+
+// Обработка команды /stats
+func HandleStatsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	sendMessage(bot, message.Chat.ID, FormatStats(ComputeStats(Load(message.From.ID).Entries)))
+}
+
+func formatPage(diary *Diary, page int) (string, *tgbotapi.InlineKeyboardMarkup) {
+	timeline := diary.Timeline()
+	if len(timeline) == 0 {
+		return "📔 Дневник пуст. Оцените фильм кнопкой «⭐ Оценить» на его карточке, и он появится здесь.", nil
+	}
+
+	pages := (len(timeline) + pageSize - 1) / pageSize
+	if page < 0 || page >= pages {
+		page = 0
+	}
+	start := page * pageSize
+	end := start + pageSize
+	if end > len(timeline) {
+		end = len(timeline)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📔 Дневник просмотров (%d, страница %d из %d):\n\n", len(timeline), page+1, pages))
+	for _, entry := range timeline[start:end] {
+		sb.WriteString(fmt.Sprintf("%s - %s (%d) ⭐ %d/10\n", entry.Date.Format("02.01.2006"), entry.Movie.Name, entry.Movie.Year, entry.Score))
+		if entry.Note != "" {
+			sb.WriteString(fmt.Sprintf("      📝 %s\n", entry.Note))
+		}
+	}
+
+	if pages == 1 {
+		return sb.String(), nil
+	}
+	var nav []tgbotapi.InlineKeyboardButton
+	owner := strconv.FormatInt(diary.UserID, 10)
+	if page > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️", CallbackPrefix+"page:"+owner+":"+strconv.Itoa(page-1)))
+	}
+	if page < pages-1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("▶️", CallbackPrefix+"page:"+owner+":"+strconv.Itoa(page+1)))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(nav)
+	return sb.String(), &keyboard
+}
+
+// Обрезка строки до limit символов
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	return string([]rune(text)[:limit-1]) + "…"
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}
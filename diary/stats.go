@@ -0,0 +1,130 @@
+package diary
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/luzhnov-aleksei/kinobot/movies"
+)
+
+// Количество значений признака, например жанра
+type Count struct {
+	Name  string
+	Count int
+}
+
+// Статистика дневника
+type Stats struct {
+	Total     int
+	Genres    []Count
+	Countries []Count
+	Decades   []Count
+	Types     []Count
+	// Средние оценки: личная и рейтинги КП/IMDb по тем же фильмам
+	AvgScore float64
+	AvgKp    float64
+	AvgImdb  float64
+}
+
+// Подсчёт статистики по записям дневника
+func ComputeStats(entries []Entry) Stats {
+	stats := Stats{Total: len(entries)}
+	if len(entries) == 0 {
+		return stats
+	}
+
+	genres := make(map[string]int)
+	countries := make(map[string]int)
+	decades := make(map[string]int)
+	types := make(map[string]int)
+	var scoreSum, kpSum, imdbSum float64
+	var kpCount, imdbCount int
+
+	for _, entry := range entries {
+		movie := entry.Movie
+		for _, genre := range movie.Genres {
+			genres[genre.Name]++
+		}
+		for _, country := range movie.Countries {
+			countries[country.Name]++
+		}
+		if movie.Year > 0 {
+			decades[fmt.Sprintf("%d-е", movie.Year/10*10)]++
+		}
+		if typeName := movies.TypeName(movie.MovieType()); typeName != "" {
+			types[typeName]++
+		}
+
+		scoreSum += float64(entry.Score)
+		if movie.Rating.Kp > 0 {
+			kpSum += float64(movie.Rating.Kp)
+			kpCount++
+		}
+		if movie.Rating.Imdb > 0 {
+			imdbSum += float64(movie.Rating.Imdb)
+			imdbCount++
+		}
+	}
+
+	stats.Genres = sortCounts(genres)
+	stats.Countries = sortCounts(countries)
+	stats.Decades = sortCounts(decades)
+	stats.Types = sortCounts(types)
+	stats.AvgScore = scoreSum / float64(len(entries))
+	if kpCount > 0 {
+		stats.AvgKp = kpSum / float64(kpCount)
+	}
+	if imdbCount > 0 {
+		stats.AvgImdb = imdbSum / float64(imdbCount)
+	}
+	return stats
+}
+
+// Сортировка по убыванию количества, при равенстве - по названию
+func sortCounts(counts map[string]int) []Count {
+	result := make([]Count, 0, len(counts))
+	for name, count := range counts {
+		result = append(result, Count{Name: name, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Текст статистики для /stats
+func FormatStats(stats Stats) string {
+	if stats.Total == 0 {
+		return "📊 Статистики пока нет: оцените просмотренные фильмы кнопкой «⭐ Оценить» на карточке."
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📊 Статистика просмотров\n\n")
+	sb.WriteString(fmt.Sprintf("Просмотрено: %d\n", stats.Total))
+	sb.WriteString(fmt.Sprintf("Средняя личная оценка: %.1f\n", stats.AvgScore))
+	sb.WriteString(fmt.Sprintf("Средний рейтинг этих фильмов: КП %.1f, IMDb %.1f\n\n", stats.AvgKp, stats.AvgImdb))
+	writeCounts(&sb, "По типам", stats.Types)
+	writeCounts(&sb, "По жанрам", stats.Genres)
+	writeCounts(&sb, "По странам", stats.Countries)
+	writeCounts(&sb, "По десятилетиям", stats.Decades)
+	return strings.TrimSpace(sb.String())
+}
+
+// Строка с первыми пятью значениями признака
+func writeCounts(sb *strings.Builder, title string, counts []Count) {
+	if len(counts) == 0 {
+		return
+	}
+	if len(counts) > 5 {
+		counts = counts[:5]
+	}
+	parts := make([]string, 0, len(counts))
+	for _, count := range counts {
+		parts = append(parts, fmt.Sprintf("%s - %d", count.Name, count.Count))
+	}
+	sb.WriteString(fmt.Sprintf("%s: %s\n", title, strings.Join(parts, ", ")))
+}
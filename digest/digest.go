@@ -0,0 +1,370 @@
+package digest
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/groups"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/recommend"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+	"github.com/luzhnov-aleksei/kinobot/watchlist"
+)
+
+// Раздел хранилища с подписками, ключ - ID чата
+const bucket = "digest"
+
+// Префикс callback-данных кнопок дайджеста
+const CallbackPrefix = "dg:"
+
+const (
+	// Фильмов в каждом разделе дайджеста
+	sectionSize = 5
+	// Напоминаний о давно добавленных фильмах
+	maxReminders = 3
+)
+
+var (
+	// Через сколько дней фильм в списке считается давно ждущим
+	WaitingDays = 30
+	// Если за сутки к API ушло столько запросов, разделы с подборками пропускаются,
+	// чтобы поиску пользователей хватило лимита
+	APIReserveThreshold = 150
+)
+
+// Подписка чата на дайджест
+type Subscription struct {
+	ChatID   int64    `json:"chatId"`
+	UserID   int64    `json:"userId"`
+	IsGroup  bool     `json:"isGroup,omitempty"`
+	Schedule Schedule `json:"schedule"`
+	// Время следующей отправки, хранится в хранилище, чтобы пережить перезапуск
+	NextRun time.Time `json:"nextRun"`
+	LastRun time.Time `json:"lastRun,omitempty"`
+}
+
+var mu sync.Mutex
+
+// Подписка чата, если она есть
+func Load(chatID int64) (*Subscription, bool) {
+	subscription := &Subscription{}
+	ok := storage.Get(bucket, strconv.FormatInt(chatID, 10), subscription)
+	return subscription, ok
+}
+
+func save(subscription *Subscription) {
+	if err := storage.Put(bucket, strconv.FormatInt(subscription.ChatID, 10), subscription); err != nil {
+		log.Println("Ошибка при сохранении подписки на дайджест:", err)
+	}
+}
+
+// Удаление личной подписки пользователя. В подписках групп пользователь перестаёт быть автором.
+func Forget(userID int64) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, key := range storage.Keys(bucket) {
+		var subscription Subscription
+		if !storage.Get(bucket, key, &subscription) {
+			continue
+		}
+		if subscription.ChatID == userID {
+			if err := storage.Delete(bucket, key); err != nil {
+				return err
+			}
+		} else if subscription.UserID == userID {
+			subscription.UserID = 0
+			if err := storage.Put(bucket, key, &subscription); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Обработка команды /digest
+func HandleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	chatID := message.Chat.ID
+	args := strings.Fields(message.CommandArguments())
+	subscription, exists := Load(chatID)
+
+	if len(args) == 0 {
+		if !exists {
+			sendMessage(bot, chatID, usage)
+			return
+		}
+		sendMessage(bot, chatID, fmt.Sprintf("📬 Дайджест приходит %s.\nБлижайший: %s\n\n/digest off - отписаться",
+			subscription.Schedule, formatTime(subscription.NextRun, subscription.Schedule)))
+		return
+	}
+
+	isGroup := groups.IsGroup(message.Chat)
+	if isGroup && !groups.IsAdmin(bot, chatID, message.From.ID) {
+		sendMessage(bot, chatID, "Настраивать дайджест в группе могут только администраторы.")
+		return
+	}
+
+	if strings.ToLower(args[0]) == "off" {
+		if !exists {
+			sendMessage(bot, chatID, "Подписки на дайджест нет.")
+			return
+		}
+		if err := storage.Delete(bucket, strconv.FormatInt(chatID, 10)); err != nil {
+			log.Println("Ошибка при удалении подписки на дайджест:", err)
+		}
+		sendMessage(bot, chatID, "Вы отписались от дайджеста.")
+		return
+	}
+
+	schedule, err := ParseSchedule(args)
+	if err != nil {
+		sendMessage(bot, chatID, fmt.Sprintf("Не удалось разобрать расписание: %v\n\n%s", err, usage))
+		return
+	}
+	subscription = &Subscription{
+		ChatID:   chatID,
+		UserID:   message.From.ID,
+		IsGroup:  isGroup,
+		Schedule: schedule,
+		NextRun:  schedule.Next(time.Now()),
+	}
+	save(subscription)
+	sendMessage(bot, chatID, fmt.Sprintf("✅ Дайджест будет приходить %s.\nБлижайший: %s",
+		schedule, formatTime(subscription.NextRun, schedule)))
+}
+
+const usage = "📬 Дайджест: премьеры, новинки в любимых жанрах и напоминания о фильмах из списка.\n\n" +
+	"/digest daily 09:00 - каждый день\n" +
+	"/digest weekly пт 19:30 Europe/Moscow - раз в неделю, день и часовой пояс можно не указывать\n" +
+	"/digest off - отписаться\n\n" +
+	"Часовой пояс - название (Asia/Yekaterinburg) или смещение (UTC+5), по умолчанию Europe/Moscow."
+
+// Отправка дайджестов, время которых наступило. Если бот был выключен и пропустил
+// несколько отправок, приходит один дайджест, а расписание продолжается с текущего момента.
+func SendDue(bot *tgbotapi.BotAPI, now time.Time) {
+	mu.Lock()
+	var due []Subscription
+	for _, key := range storage.Keys(bucket) {
+		chatID, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			continue
+		}
+		subscription, ok := Load(chatID)
+		if !ok || now.Before(subscription.NextRun) {
+			continue
+		}
+		due = append(due, *subscription)
+		// Следующая отправка сохраняется до текущей, чтобы после сбоя дайджест не пришёл дважды
+		subscription.LastRun = now
+		subscription.NextRun = subscription.Schedule.Next(now)
+		save(subscription)
+	}
+	mu.Unlock()
+
+	// Одинаковые запросы (премьеры, подборки по жанру) за один проход не повторяются
+	cache := make(map[string][]api.Cinema)
+	for _, subscription := range due {
+		text, keyboard := Build(subscription, now, cache)
+		msg := tgbotapi.NewMessage(subscription.ChatID, text)
+		if keyboard != nil {
+			msg.ReplyMarkup = keyboard
+		}
+		sender.Post(bot, msg)
+	}
+}
+
+// Фоновая отправка дайджестов по расписанию
+func Run(bot *tgbotapi.BotAPI, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		SendDue(bot, now)
+	}
+}
+
+// Текст дайджеста и кнопки для открытия карточек
+func Build(subscription Subscription, now time.Time, cache map[string][]api.Cinema) (string, *tgbotapi.InlineKeyboardMarkup) {
+	schedule := subscription.Schedule
+	var sb strings.Builder
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	addButton := func(movie api.Cinema) {
+		label := fmt.Sprintf("%s (%d)", movie.Name, movie.Year)
+		data := CallbackPrefix + "card:" + strconv.FormatUint(uint64(movie.ID), 10)
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, data)))
+	}
+
+	if schedule.Weekly {
+		sb.WriteString("📬 Дайджест на неделю\n")
+	} else {
+		sb.WriteString("📬 Дайджест на день\n")
+	}
+	empty := true
+
+	premieres := fetch(cache, premiereParams(now, now.Add(schedule.Period())))
+	if len(premieres) > 0 {
+		empty = false
+		sb.WriteString("\n🎬 Премьеры:\n")
+		for _, movie := range premieres {
+			sb.WriteString(fmt.Sprintf("• %s (%d)%s\n", movie.Name, movie.Year, premiereDate(movie)))
+			addButton(movie)
+		}
+	}
+
+	// Новинки подбираются по вкусу того, кто оформил подписку
+	profile := recommend.BuildProfile(recommend.CollectSignals(subscription.UserID, subscription.ChatID))
+	if genres := profile.TopGenres(1); len(genres) > 0 {
+		var picks []api.Cinema
+		for _, movie := range fetch(cache, newArrivalParams(genres[0], now)) {
+			if !profile.Seen[movie.ID] && len(picks) < sectionSize {
+				picks = append(picks, movie)
+			}
+		}
+		if len(picks) > 0 {
+			empty = false
+			sb.WriteString(fmt.Sprintf("\n⭐ Новинки в жанре «%s»:\n", genres[0]))
+			for _, movie := range picks {
+				sb.WriteString(fmt.Sprintf("• %s (%d) - КП %.1f\n", movie.Name, movie.Year, movie.Rating.Kp))
+				addButton(movie)
+			}
+		}
+	}
+
+	if reminders := longWaiting(subscription.ChatID, now); len(reminders) > 0 {
+		empty = false
+		sb.WriteString("\n⏳ Давно ждут в списке /list:\n")
+		for _, entry := range reminders {
+			days := int(now.Sub(entry.AddedAt).Hours() / 24)
+			sb.WriteString(fmt.Sprintf("• %s (%d) - в списке %d дн.\n", entry.Movie.Name, entry.Movie.Year, days))
+		}
+	}
+
+	if empty {
+		sb.WriteString("\nНа этот раз ничего нового. Добавляйте фильмы в список и оценивайте просмотренные - подборка станет точнее.")
+	}
+	sb.WriteString("\n/digest off - отписаться")
+
+	if len(buttons) == 0 {
+		return sb.String(), nil
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	return sb.String(), &keyboard
+}
+
+// Премьеры в России за период, самые ожидаемые первыми
+func premiereParams(from, to time.Time) url.Values {
+	params := url.Values{}
+	params.Set("page", "1")
+	params.Set("limit", strconv.Itoa(sectionSize))
+	params.Set("premiere.russia", from.Format("02.01.2006")+"-"+to.Format("02.01.2006"))
+	params.Set("sortField", "votes.await")
+	params.Set("sortType", "-1")
+	params.Add("notNullFields", "name")
+	return params
+}
+
+// Лучшие фильмы жанра за этот и прошлый год
+func newArrivalParams(genre string, now time.Time) url.Values {
+	params := url.Values{}
+	params.Set("page", "1")
+	params.Set("limit", "20")
+	params.Set("genres.name", genre)
+	params.Set("year", fmt.Sprintf("%d-%d", now.Year()-1, now.Year()))
+	params.Set("rating.kp", "7-10")
+	params.Set("sortField", "rating.kp")
+	params.Set("sortType", "-1")
+	params.Add("notNullFields", "name")
+	return params
+}
+
+func fetch(cache map[string][]api.Cinema, params url.Values) []api.Cinema {
+	key := params.Encode()
+	if found, ok := cache[key]; ok {
+		return found
+	}
+	if api.RequestsToday() >= APIReserveThreshold {
+		log.Println("Лимит запросов к API почти исчерпан, подборка для дайджеста пропущена")
+		return nil
+	}
+	found, err := api.FilterMovies(api.BaseURL+"/movie", params)
+	if err != nil {
+		log.Println("Ошибка при подборе фильмов для дайджеста:", err)
+	}
+	cache[key] = found
+	return found
+}
+
+// Непросмотренные фильмы, которые лежат в списке дольше WaitingDays, самые старые первыми
+func longWaiting(chatID int64, now time.Time) []watchlist.Entry {
+	var result []watchlist.Entry
+	for _, entry := range watchlist.Load(chatID).Unwatched() {
+		if now.Sub(entry.AddedAt) >= time.Duration(WaitingDays)*24*time.Hour {
+			result = append(result, entry)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].AddedAt.Before(result[j].AddedAt)
+	})
+	if len(result) > maxReminders {
+		result = result[:maxReminders]
+	}
+	return result
+}
+
+// Дата премьеры в России для строки дайджеста
+func premiereDate(movie api.Cinema) string {
+	if movie.Premiere == nil || movie.Premiere.Russia == "" {
+		return ""
+	}
+	date, err := time.Parse(time.RFC3339, movie.Premiere.Russia)
+	if err != nil {
+		return ""
+	}
+	return " - " + date.Format("02.01")
+}
+
+// Время в часовом поясе расписания
+func formatTime(t time.Time, schedule Schedule) string {
+	if location, err := loadLocation(schedule.TimeZone); err == nil {
+		t = t.In(location)
+	}
+	return t.Format("02.01.2006 15:04")
+}
+
+// Обработка кнопки dg:card:<id> - карточка фильма из дайджеста
+func HandleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	parts := strings.Split(strings.TrimPrefix(callback.Data, CallbackPrefix), ":")
+	if len(parts) != 2 || parts[0] != "card" {
+		return
+	}
+	movieID, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return
+	}
+
+	chatID := callback.Message.Chat.ID
+	movie, err := movies.GetMovie(uint32(movieID))
+	if err != nil {
+		log.Println("Ошибка при получении фильма из дайджеста:", err)
+		sendMessage(bot, chatID, "Не удалось загрузить фильм, попробуйте позже.")
+		return
+	}
+	movies.SendMovieCard(bot, chatID, movie)
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}
@@ -0,0 +1,159 @@
+package digest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// Встроенная база часовых поясов: в образе alpine её нет
+	_ "time/tzdata"
+)
+
+// Часовой пояс по умолчанию
+const defaultTimeZone = "Europe/Moscow"
+
+// Расписание дайджеста: каждый день или раз в неделю в заданное время по часовому поясу пользователя
+type Schedule struct {
+	Weekly   bool         `json:"weekly"`
+	Weekday  time.Weekday `json:"weekday"`
+	Hour     int          `json:"hour"`
+	Minute   int          `json:"minute"`
+	TimeZone string       `json:"timeZone"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"пн": time.Monday, "вт": time.Tuesday, "ср": time.Wednesday, "чт": time.Thursday,
+	"пт": time.Friday, "сб": time.Saturday, "вс": time.Sunday,
+	"mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday, "thu": time.Thursday,
+	"fri": time.Friday, "sat": time.Saturday, "sun": time.Sunday,
+}
+
+var weekdayNames = [...]string{"в воскресенье", "в понедельник", "во вторник", "в среду", "в четверг", "в пятницу", "в субботу"}
+
+// Разбор аргументов /digest: daily|weekly [день] ЧЧ:ММ [часовой пояс]
+func ParseSchedule(args []string) (Schedule, error) {
+	schedule := Schedule{Weekday: time.Monday, TimeZone: defaultTimeZone}
+	if len(args) == 0 {
+		return schedule, fmt.Errorf("не указана периодичность")
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "daily":
+	case "weekly":
+		schedule.Weekly = true
+	default:
+		return schedule, fmt.Errorf("неизвестная периодичность %q, нужно daily или weekly", args[0])
+	}
+	args = args[1:]
+
+	if schedule.Weekly && len(args) > 0 {
+		if day, ok := weekdays[strings.ToLower(args[0])]; ok {
+			schedule.Weekday = day
+			args = args[1:]
+		}
+	}
+
+	if len(args) == 0 {
+		return schedule, fmt.Errorf("не указано время")
+	}
+	clock, err := time.Parse("15:04", args[0])
+	if err != nil {
+		return schedule, fmt.Errorf("неверное время %q, нужно ЧЧ:ММ", args[0])
+	}
+	schedule.Hour, schedule.Minute = clock.Hour(), clock.Minute()
+	args = args[1:]
+
+	if len(args) > 0 {
+		if _, err := loadLocation(args[0]); err != nil {
+			return schedule, err
+		}
+		schedule.TimeZone = args[0]
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		return schedule, fmt.Errorf("лишние аргументы: %s", strings.Join(args, " "))
+	}
+	return schedule, nil
+}
+
+// Часовой пояс: имя из базы (Europe/Moscow) или смещение (UTC+3, +03:00, -5:30)
+func loadLocation(name string) (*time.Location, error) {
+	offset := strings.TrimPrefix(strings.TrimPrefix(strings.ToUpper(name), "UTC"), "GMT")
+	if offset == "" {
+		return time.UTC, nil
+	}
+	if offset[0] == '+' || offset[0] == '-' {
+		sign := 1
+		if offset[0] == '-' {
+			sign = -1
+		}
+		hours, minutes, hasMinutes := strings.Cut(offset[1:], ":")
+		h, ok := parseDigits(hours, 2)
+		if !ok || h > 14 {
+			return nil, fmt.Errorf("неверный часовой пояс %q", name)
+		}
+		m := 0
+		if hasMinutes {
+			if m, ok = parseDigits(minutes, 2); !ok || len(minutes) != 2 || m >= 60 {
+				return nil, fmt.Errorf("неверный часовой пояс %q", name)
+			}
+		}
+		return time.FixedZone(name, sign*(h*3600+m*60)), nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("неизвестный часовой пояс %q", name)
+	}
+	return location, nil
+}
+
+// Число из одной или нескольких цифр без знака, не длиннее maxLen
+func parseDigits(s string, maxLen int) (int, bool) {
+	if s == "" || len(s) > maxLen {
+		return 0, false
+	}
+	n := 0
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+		n = n*10 + int(r-'0')
+	}
+	return n, true
+}
+
+// Ближайший запуск строго после after. Время считается по часовому поясу
+// расписания, поэтому переход на летнее время его не сдвигает.
+func (s Schedule) Next(after time.Time) time.Time {
+	location, err := loadLocation(s.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+	local := after.In(location)
+	for days := 0; ; days++ {
+		next := time.Date(local.Year(), local.Month(), local.Day()+days, s.Hour, s.Minute, 0, 0, location)
+		if s.Weekly && next.Weekday() != s.Weekday {
+			continue
+		}
+		if next.After(after) {
+			return next
+		}
+	}
+}
+
+// Период, за который собирается дайджест
+func (s Schedule) Period() time.Duration {
+	if s.Weekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Описание расписания для пользователя
+func (s Schedule) String() string {
+	clock := fmt.Sprintf("%02d:%02d (%s)", s.Hour, s.Minute, s.TimeZone)
+	if s.Weekly {
+		return fmt.Sprintf("раз в неделю, %s в %s", weekdayNames[s.Weekday], clock)
+	}
+	return "каждый день в " + clock
+}
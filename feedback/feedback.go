@@ -0,0 +1,378 @@
+package feedback
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/dialog"
+	"github.com/luzhnov-aleksei/kinobot/history"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+)
+
+const (
+	// Обращения, ключ - номер обращения
+	ticketsBucket = "feedback"
+	// Служебные данные: номер следующего обращения
+	metaBucket = "feedback_meta"
+	// Пересланные в чат администраторов сообщения, ключ - ID сообщения, значение - номер обращения
+	messagesBucket = "feedback_messages"
+)
+
+// Префикс callback-данных кнопок обращений
+const CallbackPrefix = "fb:"
+
+// Статусы обращения
+const (
+	StatusOpen     = "open"
+	StatusAnswered = "answered"
+	StatusClosed   = "closed"
+)
+
+const (
+	// Обращений от одного пользователя в сутки
+	maxTicketsPerDay = 3
+	// Telegram ограничивает подпись к фото 1024 символами, а сообщение - 4096
+	maxCaptionLength = 1024
+	maxMessageLength = 4096
+)
+
+// Чат администраторов, куда пересылаются обращения (переменная окружения FEEDBACK_CHAT_ID)
+var ChatID int64
+
+// Ответ администратора на обращение
+type Reply struct {
+	From int64     `json:"from"`
+	Text string    `json:"text"`
+	At   time.Time `json:"at"`
+}
+
+// Обращение пользователя
+type Ticket struct {
+	ID        int    `json:"id"`
+	UserID    int64  `json:"userId"`
+	ChatID    int64  `json:"chatId"`
+	FirstName string `json:"firstName"`
+	UserName  string `json:"userName,omitempty"`
+	Text      string `json:"text"`
+	// Скриншот (file_id самого большого размера фото)
+	PhotoFileID string    `json:"photoFileId,omitempty"`
+	LastQuery   string    `json:"lastQuery,omitempty"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Replies     []Reply   `json:"replies,omitempty"`
+	// Сообщение с обращением в чате администраторов
+	ForwardedMessageID int `json:"forwardedMessageId,omitempty"`
+}
+
+// Обращения нумеруются и пересылаются под блокировкой
+var mu sync.Mutex
+
+func init() {
+	dialog.Register(&dialog.Dialog{
+		Name:       "feedback",
+		Start:      "text",
+		States:     map[string]dialog.Step{"text": handleText},
+		CancelText: "Обращение отменено.",
+	})
+}
+
+// Обращение по номеру
+func Load(id int) (*Ticket, bool) {
+	ticket := &Ticket{}
+	ok := storage.Get(ticketsBucket, strconv.Itoa(id), ticket)
+	return ticket, ok
+}
+
+func save(ticket *Ticket) error {
+	return storage.Put(ticketsBucket, strconv.Itoa(ticket.ID), ticket)
+}
+
+// Все обращения по возрастанию номера
+func Tickets() []Ticket {
+	var tickets []Ticket
+	for _, key := range storage.Keys(ticketsBucket) {
+		var ticket Ticket
+		if storage.Get(ticketsBucket, key, &ticket) {
+			tickets = append(tickets, ticket)
+		}
+	}
+	sort.Slice(tickets, func(i, j int) bool { return tickets[i].ID < tickets[j].ID })
+	return tickets
+}
+
+// Удаление обращений пользователя и связей с пересланными сообщениями
+func Forget(userID int64) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	removed := make(map[int]bool)
+	for _, ticket := range Tickets() {
+		if ticket.UserID != userID {
+			continue
+		}
+		if err := storage.Delete(ticketsBucket, strconv.Itoa(ticket.ID)); err != nil {
+			return err
+		}
+		removed[ticket.ID] = true
+	}
+	for _, key := range storage.Keys(messagesBucket) {
+		var ticketID int
+		if storage.Get(messagesBucket, key, &ticketID) && removed[ticketID] {
+			if err := storage.Delete(messagesBucket, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func nextID() int {
+	var next int
+	storage.Get(metaBucket, "next", &next)
+	if next == 0 {
+		next = 1
+	}
+	if err := storage.Put(metaBucket, "next", next+1); err != nil {
+		log.Println("Ошибка при сохранении номера обращения:", err)
+	}
+	return next
+}
+
+// Обработка команды /feedback: с текстом сразу создаёт обращение, без текста - ждёт следующее сообщение
+func HandleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	if !message.Chat.IsPrivate() {
+		sendMessage(bot, message.Chat.ID, "Чтобы написать разработчикам, отправьте /feedback боту в личные сообщения.")
+		return
+	}
+	if text := strings.TrimSpace(message.CommandArguments()); text != "" {
+		createTicket(bot, message, text, "")
+		return
+	}
+
+	if err := dialog.Begin(message.Chat.ID, message.From.ID, "feedback", nil); err != nil {
+		log.Println(err)
+		return
+	}
+	sendMessage(bot, message.Chat.ID, "✉️ Опишите проблему или идею одним сообщением, можно приложить скриншот с подписью.\n/cancel - отменить")
+}
+
+// Шаг диалога /feedback: текст обращения или скриншот с подписью
+func handleText(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *dialog.Session) string {
+	text := strings.TrimSpace(message.Text)
+	photoID := ""
+	if len(message.Photo) > 0 {
+		text = strings.TrimSpace(message.Caption)
+		photoID = message.Photo[len(message.Photo)-1].FileID
+	}
+	if text == "" && photoID == "" {
+		sendMessage(bot, message.Chat.ID, "Обращение должно содержать текст или скриншот. Попробуйте ещё раз или отправьте /cancel.")
+		return session.State
+	}
+	createTicket(bot, message, text, photoID)
+	return dialog.End
+}
+
+func createTicket(bot *tgbotapi.BotAPI, message *tgbotapi.Message, text string, photoID string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	user := message.From
+	today := 0
+	for _, ticket := range Tickets() {
+		if ticket.UserID == user.ID && time.Since(ticket.CreatedAt) < 24*time.Hour {
+			today++
+		}
+	}
+	if today >= maxTicketsPerDay {
+		sendMessage(bot, message.Chat.ID, "Вы уже отправили несколько обращений за сутки, мы ответим на них в ближайшее время.")
+		return
+	}
+
+	ticket := &Ticket{
+		ID:          nextID(),
+		UserID:      user.ID,
+		ChatID:      message.Chat.ID,
+		FirstName:   user.FirstName,
+		UserName:    user.UserName,
+		Text:        text,
+		PhotoFileID: photoID,
+		LastQuery:   history.Load(user.ID).LastQuery,
+		Status:      StatusOpen,
+		CreatedAt:   time.Now(),
+	}
+	ticket.ForwardedMessageID = forward(bot, ticket)
+	if err := save(ticket); err != nil {
+		log.Println("Ошибка при сохранении обращения:", err)
+		sendMessage(bot, message.Chat.ID, "Не удалось сохранить обращение, попробуйте позже.")
+		return
+	}
+	log.Printf("Новое обращение #%d от пользователя [%d]", ticket.ID, user.ID)
+	if ChatID != 0 && ticket.ForwardedMessageID == 0 {
+		sendMessage(bot, message.Chat.ID, fmt.Sprintf("Обращение #%d сохранено, но пока не доставлено разработчикам. "+
+			"Мы увидим его в списке обращений, ответ придёт сюда.", ticket.ID))
+		return
+	}
+	sendMessage(bot, message.Chat.ID, fmt.Sprintf("Спасибо! Обращение #%d передано разработчикам, ответ придёт сюда.", ticket.ID))
+}
+
+// Пересылка обращения в чат администраторов, возвращает ID сообщения в этом чате
+func forward(bot *tgbotapi.BotAPI, ticket *Ticket) int {
+	if ChatID == 0 {
+		log.Printf("FEEDBACK_CHAT_ID не задан, обращение #%d сохранено без пересылки", ticket.ID)
+		return 0
+	}
+
+	text := formatTicket(ticket)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ Закрыть", CallbackPrefix+"close:"+strconv.Itoa(ticket.ID)),
+	))
+
+	var sent tgbotapi.Message
+	var err error
+	if ticket.PhotoFileID != "" {
+		photo := tgbotapi.NewPhoto(ChatID, tgbotapi.FileID(ticket.PhotoFileID))
+		photo.Caption = truncate(text, maxCaptionLength)
+		photo.ReplyMarkup = keyboard
+		sent, err = sender.Send(bot, photo)
+	} else {
+		msg := tgbotapi.NewMessage(ChatID, truncate(text, maxMessageLength))
+		msg.ReplyMarkup = keyboard
+		sent, err = sender.Send(bot, msg)
+	}
+	if err != nil {
+		log.Println("Ошибка при пересылке обращения:", err)
+		return 0
+	}
+
+	if err := storage.Put(messagesBucket, strconv.Itoa(sent.MessageID), ticket.ID); err != nil {
+		log.Println("Ошибка при сохранении связи обращения с сообщением:", err)
+	}
+	return sent.MessageID
+}
+
+// Текст обращения для администраторов
+func formatTicket(ticket *Ticket) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🆘 Обращение #%d\n", ticket.ID))
+	sb.WriteString(fmt.Sprintf("От: %s", ticket.FirstName))
+	if ticket.UserName != "" {
+		sb.WriteString(" @" + ticket.UserName)
+	}
+	sb.WriteString(fmt.Sprintf(" (ID %d)\n", ticket.UserID))
+	if ticket.LastQuery != "" {
+		sb.WriteString(fmt.Sprintf("Последний запрос: «%s»\n", ticket.LastQuery))
+	}
+	sb.WriteString("\n" + ticket.Text)
+	sb.WriteString("\n\nОтветьте на это сообщение, чтобы ответить пользователю.")
+	return sb.String()
+}
+
+// Ответ администратора на пересланное обращение. Возвращает true, если сообщение обработано.
+func HandleAdminReply(bot *tgbotapi.BotAPI, message *tgbotapi.Message) bool {
+	if ChatID == 0 || message.Chat.ID != ChatID || message.ReplyToMessage == nil || message.Text == "" || message.IsCommand() {
+		return false
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var ticketID int
+	if !storage.Get(messagesBucket, strconv.Itoa(message.ReplyToMessage.MessageID), &ticketID) {
+		return false
+	}
+	ticket, ok := Load(ticketID)
+	if !ok {
+		return false
+	}
+
+	answer := fmt.Sprintf("💬 Ответ на обращение #%d:\n\n%s", ticket.ID, message.Text)
+	if _, err := sender.Send(bot, tgbotapi.NewMessage(ticket.ChatID, answer)); err != nil {
+		log.Println("Ошибка при отправке ответа на обращение:", err)
+		sendMessage(bot, message.Chat.ID, fmt.Sprintf("Не удалось доставить ответ на обращение #%d: %v", ticket.ID, err))
+		return true
+	}
+
+	ticket.Replies = append(ticket.Replies, Reply{From: message.From.ID, Text: message.Text, At: time.Now()})
+	if ticket.Status == StatusOpen {
+		ticket.Status = StatusAnswered
+	}
+	if err := save(ticket); err != nil {
+		log.Println("Ошибка при сохранении ответа на обращение:", err)
+	}
+	sendMessage(bot, message.Chat.ID, fmt.Sprintf("Ответ на обращение #%d отправлен.", ticket.ID))
+	return true
+}
+
+// Обработка кнопки fb:close:<номер>
+func HandleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	if ChatID == 0 || callback.Message.Chat.ID != ChatID {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(callback.Data, CallbackPrefix), ":")
+	if len(parts) != 2 || parts[0] != "close" {
+		return
+	}
+	ticketID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	ticket, ok := Load(ticketID)
+	if !ok {
+		return
+	}
+	ticket.Status = StatusClosed
+	if err := save(ticket); err != nil {
+		log.Println("Ошибка при закрытии обращения:", err)
+		return
+	}
+
+	empty := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	edit := tgbotapi.NewEditMessageReplyMarkup(callback.Message.Chat.ID, callback.Message.MessageID, empty)
+	sender.Post(bot, edit)
+	sendMessage(bot, callback.Message.Chat.ID, fmt.Sprintf("Обращение #%d закрыто.", ticket.ID))
+}
+
+// Список незакрытых обращений для администратора
+func FormatOpenTickets() string {
+	var sb strings.Builder
+	for _, ticket := range Tickets() {
+		if ticket.Status == StatusClosed {
+			continue
+		}
+		status := "новое"
+		if ticket.Status == StatusAnswered {
+			status = "есть ответ"
+		}
+		sb.WriteString(fmt.Sprintf("#%d %s (%s, %s): %s\n", ticket.ID, ticket.FirstName,
+			ticket.CreatedAt.Format("02.01.2006"), status, truncate(ticket.Text, 60)))
+	}
+	if sb.Len() == 0 {
+		return "Незакрытых обращений нет."
+	}
+	return "🆘 Незакрытые обращения:\n\n" + strings.TrimSpace(sb.String())
+}
+
+// Обрезка текста до limit символов
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	return string([]rune(text)[:limit-1]) + "…"
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}
@@ -0,0 +1,196 @@
+package gallery
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/media"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+)
+
+// Префикс callback-данных кнопок галереи: gl:<ID фильма>:<страница>
+const CallbackPrefix = "gl:"
+
+// Изображений в одном альбоме - больше Telegram не принимает
+const PageSize = 10
+
+// Сколько страниц галерей хранится в кэше и сколько живёт страница
+const (
+	MaxCachedPages = 1000
+	CacheTTL       = 24 * time.Hour
+)
+
+// Сколько запросов к API за сутки можно потратить, прежде чем галерея перестанет
+// загружать новые страницы: кнопки не проходят через лимит сообщений
+var APIReserveThreshold = 150
+
+// Страница галереи с названием фильма для подписи
+type cachedPage struct {
+	images   []api.Image
+	pages    int
+	title    string
+	cachedAt time.Time
+}
+
+// Уже загруженные страницы, ключ - ID фильма и номер страницы
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]cachedPage)
+)
+
+func init() {
+	movies.AddCardRow(func(chatID int64, movie *api.Cinema) []tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🖼 Галерея", pageData(movie.ID, 1)))
+	})
+}
+
+func pageData(movieID uint32, page int) string {
+	return fmt.Sprintf("%s%d:%d", CallbackPrefix, movieID, page)
+}
+
+// Обработка кнопок «Галерея» и «Ещё»: отправка страницы изображений альбомом
+func HandleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	parts := strings.Split(strings.TrimPrefix(callback.Data, CallbackPrefix), ":")
+	if len(parts) != 2 {
+		return
+	}
+	movieID, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return
+	}
+	page, err := strconv.Atoi(parts[1])
+	if err != nil || page < 1 {
+		return
+	}
+	chatID := callback.Message.Chat.ID
+
+	cached, ok := cachedImages(uint32(movieID), page)
+	if !ok && api.RequestsToday() >= APIReserveThreshold {
+		sendMessage(bot, chatID, "Лимит запросов к Кинопоиску на сегодня почти исчерпан, галерея будет доступна завтра.")
+		return
+	}
+	if !ok {
+		var err error
+		if cached, err = loadPage(uint32(movieID), page); err != nil {
+			log.Println("Ошибка при получении изображений фильма:", err)
+			sendMessage(bot, chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+			return
+		}
+	}
+	images, pages, title := cached.images, cached.pages, cached.title
+	if len(images) == 0 {
+		text := "У этого фильма пока нет кадров и постеров."
+		if page > 1 {
+			text = "Больше изображений нет."
+		}
+		sendMessage(bot, chatID, text)
+		return
+	}
+
+	caption := fmt.Sprintf("🖼 %s: страница %d из %d", title, page, pages)
+
+	// Кнопка «Ещё» переезжает под новый альбом, у прежнего сообщения она убирается
+	if page > 1 && callback.Message.Photo == nil {
+		edit := tgbotapi.NewEditMessageText(chatID, callback.Message.MessageID,
+			fmt.Sprintf("🖼 %s: страница %d из %d", title, page-1, pages))
+		sender.Post(bot, edit)
+	}
+
+	urls := make([]string, len(images))
+	for i, image := range images {
+		urls[i] = image.URL
+	}
+	if err := sendAlbum(bot, chatID, urls, caption); err != nil {
+		log.Println("Ошибка при отправке галереи:", err)
+		sendMessage(bot, chatID, "Не удалось отправить изображения, попробуйте позже.")
+		return
+	}
+
+	if page < pages {
+		msg := tgbotapi.NewMessage(chatID, caption)
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Ещё ▶️", pageData(uint32(movieID), page+1)),
+		))
+		sender.Post(bot, msg)
+	}
+}
+
+// Страница изображений из кэша
+func cachedImages(movieID uint32, page int) (cachedPage, bool) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	entry, ok := cache[cacheKey(movieID, page)]
+	if !ok || time.Since(entry.cachedAt) > CacheTTL {
+		return cachedPage{}, false
+	}
+	return entry, true
+}
+
+// Загрузка страницы изображений через API и сохранение в кэш вместе с названием фильма
+func loadPage(movieID uint32, page int) (cachedPage, error) {
+	images, pages, err := api.RequestImages(api.BaseURL+"/image", movieID, page, PageSize)
+	if err != nil {
+		return cachedPage{}, err
+	}
+	entry := cachedPage{images: images, pages: pages, title: "Галерея", cachedAt: time.Now()}
+	if len(images) > 0 {
+		if movie, err := movies.GetMovie(movieID); err == nil && movie.Name != "" {
+			entry.title = fmt.Sprintf("Галерея «%s»", movie.Name)
+		}
+	}
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	key := cacheKey(movieID, page)
+	if _, ok := cache[key]; !ok && len(cache) >= MaxCachedPages {
+		evict()
+	}
+	cache[key] = entry
+	return entry, nil
+}
+
+func cacheKey(movieID uint32, page int) string {
+	return fmt.Sprintf("%d:%d", movieID, page)
+}
+
+// Освобождение места в кэше: устаревшие страницы и самая старая, вызывается под cacheMu
+func evict() {
+	oldestKey := ""
+	var oldest time.Time
+	for key, entry := range cache {
+		if time.Since(entry.cachedAt) > CacheTTL {
+			delete(cache, key)
+			continue
+		}
+		if oldest.IsZero() || entry.cachedAt.Before(oldest) {
+			oldestKey, oldest = key, entry.cachedAt
+		}
+	}
+	if len(cache) >= MaxCachedPages {
+		delete(cache, oldestKey)
+	}
+}
+
+// Отправка изображений альбомом. Альбом - от двух изображений, одно отправляется обычным фото.
+func sendAlbum(bot *tgbotapi.BotAPI, chatID int64, urls []string, caption string) error {
+	if len(urls) == 1 {
+		photo := tgbotapi.NewPhoto(chatID, nil)
+		photo.Caption = caption
+		_, err := media.SendPhoto(bot, photo, urls[0])
+		return err
+	}
+	_, err := media.SendAlbum(bot, chatID, urls, caption)
+	return err
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}
@@ -0,0 +1,160 @@
+package groups
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+)
+
+// Раздел хранилища с настройками чатов
+const settingsBucket = "chat_settings"
+
+// Настройки группового чата
+type Settings struct {
+	// Общий лимит запросов чата в день
+	DailyLimit int `json:"dailyLimit"`
+	// Удалять прошлый список фильмов бота при новом поиске
+	Cleanup bool `json:"cleanup"`
+}
+
+var defaultSettings = Settings{
+	DailyLimit: 50,
+	Cleanup:    true,
+}
+
+// Групповой чат или супергруппа
+func IsGroup(chat *tgbotapi.Chat) bool {
+	return chat != nil && (chat.IsGroup() || chat.IsSuperGroup())
+}
+
+// Получение настроек чата
+func GetSettings(chatID int64) Settings {
+	settings := defaultSettings
+	storage.Get(settingsBucket, strconv.FormatInt(chatID, 10), &settings)
+	return settings
+}
+
+// Сохранение настроек чата
+func SaveSettings(chatID int64, settings Settings) error {
+	return storage.Put(settingsBucket, strconv.FormatInt(chatID, 10), settings)
+}
+
+// Команда из сообщения, если она адресована этому боту
+func Command(bot *tgbotapi.BotAPI, message *tgbotapi.Message) string {
+	if !message.IsCommand() {
+		return ""
+	}
+	// Команда вида /film@other_bot предназначена другому боту
+	withAt := message.CommandWithAt()
+	if i := strings.Index(withAt, "@"); i >= 0 && !strings.EqualFold(withAt[i+1:], bot.Self.UserName) {
+		return ""
+	}
+	return message.Command()
+}
+
+// Поисковый запрос из сообщения в группе: команда /film, упоминание бота или ответ на его сообщение.
+// Возвращает false, если сообщение обращено не к боту.
+func ExtractQuery(bot *tgbotapi.BotAPI, message *tgbotapi.Message) (string, bool) {
+	if Command(bot, message) == "film" {
+		return strings.TrimSpace(message.CommandArguments()), true
+	}
+	if message.IsCommand() {
+		return "", false
+	}
+
+	if bot.Self.UserName != "" {
+		mention := "@" + bot.Self.UserName
+		var words []string
+		mentioned := false
+		for _, word := range strings.Fields(message.Text) {
+			if strings.EqualFold(strings.TrimRight(word, ",.:!?"), mention) {
+				mentioned = true
+				continue
+			}
+			words = append(words, word)
+		}
+		if mentioned {
+			return strings.Join(words, " "), true
+		}
+	}
+
+	reply := message.ReplyToMessage
+	if reply != nil && reply.From != nil && reply.From.ID == bot.Self.ID {
+		return strings.TrimSpace(message.Text), true
+	}
+	return "", false
+}
+
+// Является ли пользователь администратором чата
+func IsAdmin(bot *tgbotapi.BotAPI, chatID int64, userID int64) bool {
+	member, err := bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID},
+	})
+	if err != nil {
+		log.Println("Ошибка при получении участника чата:", err)
+		return false
+	}
+	return member.IsCreator() || member.IsAdministrator()
+}
+
+// Обработка команды /settings: без аргументов показывает настройки,
+// изменять их могут только администраторы чата
+func HandleSettingsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		sendMessage(bot, chatID, formatSettings(GetSettings(chatID)))
+		return
+	}
+
+	if !IsAdmin(bot, chatID, message.From.ID) {
+		sendMessage(bot, chatID, "Менять настройки могут только администраторы чата.")
+		return
+	}
+
+	settings := GetSettings(chatID)
+	switch {
+	case len(args) == 2 && args[0] == "limit":
+		limit, err := strconv.Atoi(args[1])
+		if err != nil || limit < 1 || limit > 200 {
+			sendMessage(bot, chatID, "Лимит должен быть числом от 1 до 200.")
+			return
+		}
+		settings.DailyLimit = limit
+	case len(args) == 2 && args[0] == "cleanup" && (args[1] == "on" || args[1] == "off"):
+		settings.Cleanup = args[1] == "on"
+	default:
+		sendMessage(bot, chatID, settingsUsage)
+		return
+	}
+
+	if err := SaveSettings(chatID, settings); err != nil {
+		log.Println("Ошибка при сохранении настроек чата:", err)
+		sendMessage(bot, chatID, "Не удалось сохранить настройки, попробуйте позже.")
+		return
+	}
+	sendMessage(bot, chatID, "Настройки обновлены.\n\n"+formatSettings(settings))
+}
+
+const settingsUsage = "Использование:\n" +
+	"/settings limit <1-200> - общий лимит запросов чата в день\n" +
+	"/settings cleanup on|off - удалять прошлый список фильмов бота"
+
+func formatSettings(settings Settings) string {
+	cleanup := "выключено"
+	if settings.Cleanup {
+		cleanup = "включено"
+	}
+	return fmt.Sprintf("⚙️ Настройки чата\n\nЛимит запросов в день: %d\nУдаление прошлого списка фильмов: %s\n\n%s",
+		settings.DailyLimit, cleanup, settingsUsage)
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}
@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/admin"
+	"github.com/luzhnov-aleksei/kinobot/compare"
+	"github.com/luzhnov-aleksei/kinobot/dialog"
+	"github.com/luzhnov-aleksei/kinobot/diary"
+	"github.com/luzhnov-aleksei/kinobot/digest"
+	"github.com/luzhnov-aleksei/kinobot/feedback"
+	"github.com/luzhnov-aleksei/kinobot/gallery"
+	"github.com/luzhnov-aleksei/kinobot/groups"
+	"github.com/luzhnov-aleksei/kinobot/history"
+	"github.com/luzhnov-aleksei/kinobot/limiter"
+	"github.com/luzhnov-aleksei/kinobot/movienight"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/notify"
+	"github.com/luzhnov-aleksei/kinobot/people"
+	"github.com/luzhnov-aleksei/kinobot/privacy"
+	"github.com/luzhnov-aleksei/kinobot/random"
+	"github.com/luzhnov-aleksei/kinobot/recommend"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/series"
+	"github.com/luzhnov-aleksei/kinobot/streaming"
+	"github.com/luzhnov-aleksei/kinobot/transfer"
+	"github.com/luzhnov-aleksei/kinobot/watchlist"
+)
+
+// Разделение обработки обновлений
+func HandleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	if update.Message != nil {
+		handleMessage(bot, update)
+	} else if update.CallbackQuery != nil {
+		// Подтверждаем нажатие, чтобы у пользователя пропали часики на кнопке
+		callback := tgbotapi.NewCallback(update.CallbackQuery.ID, "")
+		sender.Post(bot, callback)
+
+		callbackQuery := update.CallbackQuery
+		if admin.IsBanned(callbackQuery.From.ID) {
+			return
+		}
+		switch {
+		case strings.HasPrefix(callbackQuery.Data, movienight.CallbackPrefix):
+			movienight.HandleVote(bot, callbackQuery)
+		case strings.HasPrefix(callbackQuery.Data, watchlist.CallbackPrefix):
+			watchlist.HandleCallback(bot, callbackQuery)
+		case strings.HasPrefix(callbackQuery.Data, diary.CallbackPrefix):
+			diary.HandleCallback(bot, callbackQuery)
+		case strings.HasPrefix(callbackQuery.Data, notify.CallbackPrefix):
+			notify.HandleCallback(bot, callbackQuery)
+		case strings.HasPrefix(callbackQuery.Data, digest.CallbackPrefix):
+			digest.HandleCallback(bot, callbackQuery)
+		case strings.HasPrefix(callbackQuery.Data, admin.CallbackPrefix):
+			admin.HandleCallback(bot, callbackQuery)
+		case strings.HasPrefix(callbackQuery.Data, feedback.CallbackPrefix):
+			feedback.HandleCallback(bot, callbackQuery)
+		case strings.HasPrefix(callbackQuery.Data, transfer.CallbackPrefix):
+			transfer.HandleCallback(bot, callbackQuery)
+		case strings.HasPrefix(callbackQuery.Data, privacy.CallbackPrefix):
+			privacy.HandleCallback(bot, callbackQuery)
+		case strings.HasPrefix(callbackQuery.Data, gallery.CallbackPrefix):
+			gallery.HandleCallback(bot, callbackQuery)
+		case strings.HasPrefix(callbackQuery.Data, series.CallbackPrefix):
+			series.HandleCallback(bot, callbackQuery)
+		case strings.HasPrefix(callbackQuery.Data, compare.CallbackPrefix):
+			compare.HandleCallback(bot, callbackQuery)
+		case strings.HasPrefix(callbackQuery.Data, people.CallbackPrefix):
+			people.HandleCallback(bot, callbackQuery)
+		case strings.HasPrefix(callbackQuery.Data, streaming.CallbackPrefix):
+			streaming.HandleCallback(bot, callbackQuery)
+		case strings.HasPrefix(callbackQuery.Data, random.CallbackPrefix):
+			random.HandleCallback(bot, callbackQuery)
+		case strings.HasPrefix(callbackQuery.Data, movies.LayoutPrefix):
+			movies.HandleLayoutCallback(bot, callbackQuery)
+		default:
+			// Обработка выбора фильма из списка
+			movie := movies.HandleMovieSelection(bot, &update)
+			if movie != nil {
+				history.RecordSelection(callbackQuery.From.ID, movie)
+				movienight.HandleSelection(bot, callbackQuery.Message.Chat.ID, callbackQuery.From, movie)
+			}
+		}
+	}
+}
+
+// Разделение обработки сообщений
+func handleMessage(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	userID := update.Message.From.ID
+	// Заблокированным пользователям бот не отвечает
+	if admin.IsBanned(userID) {
+		return
+	}
+	admin.RecordActivity(update.Message.From, update.Message.Chat)
+
+	firstName := update.Message.From.FirstName
+	username := update.Message.From.UserName
+
+	if firstName == "" {
+		firstName = "друг"
+	}
+
+	if username == "" {
+		username = "username отсутствует"
+	}
+
+	// Обработка новых участников чата
+	if update.Message.NewChatMembers != nil {
+		for _, member := range update.Message.NewChatMembers {
+			log.Printf("New user authorized: %s (@%s)", member.FirstName, member.UserName)
+		}
+	}
+
+	// Ответ администратора на обращение пользователя
+	if feedback.HandleAdminReply(bot, update.Message) {
+		return
+	}
+
+	// Ответ на сообщение об оценке - заметка в дневник
+	if diary.HandleNoteReply(bot, update.Message) {
+		return
+	}
+
+	// Ответ в активном многошаговом диалоге идёт в диалог, а не в поиск фильмов.
+	// Лимит не расходуется: пожаловаться на лимит должно быть можно и после его исчерпания
+	if dialog.Handle(bot, update.Message) {
+		return
+	}
+
+	// В группах бот отвечает только на обращённые к нему сообщения
+	if groups.IsGroup(update.Message.Chat) {
+		handleGroupMessage(bot, update, firstName, username)
+		return
+	}
+
+	// Команды администратора, обращения к разработчикам и управление своими данными не расходуют лимит
+	if admin.HandleCommand(bot, update.Message) {
+		return
+	}
+	switch update.Message.Command() {
+	case "feedback":
+		feedback.HandleCommand(bot, update.Message)
+		return
+	case "mydata":
+		privacy.HandleMyDataCommand(bot, update.Message)
+		return
+	case "forget":
+		privacy.HandleForgetCommand(bot, update.Message)
+		return
+	case "services":
+		streaming.HandleCommand(bot, update.Message)
+		return
+	case "cancel":
+		// Активный диалог /cancel завершает выше, а без него это не поисковый запрос
+		sendMessage(bot, update.Message.Chat.ID, "Нечего отменять.")
+		return
+	}
+
+	// Проверка на лимит сообщений
+	if !limiter.CanSendMessage(userID) {
+		handleMessageLimit(bot, update.Message.Chat.ID, int(userID), username)
+		return
+	}
+
+	limiter.IncrementMessageCount(userID)
+
+	// Обработка команд
+	switch update.Message.Command() {
+	case "start":
+		handleStartCommand(bot, update, firstName)
+	case "help":
+		handleHelpCommand(bot, update)
+	case "movienight":
+		movienight.HandleCommand(bot, update.Message)
+	case "list":
+		watchlist.HandleListCommand(bot, update.Message)
+	case "random":
+		random.HandleCommand(bot, update.Message)
+	case "diary":
+		diary.HandleDiaryCommand(bot, update.Message)
+	case "stats":
+		diary.HandleStatsCommand(bot, update.Message)
+	case "recommend":
+		recommend.HandleRecommendCommand(bot, update.Message)
+	case "compare":
+		compare.HandleCommand(bot, update.Message)
+	case "person":
+		people.HandleCommand(bot, update.Message)
+	case "digest":
+		digest.HandleCommand(bot, update.Message)
+	case "export":
+		transfer.HandleExportCommand(bot, update.Message)
+	case "import":
+		transfer.HandleImportCommand(bot, update.Message)
+	default:
+		handleMovieSearch(bot, update, update.Message.Text)
+	}
+}
+
+// Обработка сообщений в групповом чате: команды, упоминания бота и ответы на его сообщения
+func handleGroupMessage(bot *tgbotapi.BotAPI, update tgbotapi.Update, firstName string, username string) {
+	message := update.Message
+	chatID := message.Chat.ID
+	command := groups.Command(bot, message)
+	query, isSearch := groups.ExtractQuery(bot, message)
+
+	// Команды без запросов к API не расходуют лимит, иначе админ не сможет его поднять
+	switch command {
+	case "settings":
+		groups.HandleSettingsCommand(bot, message)
+		return
+	case "movienight":
+		movienight.HandleCommand(bot, message)
+		return
+	case "list":
+		watchlist.HandleListCommand(bot, message)
+		return
+	case "random":
+		// Выбор из списка не обращается к API, подбор по условиям расходует лимит
+		if random.FromList(message.CommandArguments()) {
+			random.HandleCommand(bot, message)
+			return
+		}
+	case "diary":
+		diary.HandleDiaryCommand(bot, message)
+		return
+	case "stats":
+		diary.HandleStatsCommand(bot, message)
+		return
+	case "digest":
+		digest.HandleCommand(bot, message)
+		return
+	case "feedback":
+		feedback.HandleCommand(bot, message)
+		return
+	case "export", "import":
+		sendMessage(bot, chatID, "Экспорт и импорт доступны в личке с ботом.")
+		return
+	case "mydata":
+		privacy.HandleMyDataCommand(bot, message)
+		return
+	case "forget":
+		privacy.HandleForgetCommand(bot, message)
+		return
+	case "services":
+		streaming.HandleCommand(bot, message)
+		return
+	case "cancel":
+		sendMessage(bot, chatID, "Нечего отменять.")
+		return
+	}
+	if !isSearch && command != "start" && command != "help" && command != "recommend" && command != "compare" && command != "person" && command != "random" {
+		return
+	}
+
+	// Лимит в группе общий для всего чата
+	limiter.SetLimit(chatID, groups.GetSettings(chatID).DailyLimit)
+	if !limiter.CanSendMessage(chatID) {
+		handleGroupLimit(bot, chatID, username)
+		return
+	}
+
+	limiter.IncrementMessageCount(chatID)
+
+	switch {
+	case command == "start":
+		handleStartCommand(bot, update, firstName)
+	case command == "help":
+		handleHelpCommand(bot, update)
+	case command == "recommend":
+		recommend.HandleRecommendCommand(bot, message)
+	case command == "compare":
+		compare.HandleCommand(bot, message)
+	case command == "person":
+		people.HandleCommand(bot, message)
+	case command == "random":
+		random.HandleCommand(bot, message)
+	case query == "":
+		sendMessage(bot, chatID, "Напишите запрос после команды, например: /film Интерстеллар")
+	default:
+		handleMovieSearch(bot, update, query)
+	}
+}
+
+// Обработка превышения лимита сообщений
+func handleMessageLimit(bot *tgbotapi.BotAPI, chatID int64, userID int, username string) {
+	log.Printf("Пользователь [%d] с username [@%s] превысил лимит сообщений", userID, username)
+	msg := tgbotapi.NewMessage(chatID, "Вы превысили лимит сообщений на сегодня. Попробуйте снова завтра.")
+	sender.Post(bot, msg)
+}
+
+// Обработка превышения общего лимита группы
+func handleGroupLimit(bot *tgbotapi.BotAPI, chatID int64, username string) {
+	log.Printf("Чат [%d] исчерпал лимит запросов, последний запрос от [@%s]", chatID, username)
+	sendMessage(bot, chatID, "Чат исчерпал общий лимит запросов на сегодня. Попробуйте снова завтра.")
+}
+
+// Обработка команды /start
+func handleStartCommand(bot *tgbotapi.BotAPI, update tgbotapi.Update, firstName string) {
+	// Ссылка на человека из карточки фильма
+	if people.HandleStart(bot, update.Message) {
+		return
+	}
+	commonMsg := getCommonMessage()
+	msgText := fmt.Sprintf("Привет, %s👋👋👋\n\n", firstName) + commonMsg
+	sendMessage(bot, update.Message.Chat.ID, msgText)
+}
+
+// Обработка команды /help
+func handleHelpCommand(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
+	commonMsg := getCommonMessage()
+	sendMessage(bot, update.Message.Chat.ID, commonMsg)
+}
+
+// Получение общего сообщения
+func getCommonMessage() string {
+	return "🤖 Это кинобот-помощник для создания списка фильмов и сериалов, которые ты планируешь посмотреть.\n\n" +
+		"✏️ Просто напиши боту запрос, выбери нужный фильм и бот выдаст информацию о нем.\n\n" +
+		"🔎 Поиск работает по названию, жанру, году. Также можно это комбинировать\n\n" +
+		"📽️ Бот может искать всё, что есть на Кинопоиске: фильмы, мультфильмы, сериалы, аниме и т.д.\n\n" +
+		"⭐ После просмотра оцени фильм кнопкой «⭐ Оценить» на карточке: /diary - дневник просмотров, /stats - статистика, /recommend - подборка по твоему вкусу, /digest - дайджест премьер и новинок по расписанию.\n\n" +
+		"👤 /person Кристофер Нолан найдёт актёра или режиссёра и покажет его фильмографию, а имя, написанное боту, найдётся и без команды.\n\n" +
+		"🎲 Не знаешь, что посмотреть? /random комедия 2000-2010 кп7 до120 подберёт случайный фильм по жанру, годам, рейтингу, типу и длительности, а кнопка «🎲 Другой» предложит следующий без повторов.\n\n" +
+		"⚖️ Не можешь выбрать? /compare Интерстеллар; Начало покажет фильмы рядом в одной таблице.\n\n" +
+		"🍿 В группе можно устроить киновечер: /movienight соберёт кандидатов и проведёт голосование.\n\n" +
+		"📝 Личку бота можно использовать как записную книгу с фильмами: кнопка «➕ В список» на карточке добавляет фильм в список /list, а /random выбирает из него случайный. О премьерах и новых сезонах фильмов из списка бот напомнит сам.\n\n" +
+		"🍿 Под карточкой есть ссылки на онлайн-кинотеатры, где идёт фильм. Отметь свои подписки в /services - они будут первыми, а бот напомнит, когда фильм из списка появится в одной из них.\n\n" +
+		"📦 /export выгрузит список и дневник в CSV и JSON (и в формате Letterboxd), а /import загрузит их обратно или перенесёт оценки из Letterboxd и IMDb.\n\n" +
+		"💬 Или добавь бота в любой чат: там он ищет фильмы по команде /film, упоминанию или ответу на его сообщение👍\n\n" +
+		"🔐 /mydata покажет, что бот хранит о тебе, а /forget удалит эти данные.\n\n" +
+		"🤔 Если возникнут вопросы или проблемы с ботом, то отправь /feedback - сообщение попадёт к разработчикам, а ответ придёт сюда"
+}
+
+// Обработка поиска фильмов
+func handleMovieSearch(bot *tgbotapi.BotAPI, update tgbotapi.Update, query string) {
+	// Имя и текст запроса в журнал не пишутся: у журнала процесса нет срока хранения
+	log.Printf("Поисковый запрос от пользователя [%d]", update.Message.From.ID)
+	admin.RecordSearch()
+	history.RecordQuery(update.Message.From.ID, query)
+	// GIF и результаты отправляются без ожидания, чтобы поиск в одном чате не задерживал остальные
+	chatID := update.Message.Chat.ID
+	animationID := make(chan int, 1)
+	animation := tgbotapi.NewAnimation(chatID, tgbotapi.FileURL("https://media1.tenor.com/m/RVvnVPK-6dcAAAAd/reload-cat.gif"))
+	sender.PostThen(bot, animation, func(sent tgbotapi.Message, err error) {
+		if err != nil {
+			log.Printf("Не удалось отправить GIF: %v", err)
+			return
+		}
+		animationID <- sent.MessageID
+	})
+
+	movies.HandleMovieSearch(bot, &update, query)
+
+	// Удаляем GIF после результатов: очередь чата доходит до удаления, когда GIF уже отправлен
+	sender.PostDeferred(bot, chatID, func() tgbotapi.Chattable {
+		select {
+		case id := <-animationID:
+			return tgbotapi.NewDeleteMessage(chatID, id)
+		default:
+			return nil
+		}
+	})
+}
+
+// Функция для отправки сообщений
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, strings.TrimSpace(text))
+	sender.Post(bot, msg)
+}
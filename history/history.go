@@ -0,0 +1,126 @@
+package history
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+)
+
+// Раздел хранилища с историей пользователей, ключ - ID пользователя
+const bucket = "history"
+
+// Сколько последних выборов хранится
+const maxSelections = 50
+
+// Фильм, открытый пользователем из результатов поиска
+type Selection struct {
+	Movie api.Cinema `json:"movie"`
+	At    time.Time  `json:"at"`
+}
+
+// История действий пользователя
+type History struct {
+	UserID     int64       `json:"userId"`
+	Selections []Selection `json:"selections"`
+	// Последний поисковый запрос
+	LastQuery   string    `json:"lastQuery,omitempty"`
+	LastQueryAt time.Time `json:"lastQueryAt,omitempty"`
+}
+
+var mu sync.Mutex
+
+// История пользователя (пустая, если её ещё нет)
+func Load(userID int64) *History {
+	history := &History{UserID: userID}
+	storage.Get(bucket, strconv.FormatInt(userID, 10), history)
+	return history
+}
+
+// Запоминание выбранного фильма, старые выборы вытесняются
+func RecordSelection(userID int64, movie *api.Cinema) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	history := Load(userID)
+	selection := Selection{Movie: *movie, At: time.Now()}
+	// Описание для профиля вкуса не нужно, а места занимает много
+	selection.Movie.Description = ""
+	selection.Movie.ShortDescription = ""
+	history.Selections = append(history.Selections, selection)
+	if len(history.Selections) > maxSelections {
+		history.Selections = history.Selections[len(history.Selections)-maxSelections:]
+	}
+
+	if err := storage.Put(bucket, strconv.FormatInt(userID, 10), history); err != nil {
+		log.Println("Ошибка при сохранении истории:", err)
+	}
+}
+
+// Удаление истории пользователя
+func Forget(userID int64) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return storage.Delete(bucket, strconv.FormatInt(userID, 10))
+}
+
+// Удаление выборов и поисковых запросов старше before. Возвращает число изменённых историй.
+func Purge(before time.Time) int {
+	mu.Lock()
+	defer mu.Unlock()
+
+	purged := 0
+	for _, key := range storage.Keys(bucket) {
+		var history History
+		if !storage.Get(bucket, key, &history) {
+			continue
+		}
+		changed := false
+		var kept []Selection
+		for _, selection := range history.Selections {
+			if selection.At.Before(before) {
+				changed = true
+				continue
+			}
+			kept = append(kept, selection)
+		}
+		history.Selections = kept
+		if history.LastQuery != "" && history.LastQueryAt.Before(before) {
+			history.LastQuery, history.LastQueryAt = "", time.Time{}
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+
+		var err error
+		if len(history.Selections) == 0 && history.LastQuery == "" {
+			err = storage.Delete(bucket, key)
+		} else {
+			err = storage.Put(bucket, key, &history)
+		}
+		if err != nil {
+			log.Println("Ошибка при очистке истории:", err)
+			continue
+		}
+		purged++
+	}
+	return purged
+}
+
+// Запоминание последнего поискового запроса. Его не страшно потерять, поэтому он пишется
+// на диск отложенно, а не переписывает хранилище на каждом поиске.
+func RecordQuery(userID int64, query string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	history := Load(userID)
+	history.LastQuery = query
+	history.LastQueryAt = time.Now()
+	if err := storage.PutLazy(bucket, strconv.FormatInt(userID, 10), history); err != nil {
+		log.Println("Ошибка при сохранении истории:", err)
+	}
+}
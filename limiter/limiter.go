@@ -14,6 +14,21 @@ type UserMessageInfo struct {
 
 var userMessages = make(map[int64]*UserMessageInfo)
 
+// Индивидуальные лимиты, отличные от maxMessagesPerDay (например, общий лимит группы)
+var customLimits = make(map[int64]int)
+
+// Установка индивидуального лимита сообщений в день
+func SetLimit(id int64, limit int) {
+	customLimits[id] = limit
+}
+
+func limitFor(id int64) int {
+	if limit, ok := customLimits[id]; ok {
+		return limit
+	}
+	return maxMessagesPerDay
+}
+
 func CanSendMessage(userID int64) bool {
 	userInfo, exists := userMessages[userID]
 	if !exists {
@@ -29,10 +44,24 @@ func CanSendMessage(userID int64) bool {
 		userInfo.ResetAt = time.Now().Add(24 * time.Hour)
 	}
 
-	return userInfo.Count < maxMessagesPerDay
+	return userInfo.Count < limitFor(userID)
 }
 
 func IncrementMessageCount(userID int64) {
 	userInfo := userMessages[userID]
 	userInfo.Count++
 }
+
+// Использование лимита: сколько сообщений отправлено за сутки и сколько разрешено
+func Usage(id int64) (int, int) {
+	count := 0
+	if userInfo, exists := userMessages[id]; exists && time.Now().Before(userInfo.ResetAt) {
+		count = userInfo.Count
+	}
+	return count, limitFor(id)
+}
+
+// Сброс счётчика сообщений до следующих суток
+func Reset(id int64) {
+	delete(userMessages, id)
+}
@@ -1,14 +1,22 @@
 package main
 
 import (
-	"fmt"
 	"log"
 	"os"
-	"strings"
+	"strconv"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	"github.com/luzhnov-aleksei/kinobot/limiter"
+	"github.com/luzhnov-aleksei/kinobot/admin"
+	"github.com/luzhnov-aleksei/kinobot/digest"
+	"github.com/luzhnov-aleksei/kinobot/feedback"
+	"github.com/luzhnov-aleksei/kinobot/handlers"
+	"github.com/luzhnov-aleksei/kinobot/movienight"
 	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/notify"
+	"github.com/luzhnov-aleksei/kinobot/privacy"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
 )
 
 func main() {
@@ -17,136 +25,71 @@ func main() {
 		log.Fatal("BOT_KEY environment variable is not set")
 	}
 
-	bot, err := tgbotapi.NewBotAPI(botKey)
-	if err != nil {
-		log.Fatalf("Failed to authorize bot. Error: %v. This might be due to VPN issues.", err)
+	storagePath := os.Getenv("STORAGE_PATH")
+	if storagePath == "" {
+		storagePath = "kinobot.json"
 	}
-
-	bot.Debug = false
-	log.Printf("Authorized on account %s", bot.Self.UserName)
-
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-	updates := bot.GetUpdatesChan(u)
-
-	// Обработка всех обновлений
-	for update := range updates {
-		handleUpdate(bot, update)
-	}
-}
-
-// Разделение обработки обновлений
-func handleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
-	if update.Message != nil {
-		handleMessage(bot, update)
-	} else if update.CallbackQuery != nil {
-		// Обработка выбора фильма из списка
-		movies.HandleMovieSelection(bot, &update)
+	if err := storage.Open(storagePath); err != nil {
+		log.Fatalf("Failed to open storage %s: %v", storagePath, err)
 	}
-}
-
-// Разделение обработки сообщений
-func handleMessage(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
-	userID := update.Message.From.ID
-	firstName := update.Message.From.FirstName
-	username := update.Message.From.UserName
 
-	if firstName == "" {
-		firstName = "друг"
-	}
-
-	if username == "" {
-		username = "username отсутствует"
+	adminIDs, err := admin.ParseIDs(os.Getenv("ADMIN_IDS"))
+	if err != nil {
+		log.Fatalf("Failed to parse ADMIN_IDS: %v", err)
 	}
+	admin.SetAdmins(adminIDs)
 
-	// Обработка новых участников чата
-	if update.Message.NewChatMembers != nil {
-		for _, member := range update.Message.NewChatMembers {
-			log.Printf("New user authorized: %s (@%s)", member.FirstName, member.UserName)
+	// Чат администраторов для обращений пользователей
+	if chatID := os.Getenv("FEEDBACK_CHAT_ID"); chatID != "" {
+		feedback.ChatID, err = strconv.ParseInt(chatID, 10, 64)
+		if err != nil {
+			log.Fatalf("Failed to parse FEEDBACK_CHAT_ID: %v", err)
 		}
 	}
 
-	// Проверка на лимит сообщений
-	if !limiter.CanSendMessage(userID) {
-		handleMessageLimit(bot, update.Message.Chat.ID, int(userID), username)
-		return
+	// Журнал сообщений, которые не удалось доставить
+	sender.DeadLetterPath = os.Getenv("DEAD_LETTER_PATH")
+	if sender.DeadLetterPath == "" {
+		sender.DeadLetterPath = "dead_letters.jsonl"
 	}
 
-	limiter.IncrementMessageCount(userID)
-
-	// Обработка команд /start и /help
-	switch update.Message.Text {
-	case "/start":
-		handleStartCommand(bot, update, firstName)
-	case "/help":
-		handleHelpCommand(bot, update)
-	default:
-		handleMovieSearch(bot, update)
+	// Сроки хранения истории поиска и журналов
+	if days := os.Getenv("HISTORY_RETENTION_DAYS"); days != "" {
+		if privacy.HistoryRetention, err = privacy.ParseRetention(days); err != nil {
+			log.Fatalf("Failed to parse HISTORY_RETENTION_DAYS: %v", err)
+		}
 	}
-}
-
-// Обработка превышения лимита сообщений
-func handleMessageLimit(bot *tgbotapi.BotAPI, chatID int64, userID int, username string) {
-	log.Printf("Пользователь [%d] с username [@%s] превысил лимит сообщений", userID, username)
-	msg := tgbotapi.NewMessage(chatID, "Вы превысили лимит сообщений на сегодня. Попробуйте снова завтра.")
-	if _, err := bot.Send(msg); err != nil {
-		log.Println("Ошибка при отправке сообщения из-за лимита на пользователя:", err)
+	if days := os.Getenv("LOG_RETENTION_DAYS"); days != "" {
+		if privacy.LogRetention, err = privacy.ParseRetention(days); err != nil {
+			log.Fatalf("Failed to parse LOG_RETENTION_DAYS: %v", err)
+		}
 	}
-}
-
-// Обработка команды /start
-func handleStartCommand(bot *tgbotapi.BotAPI, update tgbotapi.Update, firstName string) {
-	commonMsg := getCommonMessage()
-	msgText := fmt.Sprintf("Привет, %s👋👋👋\n\n", firstName) + commonMsg
-	sendMessage(bot, update.Message.Chat.ID, msgText)
-}
 
-// Обработка команды /help
-func handleHelpCommand(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
-	commonMsg := getCommonMessage()
-	sendMessage(bot, update.Message.Chat.ID, commonMsg)
-}
-
-// Получение общего сообщения
-func getCommonMessage() string {
-	return "🤖 Это кинобот-помощник для создания списка фильмов и сериалов, которые ты планируешь посмотреть.\n\n" +
-		"✏️ Просто напиши боту запрос, выбери нужный фильм и бот выдаст информацию о нем.\n\n" +
-		"🔎 Поиск работает по названию, жанру, году. Также можно это комбинировать\n\n" +
-		"📽️ Бот может искать всё, что есть на Кинопоиске: фильмы, мультфильмы, сериалы, аниме и т.д.\n\n" +
-		"📝 Личку бота можно использовать как записную книгу с фильмами.\n\n" +
-		"💬 Или добавь бота в любой чат, дай ему админку, и он будет присылать туда фильмы по вашим запросам👍\n\n" +
-		"🤔 Если возникнут вопросы или проблемы с ботом, то напиши разработчику @luzhnov_aleksei"
-}
-
-// Обработка поиска фильмов
-func handleMovieSearch(bot *tgbotapi.BotAPI, update tgbotapi.Update) {
-	log.Printf("Получено сообщение от пользователя [%d] с username [@%s]: %s", update.Message.From.ID, update.Message.From.UserName, update.Message.Text)
-	animation := tgbotapi.NewAnimation(update.Message.Chat.ID, tgbotapi.FileURL("https://media1.tenor.com/m/RVvnVPK-6dcAAAAd/reload-cat.gif"))
-	animationMsg, err := bot.Send(animation)
+	bot, err := tgbotapi.NewBotAPI(botKey)
 	if err != nil {
-		log.Printf("Не удалось отправить GIF: %v", err)
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "🔄 Идет поиск... Пожалуйста, подождите.")
-		if _, err := bot.Send(msg); err != nil {
-			log.Println("Ошибка при отправке сообщения из-за отсутствия gif:", err)
-		}
-		movies.HandleMovieSearch(bot, &update)
-		return
+		log.Fatalf("Failed to authorize bot. Error: %v. This might be due to VPN issues.", err)
 	}
 
-	// Обрабатываем запрос фильма после успешной отправки GIF
-	movies.HandleMovieSearch(bot, &update)
+	bot.Debug = false
+	log.Printf("Authorized on account %s", bot.Self.UserName)
+	// Ссылки на карточки людей ведут в личку с ботом
+	movies.BotUserName = bot.Self.UserName
+
+	// Закрытие голосований киновечеров по дедлайну
+	go movienight.Run(bot, 30*time.Second)
+	// Уведомления о премьерах и новых сезонах фильмов из списков
+	go notify.Run(bot, time.Hour)
+	// Дайджесты по расписанию подписчиков
+	go digest.Run(bot, time.Minute)
+	// Очистка устаревшей истории поиска и журналов
+	go privacy.Run(24 * time.Hour)
 
-	// Удаляем GIF после обработки запроса
-	deleteMessage := tgbotapi.NewDeleteMessage(update.Message.Chat.ID, animationMsg.MessageID)
-	if _, deleteErr := bot.Request(deleteMessage); deleteErr != nil {
-		log.Println("Ошибка при удалении GIF сообщения:", deleteErr)
-	}
-}
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 60
+	updates := bot.GetUpdatesChan(u)
 
-// Функция для отправки сообщений
-func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
-	msg := tgbotapi.NewMessage(chatID, strings.TrimSpace(text))
-	if _, err := bot.Send(msg); err != nil {
-		log.Println("Ошибка при отправке сообщения:", err)
+	// Обработка всех обновлений
+	for update := range updates {
+		handlers.HandleUpdate(bot, update)
 	}
 }
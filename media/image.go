@@ -0,0 +1,139 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Ограничения Telegram на фото: размер файла, сумма ширины и высоты, соотношение сторон
+const (
+	MaxPhotoBytes  = 10 << 20
+	MaxPhotoSides  = 10000
+	MaxAspectRatio = 20
+)
+
+var (
+	// Длинная сторона изображения после уменьшения. Больше Telegram всё равно не покажет.
+	MaxSide = 2560
+	// Изображения с большим числом пикселей не декодируются: в памяти такое заняло бы сотни мегабайт
+	MaxPixels = 25_000_000
+)
+
+// Не скачивать файлы больше этого размера
+const maxDownloadBytes = 20 << 20
+
+// HTTP-клиент для скачивания изображений, в тестах подменяется
+var Client = &http.Client{Timeout: 20 * time.Second}
+
+// Скачивание изображения и подготовка к загрузке в Telegram.
+// Возвращает содержимое файла и имя с подходящим расширением.
+func Download(url string) ([]byte, string, error) {
+	res, err := Client.Get(url)
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка при скачивании изображения: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("ошибка при скачивании изображения: %s", res.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxDownloadBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка при скачивании изображения: %v", err)
+	}
+	if len(data) > maxDownloadBytes {
+		return nil, "", errors.New("ошибка при скачивании изображения: файл больше 20 МБ")
+	}
+	return Prepare(data)
+}
+
+// Проверка изображения по ограничениям Telegram. Подходящие JPEG и PNG возвращаются как есть,
+// остальные форматы и слишком большие изображения уменьшаются и перекодируются в JPEG.
+func Prepare(data []byte) ([]byte, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка при разборе изображения: %v", err)
+	}
+	if config.Width == 0 || config.Height == 0 {
+		return nil, "", errors.New("ошибка при разборе изображения: пустое изображение")
+	}
+	long, short := max(config.Width, config.Height), min(config.Width, config.Height)
+	if long > short*MaxAspectRatio {
+		return nil, "", fmt.Errorf("изображение %dx%d слишком вытянутое для Telegram", config.Width, config.Height)
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, "", fmt.Errorf("изображение %dx%d слишком большое", config.Width, config.Height)
+	}
+
+	fits := len(data) <= MaxPhotoBytes && config.Width+config.Height <= MaxPhotoSides && long <= MaxSide
+	if fits && (format == "jpeg" || format == "png") {
+		if format == "jpeg" {
+			return data, "image.jpg", nil
+		}
+		return data, "image.png", nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("ошибка при разборе изображения: %v", err)
+	}
+	if long > MaxSide {
+		img = resize(img, MaxSide)
+	}
+
+	// Качество снижается, пока файл не уложится в лимит
+	for _, quality := range []int{90, 75, 60} {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, "", fmt.Errorf("ошибка при кодировании изображения: %v", err)
+		}
+		if buf.Len() <= MaxPhotoBytes {
+			return buf.Bytes(), "image.jpg", nil
+		}
+	}
+	return nil, "", errors.New("изображение не удалось ужать до 10 МБ")
+}
+
+// Уменьшение изображения так, чтобы длинная сторона стала равна side.
+// Цвет пикселя - среднее по попавшей в него области исходного изображения,
+// пиксели читаются прямо из исходного изображения без полноразмерной копии.
+func resize(src image.Image, side int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := side, h*side/w
+	if h > w {
+		dw, dh = w*side/h, side
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					b += uint64(pb)
+					a += uint64(pa)
+					n++
+				}
+			}
+			// RGBA() возвращает 16-битные значения
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n >> 8), uint8(g / n >> 8), uint8(b / n >> 8), uint8(a / n >> 8)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,226 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+)
+
+// Раздел хранилища с file_id изображений, уже загруженных в Telegram, ключ - URL изображения
+const bucket = "media"
+
+// Сколько file_id хранится. При переполнении удаляются самые старые, их изображения
+// просто загрузятся по URL ещё раз.
+var MaxCachedFiles = 5000
+
+// Сохранённый file_id изображения
+type cachedFile struct {
+	FileID string    `json:"fileId"`
+	At     time.Time `json:"at"`
+}
+
+// Ответы Telegram, после которых стоит загрузить изображение по-другому:
+// file_id устарел, CDN не отдал картинку по URL или она не подошла по формату
+var mediaErrors = []string{
+	"wrong file identifier",
+	"failed to get HTTP URL content",
+	"wrong type of the web page content",
+	"IMAGE_PROCESS_FAILED",
+	"PHOTO_INVALID_DIMENSIONS",
+	"PHOTO_SAVE_FILE_INVALID",
+	"WEBPAGE_CURL_FAILED",
+	"WEBPAGE_MEDIA_EMPTY",
+}
+
+// Ошибка загрузки изображения, а не доставки сообщения
+func IsMediaError(err error) bool {
+	var tgErr *tgbotapi.Error
+	if !errors.As(err, &tgErr) || tgErr.Code != 400 {
+		return false
+	}
+	for _, text := range mediaErrors {
+		if strings.Contains(tgErr.Message, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Файл для отправки изображения: сохранённый file_id или URL
+func File(url string) tgbotapi.RequestFileData {
+	if fileID, ok := cachedID(url); ok {
+		return tgbotapi.FileID(fileID)
+	}
+	return tgbotapi.FileURL(url)
+}
+
+// Число сохранённых file_id
+func CacheSize() int {
+	return len(storage.Keys(bucket))
+}
+
+func cachedID(url string) (string, bool) {
+	var cached cachedFile
+	if !storage.Get(bucket, url, &cached) || cached.FileID == "" {
+		return "", false
+	}
+	return cached.FileID, true
+}
+
+// Запоминание file_id самого большого размера фото
+func remember(url string, photo []tgbotapi.PhotoSize) {
+	if url == "" || len(photo) == 0 {
+		return
+	}
+	// Кэш пишется на диск отложенно, чтобы каждая карточка не перезаписывала всё хранилище
+	cached := cachedFile{FileID: photo[len(photo)-1].FileID, At: time.Now()}
+	if err := storage.PutLazy(bucket, url, cached); err != nil {
+		log.Println("Ошибка при сохранении file_id изображения:", err)
+	}
+	evict()
+}
+
+func forget(url string) {
+	if err := storage.DeleteLazy(bucket, url); err != nil {
+		log.Println("Ошибка при удалении file_id изображения:", err)
+	}
+}
+
+// Удаление самых старых file_id, если их больше MaxCachedFiles. Удаляется сразу десятая
+// часть, чтобы не перебирать раздел на каждой новой карточке.
+func evict() {
+	keys := storage.Keys(bucket)
+	if len(keys) <= MaxCachedFiles {
+		return
+	}
+	type entry struct {
+		url string
+		at  time.Time
+	}
+	entries := make([]entry, 0, len(keys))
+	for _, key := range keys {
+		var cached cachedFile
+		storage.Get(bucket, key, &cached)
+		entries = append(entries, entry{key, cached.At})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].at.Before(entries[j].at) })
+	for _, e := range entries[:len(entries)-MaxCachedFiles*9/10] {
+		forget(e.url)
+	}
+}
+
+// Отправка фото по URL. Сначала используется сохранённый file_id, затем URL,
+// а если Telegram не смог загрузить изображение сам - бот скачивает его и загружает файлом.
+// После отправки file_id запоминается для следующих карточек.
+func SendPhoto(bot *tgbotapi.BotAPI, photo tgbotapi.PhotoConfig, url string) (tgbotapi.Message, error) {
+	if fileID, ok := cachedID(url); ok {
+		photo.File = tgbotapi.FileID(fileID)
+		message, err := sender.SendHandled(bot, photo, IsMediaError)
+		if err == nil || !IsMediaError(err) {
+			return message, err
+		}
+		log.Printf("Сохранённый file_id изображения %s не подошёл: %v", url, err)
+		forget(url)
+	}
+
+	photo.File = tgbotapi.FileURL(url)
+	message, err := sender.SendHandled(bot, photo, IsMediaError)
+	if err == nil {
+		remember(url, message.Photo)
+		return message, nil
+	}
+	if !IsMediaError(err) {
+		return message, err
+	}
+	log.Printf("Telegram не загрузил изображение %s, загружаем файлом: %v", url, err)
+
+	data, name, downloadErr := Download(url)
+	if downloadErr != nil {
+		return message, fmt.Errorf("%v; %v", err, downloadErr)
+	}
+	photo.File = tgbotapi.FileBytes{Name: name, Bytes: data}
+	message, err = sender.Send(bot, photo)
+	if err == nil {
+		remember(url, message.Photo)
+	}
+	return message, err
+}
+
+// Отправка альбома изображений по URL с подписью у первого. Как и SendPhoto,
+// использует сохранённые file_id, а при отказе Telegram загружает изображения файлами.
+// Изображения, которые не удалось скачать, в повторный альбом не попадают.
+func SendAlbum(bot *tgbotapi.BotAPI, chatID int64, urls []string, caption string) ([]tgbotapi.Message, error) {
+	files := make([]tgbotapi.RequestFileData, len(urls))
+	for i, url := range urls {
+		files[i] = File(url)
+	}
+	messages, err := sender.SendMediaGroup(bot, album(chatID, files, caption), IsMediaError)
+	if err == nil {
+		rememberAlbum(urls, messages)
+		return messages, nil
+	}
+	if !IsMediaError(err) {
+		return messages, err
+	}
+	log.Printf("Telegram не загрузил альбом в чат [%d], загружаем файлами: %v", chatID, err)
+
+	var uploaded []string
+	files = files[:0]
+	for _, url := range urls {
+		forget(url)
+		data, name, downloadErr := Download(url)
+		if downloadErr != nil {
+			log.Println(downloadErr)
+			continue
+		}
+		uploaded = append(uploaded, url)
+		files = append(files, tgbotapi.FileBytes{Name: name, Bytes: data})
+	}
+	switch len(files) {
+	case 0:
+		return nil, err
+	case 1:
+		photo := tgbotapi.NewPhoto(chatID, files[0])
+		photo.Caption = caption
+		message, err := sender.Send(bot, photo)
+		if err != nil {
+			return nil, err
+		}
+		remember(uploaded[0], message.Photo)
+		return []tgbotapi.Message{message}, nil
+	}
+	messages, err = sender.SendMediaGroup(bot, album(chatID, files, caption), nil)
+	if err == nil {
+		rememberAlbum(uploaded, messages)
+	}
+	return messages, err
+}
+
+func album(chatID int64, files []tgbotapi.RequestFileData, caption string) tgbotapi.MediaGroupConfig {
+	media := make([]interface{}, 0, len(files))
+	for i, file := range files {
+		photo := tgbotapi.NewInputMediaPhoto(file)
+		if i == 0 {
+			photo.Caption = caption
+		}
+		media = append(media, photo)
+	}
+	return tgbotapi.NewMediaGroup(chatID, media)
+}
+
+// Сообщения альбома идут в том же порядке, что и изображения
+func rememberAlbum(urls []string, messages []tgbotapi.Message) {
+	if len(urls) != len(messages) {
+		return
+	}
+	for i, message := range messages {
+		remember(urls[i], message.Photo)
+	}
+}
@@ -0,0 +1,402 @@
+package movienight
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/groups"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+)
+
+// Раздел хранилища с киновечерами, ключ - ID чата
+const bucket = "movienight"
+
+// Префикс callback-данных кнопок голосования
+const CallbackPrefix = "mn:"
+
+const (
+	statusCollecting = "collecting"
+	statusVoting     = "voting"
+)
+
+// Ограничения голосования
+const (
+	maxCandidates      = 10
+	defaultVoteMinutes = 60
+	maxVoteMinutes     = 24 * 60
+)
+
+// Кандидат на просмотр
+type Candidate struct {
+	Movie       api.Cinema `json:"movie"`
+	AddedBy     int64      `json:"addedBy"`
+	AddedByName string     `json:"addedByName"`
+	AddedAt     time.Time  `json:"addedAt"`
+}
+
+// Киновечер в чате: сбор кандидатов, затем голосование
+type Night struct {
+	ChatID     int64       `json:"chatId"`
+	Status     string      `json:"status"`
+	StartedBy  int64       `json:"startedBy"`
+	Candidates []Candidate `json:"candidates"`
+	// Голоса: ID пользователя -> индекс кандидата
+	Votes     map[string]int `json:"votes"`
+	MessageID int            `json:"messageId"`
+	Deadline  time.Time      `json:"deadline"`
+}
+
+// Голосование меняют и обработчик обновлений, и фоновое закрытие по дедлайну
+var mu sync.Mutex
+
+func load(chatID int64) (*Night, bool) {
+	var night Night
+	if !storage.Get(bucket, strconv.FormatInt(chatID, 10), &night) {
+		return nil, false
+	}
+	if night.Votes == nil {
+		night.Votes = make(map[string]int)
+	}
+	return &night, true
+}
+
+func save(night *Night) {
+	if err := storage.Put(bucket, strconv.FormatInt(night.ChatID, 10), night); err != nil {
+		log.Println("Ошибка при сохранении киновечера:", err)
+	}
+}
+
+func remove(chatID int64) {
+	if err := storage.Delete(bucket, strconv.FormatInt(chatID, 10)); err != nil {
+		log.Println("Ошибка при удалении киновечера:", err)
+	}
+}
+
+// Обработка команды /movienight [vote [минуты] | stop | cancel]
+func HandleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	// Голосовать в личке не с кем
+	if !groups.IsGroup(message.Chat) {
+		sendMessage(bot, chatID, "Киновечер проводится в группе: добавьте бота в чат с друзьями и отправьте там /movienight")
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	args := strings.Fields(message.CommandArguments())
+	night, exists := load(chatID)
+
+	action := ""
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch {
+	case action == "" && !exists:
+		night = &Night{ChatID: chatID, Status: statusCollecting, StartedBy: message.From.ID, Votes: make(map[string]int)}
+		save(night)
+		sendMessage(bot, chatID, "🍿 Киновечер начался!\n\n"+
+			"Ищите фильмы и выбирайте их из списка - выбранные фильмы станут кандидатами.\n"+
+			"Когда кандидаты собраны: /movienight vote [минуты] - запустить голосование.\n"+
+			"/movienight cancel - отменить киновечер.")
+	case action == "":
+		sendMessage(bot, chatID, formatStatus(night))
+	case !exists:
+		sendMessage(bot, chatID, "Киновечер ещё не начат. Запустите его командой /movienight")
+	case !canManage(bot, night, message.From.ID):
+		sendMessage(bot, chatID, "Управлять киновечером может только тот, кто его начал, или администратор чата.")
+	case action == "vote":
+		startVoting(bot, night, args[1:])
+	case action == "stop":
+		if night.Status != statusVoting {
+			sendMessage(bot, chatID, "Голосование ещё не началось: /movienight vote [минуты]")
+			return
+		}
+		closeVoting(bot, night)
+	case action == "cancel":
+		remove(chatID)
+		sendMessage(bot, chatID, "Киновечер отменён.")
+	default:
+		sendMessage(bot, chatID, "Использование: /movienight, /movienight vote [минуты], /movienight stop, /movienight cancel")
+	}
+}
+
+// Управлять киновечером может инициатор или администратор группы
+func canManage(bot *tgbotapi.BotAPI, night *Night, userID int64) bool {
+	if night.StartedBy == userID {
+		return true
+	}
+	return night.ChatID < 0 && groups.IsAdmin(bot, night.ChatID, userID)
+}
+
+// Добавление выбранного из поиска фильма в кандидаты, если в чате идёт сбор
+func HandleSelection(bot *tgbotapi.BotAPI, chatID int64, user *tgbotapi.User, movie *api.Cinema) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	night, exists := load(chatID)
+	if !exists || night.Status != statusCollecting {
+		return
+	}
+
+	for _, candidate := range night.Candidates {
+		if candidate.Movie.ID == movie.ID {
+			return
+		}
+	}
+	if len(night.Candidates) >= maxCandidates {
+		sendMessage(bot, chatID, fmt.Sprintf("В киновечере уже %d кандидатов, больше добавить нельзя.", maxCandidates))
+		return
+	}
+
+	night.Candidates = append(night.Candidates, Candidate{
+		Movie:       *movie,
+		AddedBy:     user.ID,
+		AddedByName: user.FirstName,
+		AddedAt:     time.Now(),
+	})
+	save(night)
+	sendMessage(bot, chatID, fmt.Sprintf("🎬 %s добавлен в кандидаты киновечера (%d).", movie.Name, len(night.Candidates)))
+}
+
+func startVoting(bot *tgbotapi.BotAPI, night *Night, args []string) {
+	if night.Status == statusVoting {
+		sendMessage(bot, night.ChatID, "Голосование уже идёт.")
+		return
+	}
+	if len(night.Candidates) < 2 {
+		sendMessage(bot, night.ChatID, "Для голосования нужно хотя бы два кандидата.")
+		return
+	}
+
+	minutes := defaultVoteMinutes
+	if len(args) > 0 {
+		value, err := strconv.Atoi(args[0])
+		if err != nil || value < 1 || value > maxVoteMinutes {
+			sendMessage(bot, night.ChatID, fmt.Sprintf("Длительность голосования - число минут от 1 до %d.", maxVoteMinutes))
+			return
+		}
+		minutes = value
+	}
+
+	night.Status = statusVoting
+	night.Deadline = time.Now().Add(time.Duration(minutes) * time.Minute)
+
+	msg := tgbotapi.NewMessage(night.ChatID, formatVoting(night))
+	msg.ReplyMarkup = voteKeyboard(night)
+	sentMsg, err := sender.Send(bot, msg)
+	if err != nil {
+		log.Println("Ошибка при отправке голосования:", err)
+		return
+	}
+	night.MessageID = sentMsg.MessageID
+	save(night)
+}
+
+// Обработка нажатия кнопки голосования
+func HandleVote(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	chatID := callback.Message.Chat.ID
+	night, exists := load(chatID)
+	if !exists || night.Status != statusVoting || night.MessageID != callback.Message.MessageID {
+		return
+	}
+
+	index, err := strconv.Atoi(strings.TrimPrefix(callback.Data, CallbackPrefix))
+	if err != nil || index < 0 || index >= len(night.Candidates) {
+		return
+	}
+
+	night.Votes[strconv.FormatInt(callback.From.ID, 10)] = index
+	save(night)
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, night.MessageID, formatVoting(night), voteKeyboard(night))
+	sender.Post(bot, edit)
+}
+
+// Удаление голосов и имени пользователя из киновечеров групп
+func Forget(userID int64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	userKey := strconv.FormatInt(userID, 10)
+	for _, key := range storage.Keys(bucket) {
+		chatID, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			continue
+		}
+		night, exists := load(chatID)
+		if !exists {
+			continue
+		}
+		changed := false
+		if _, voted := night.Votes[userKey]; voted {
+			delete(night.Votes, userKey)
+			changed = true
+		}
+		if night.StartedBy == userID {
+			night.StartedBy = 0
+			changed = true
+		}
+		for i := range night.Candidates {
+			if night.Candidates[i].AddedBy == userID {
+				night.Candidates[i].AddedBy, night.Candidates[i].AddedByName = 0, ""
+				changed = true
+			}
+		}
+		if changed {
+			save(night)
+		}
+	}
+}
+
+// Закрытие голосований, у которых наступил дедлайн
+func CloseExpired(bot *tgbotapi.BotAPI, now time.Time) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, key := range storage.Keys(bucket) {
+		chatID, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			continue
+		}
+		night, exists := load(chatID)
+		if exists && night.Status == statusVoting && !now.Before(night.Deadline) {
+			closeVoting(bot, night)
+		}
+	}
+}
+
+// Фоновая проверка дедлайнов голосований. Открытые голосования лежат в хранилище,
+// поэтому после перезапуска бота они закрываются в срок.
+func Run(bot *tgbotapi.BotAPI, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		CloseExpired(bot, now)
+	}
+}
+
+// Закрытие голосования: итоги в сообщении голосования и карточка победителя.
+// Если никто не проголосовал, победителя нет.
+func closeVoting(bot *tgbotapi.BotAPI, night *Night) {
+	counts := voteCounts(night)
+	remove(night.ChatID)
+
+	results := formatResults(night, counts)
+	edit := tgbotapi.NewEditMessageText(night.ChatID, night.MessageID, results)
+	sender.Post(bot, edit)
+
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if total == 0 {
+		sendMessage(bot, night.ChatID, "🏁 Голосование завершено, но никто не проголосовал. Начните новое командой /movienight.")
+		return
+	}
+	index, tie := chooseWinner(night, counts)
+	winner := night.Candidates[index]
+	text := fmt.Sprintf("🏆 Голосование завершено! Смотрим: %s (голосов: %d)", winner.Movie.Name, counts[index])
+	if tie {
+		text += "\nПри равенстве голосов победил фильм с более высоким рейтингом КП."
+	}
+	sendMessage(bot, night.ChatID, text)
+	movies.SendMovieCard(bot, night.ChatID, &winner.Movie)
+}
+
+func voteCounts(night *Night) []int {
+	counts := make([]int, len(night.Candidates))
+	for _, index := range night.Votes {
+		if index >= 0 && index < len(counts) {
+			counts[index]++
+		}
+	}
+	return counts
+}
+
+// Победитель - кандидат с наибольшим числом голосов. При равенстве выигрывает
+// более высокий рейтинг КП, затем тот, кого предложили раньше.
+func chooseWinner(night *Night, counts []int) (index int, tie bool) {
+	maxVotes := 0
+	for _, count := range counts {
+		if count > maxVotes {
+			maxVotes = count
+		}
+	}
+
+	index = -1
+	for i, count := range counts {
+		if count != maxVotes {
+			continue
+		}
+		if index == -1 {
+			index = i
+			continue
+		}
+		tie = true
+		if night.Candidates[i].Movie.Rating.Kp > night.Candidates[index].Movie.Rating.Kp {
+			index = i
+		}
+	}
+	return index, tie
+}
+
+func voteKeyboard(night *Night) tgbotapi.InlineKeyboardMarkup {
+	counts := voteCounts(night)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, candidate := range night.Candidates {
+		label := fmt.Sprintf("%s (%d) - %d", candidate.Movie.Name, candidate.Movie.Year, counts[i])
+		button := tgbotapi.NewInlineKeyboardButtonData(label, CallbackPrefix+strconv.Itoa(i))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func formatStatus(night *Night) string {
+	if night.Status == statusVoting {
+		return formatVoting(night)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🍿 Идёт сбор кандидатов на киновечер.\n\n")
+	if len(night.Candidates) == 0 {
+		sb.WriteString("Кандидатов пока нет - найдите фильм и выберите его из списка.\n")
+	}
+	for i, candidate := range night.Candidates {
+		sb.WriteString(fmt.Sprintf("%d. %s (%d) - предложил(а) %s\n", i+1, candidate.Movie.Name, candidate.Movie.Year, candidate.AddedByName))
+	}
+	sb.WriteString("\n/movienight vote [минуты] - запустить голосование")
+	return sb.String()
+}
+
+func formatVoting(night *Night) string {
+	return fmt.Sprintf("🗳 Голосование за фильм на киновечер!\nГолосов: %d. Итоги в %s (UTC).\nНажмите на фильм, чтобы проголосовать, голос можно поменять.",
+		len(night.Votes), night.Deadline.UTC().Format("15:04 02.01"))
+}
+
+func formatResults(night *Night, counts []int) string {
+	var sb strings.Builder
+	sb.WriteString("🗳 Голосование завершено.\n\n")
+	for i, candidate := range night.Candidates {
+		sb.WriteString(fmt.Sprintf("%s (%d) - %d\n", candidate.Movie.Name, candidate.Movie.Year, counts[i]))
+	}
+	return sb.String()
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}
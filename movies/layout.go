@@ -0,0 +1,137 @@
+package movies
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/render"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+)
+
+// Префикс callback-данных кнопок вида карточки: ly:<вид>:<ID фильма>
+const LayoutPrefix = "ly:"
+
+// Кнопки переключения вида под карточкой
+var layoutButtons = []struct {
+	layout string
+	text   string
+}{
+	{render.LayoutPhoto, "🖼 Карточка"},
+	{render.LayoutCompact, "🔹 Кратко"},
+	{render.LayoutDetailed, "📄 Подробно"},
+}
+
+func layoutRow(movie *api.Cinema) []tgbotapi.InlineKeyboardButton {
+	row := make([]tgbotapi.InlineKeyboardButton, 0, len(layoutButtons))
+	for _, button := range layoutButtons {
+		data := fmt.Sprintf("%s%s:%d", LayoutPrefix, button.layout, movie.ID)
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(button.text, data))
+	}
+	return row
+}
+
+// Сообщение - карточка фильма, а не список или другое сообщение с кнопками
+func IsCard(message *tgbotapi.Message) bool {
+	if message.ReplyMarkup == nil {
+		return false
+	}
+	for _, row := range message.ReplyMarkup.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData != nil && strings.HasPrefix(*button.CallbackData, LayoutPrefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Карточка фильма в виде layout
+func FormatMovieLayout(movie *api.Cinema, layout string) (render.Card, error) {
+	return render.Render(layout, render.DefaultLocale, CardData(movie))
+}
+
+// Краткая строка о фильме для списков. При ошибке шаблона - название и год.
+func CompactLine(movie *api.Cinema) string {
+	card, err := FormatMovieLayout(movie, render.LayoutCompact)
+	if err != nil {
+		log.Println(err)
+		return fmt.Sprintf("%s (%d)", render.Escape(movie.Name), movie.Year)
+	}
+	return card.Text
+}
+
+// Обработка кнопок вида карточки. Текстовые виды меняются на месте,
+// а переход к постеру и обратно - новой карточкой вместо прежней.
+func HandleLayoutCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	parts := strings.Split(strings.TrimPrefix(callback.Data, LayoutPrefix), ":")
+	if len(parts) != 2 {
+		return
+	}
+	layout, ok := render.GetLayout(parts[0])
+	if !ok {
+		return
+	}
+	movieID, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return
+	}
+	message := callback.Message
+	chatID := message.Chat.ID
+
+	// Подробной карточке нужны актёры и даты, которых нет в результатах поиска
+	get := GetMovie
+	if parts[0] == render.LayoutDetailed {
+		get = GetDetails
+	}
+	movie, err := get(uint32(movieID))
+	if err != nil {
+		sendError(bot, chatID, err)
+		return
+	}
+
+	if layout.Photo() {
+		if message.Photo == nil {
+			SendMovieCard(bot, chatID, movie)
+			deleteCard(bot, message)
+		}
+		return
+	}
+
+	card, err := FormatMovieLayout(movie, parts[0])
+	if err != nil {
+		sendError(bot, chatID, err)
+		return
+	}
+	if message.Photo != nil {
+		sendTextCard(bot, chatID, movie, card)
+		deleteCard(bot, message)
+		return
+	}
+	// Карточка уже в этом виде
+	if strings.TrimSpace(render.Plain(card.Text)) == message.Text {
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, message.MessageID, card.Text)
+	edit.ParseMode = "HTML"
+	edit.DisableWebPagePreview = true
+	edit.ReplyMarkup = CardKeyboard(chatID, movie)
+	if _, err := sender.Request(bot, edit); err != nil {
+		log.Println("Ошибка при смене вида карточки:", err)
+		return
+	}
+	sendOverflow(bot, chatID, card)
+}
+
+func deleteCard(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	sender.Post(bot, tgbotapi.NewDeleteMessage(message.Chat.ID, message.MessageID))
+}
+
+func sendError(bot *tgbotapi.BotAPI, chatID int64, err error) {
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+	sender.Post(bot, msg)
+}
@@ -3,25 +3,29 @@ package movies
 import (
 	"fmt"
 	"log"
-	"strings"
-	"unicode/utf8"
+	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/media"
+	"github.com/luzhnov-aleksei/kinobot/poster"
+	"github.com/luzhnov-aleksei/kinobot/render"
+	"github.com/luzhnov-aleksei/kinobot/sender"
 )
 
-func HandleMovieSelection(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
-	userID := update.CallbackQuery.From.ID
+// Обработка выбора фильма из списка. Возвращает выбранный фильм или nil.
+func HandleMovieSelection(bot *tgbotapi.BotAPI, update *tgbotapi.Update) *api.Cinema {
+	key := selectionKey(update.CallbackQuery.Message.Chat, update.CallbackQuery.From.ID)
 	selectedMovieID := update.CallbackQuery.Data
+	chatID := update.CallbackQuery.Message.Chat.ID
 
 	// Получаем сохранённый список фильмов
-	movies, exists := UserMovieSelections[userID]
+	movies, exists := selection(key)
 	if !exists {
-		msg := tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, "Произошла ошибка: список фильмов не найден.\nПопробуйте ввести новый запрос.")
-		if _, err := bot.Send(msg); err != nil {
-			log.Println("Список фильмов не найден, ошибка при отправке сообщения:", err)
-		}
-		return
+		msg := tgbotapi.NewMessage(chatID, "Произошла ошибка: список фильмов не найден.\nПопробуйте ввести новый запрос.")
+		sender.Post(bot, msg)
+		return nil
 	}
 
 	// Ищем выбранный фильм по ID
@@ -33,125 +37,384 @@ func HandleMovieSelection(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
 		}
 	}
 
-	if selectedMovie != nil {
-		film, imageURL, err := FormatMovieInfo(selectedMovie)
-		if err != nil {
-			text := fmt.Sprintf("Произошла ошибка: %s", err)
-			msg := tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, text)
-			if _, err := bot.Send(msg); err != nil {
-				log.Println("Ошибка при форматировании фильма:", err)
-			}
-			return
-		}
+	if selectedMovie == nil {
+		msg := tgbotapi.NewMessage(chatID, "Фильм не найден.")
+		sender.Post(bot, msg)
+		return nil
+	}
+
+	SendMovieCard(bot, chatID, selectedMovie)
+	return selectedMovie
+}
 
-		// Отправка информации о фильме вместе с изображением
-		photo := tgbotapi.NewInputMediaPhoto(tgbotapi.FileURL(imageURL))
-		photo.Caption = film
-		photo.ParseMode = "HTML"
+// Ряды кнопок под карточкой фильма. Пакеты с действиями над фильмом
+// добавляют свои ряды через AddCardRow.
+var cardRows []func(chatID int64, movie *api.Cinema) []tgbotapi.InlineKeyboardButton
 
-		media := []interface{}{photo}
-		mediaGroup := tgbotapi.MediaGroupConfig{
-			ChatID: update.CallbackQuery.Message.Chat.ID,
-			Media:  media,
+// Сколько фильмов хранится в кэше и сколько живёт запись
+const (
+	MaxCachedMovies = 5000
+	CacheTTL        = 24 * time.Hour
+)
+
+// Фильм в кэше. Detailed - подробная информация, а не краткие данные из результатов поиска.
+type cachedMovie struct {
+	Movie    api.Cinema
+	Detailed bool
+	CachedAt time.Time
+}
+
+// Фильмы, карточки которых уже показывались, чтобы не запрашивать их у API повторно.
+// Кэш читают и фоновые задачи (киновечер, напоминания), поэтому доступ - под cacheMu.
+var (
+	cacheMu     sync.Mutex
+	knownMovies = make(map[uint32]cachedMovie)
+)
+
+// Регистрация ряда кнопок карточки. Пустой ряд не показывается.
+func AddCardRow(row func(chatID int64, movie *api.Cinema) []tgbotapi.InlineKeyboardButton) {
+	cardRows = append(cardRows, row)
+}
+
+// Клавиатура под карточкой фильма для чата. Последний ряд - переключение вида карточки.
+func CardKeyboard(chatID int64, movie *api.Cinema) *tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, row := range cardRows {
+		if buttons := row(chatID, movie); len(buttons) > 0 {
+			rows = append(rows, buttons)
 		}
+	}
+	rows = append(rows, layoutRow(movie))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &keyboard
+}
 
-		_, err = bot.SendMediaGroup(mediaGroup)
-		if err != nil {
-			text := fmt.Sprintf("Произошла ошибка в отправке медиагруппы: %s", err)
-			msg := tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, text)
-			if _, err := bot.Send(msg); err != nil {
-				log.Println("Ошибка при отправке медиагруппы:", err)
-			}
+// Размер кэша показанных фильмов
+func CacheSize() int {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	return len(knownMovies)
+}
+
+// Очистка кэша показанных фильмов, возвращает число удалённых записей
+func ClearCache() int {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	count := len(knownMovies)
+	knownMovies = make(map[uint32]cachedMovie)
+	return count
+}
 
+// Фильм из кэша, если запись ещё не устарела
+func cached(id uint32) (cachedMovie, bool) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	entry, ok := knownMovies[id]
+	if !ok || time.Since(entry.CachedAt) > CacheTTL {
+		return cachedMovie{}, false
+	}
+	return entry, true
+}
+
+// Сохранение фильма в кэш. Краткие данные из поиска не заменяют уже полученные подробные.
+// Когда кэш заполнен, удаляются устаревшие записи, а если их нет - самая старая.
+func remember(movie *api.Cinema, detailed bool) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	if entry, ok := knownMovies[movie.ID]; ok && entry.Detailed && !detailed && time.Since(entry.CachedAt) <= CacheTTL {
+		return
+	}
+	if _, ok := knownMovies[movie.ID]; !ok && len(knownMovies) >= MaxCachedMovies {
+		evict()
+	}
+	knownMovies[movie.ID] = cachedMovie{Movie: *movie, Detailed: detailed, CachedAt: time.Now()}
+}
+
+// Освобождение места в кэше, вызывается под cacheMu
+func evict() {
+	var oldestID uint32
+	var oldest time.Time
+	for id, entry := range knownMovies {
+		if time.Since(entry.CachedAt) > CacheTTL {
+			delete(knownMovies, id)
+			continue
+		}
+		if oldest.IsZero() || entry.CachedAt.Before(oldest) {
+			oldestID, oldest = id, entry.CachedAt
 		}
+	}
+	if len(knownMovies) >= MaxCachedMovies {
+		delete(knownMovies, oldestID)
+	}
+}
+
+// Получение фильма по ID: из уже показанных или через API
+func GetMovie(id uint32) (*api.Cinema, error) {
+	if entry, ok := cached(id); ok {
+		return &entry.Movie, nil
+	}
+	return GetDetails(id)
+}
+
+// Подробная информация о фильме: из кэша, если она уже запрашивалась, иначе через API
+func GetDetails(id uint32) (*api.Cinema, error) {
+	if entry, ok := cached(id); ok && entry.Detailed {
+		return &entry.Movie, nil
+	}
+	movie, err := api.RequestMovie(api.BaseURL+"/movie", id)
+	if err != nil {
+		return nil, err
+	}
+	remember(movie, true)
+	return movie, nil
+}
+
+// Есть ли в кэше подробная информация о фильме
+func HasDetails(id uint32) bool {
+	entry, ok := cached(id)
+	return ok && entry.Detailed
+}
+
+// Фильм из кэша без запроса к API
+func CachedMovie(id uint32) (*api.Cinema, bool) {
+	entry, ok := cached(id)
+	if !ok {
+		return nil, false
+	}
+	return &entry.Movie, true
+}
+
+// Отправка карточки фильма с постером и кнопками в чат
+func SendMovieCard(bot *tgbotapi.BotAPI, chatID int64, movie *api.Cinema) {
+	remember(movie, false)
 
+	card, err := FormatMovieCard(movie)
+	if err != nil {
+		text := fmt.Sprintf("Произошла ошибка: %s", err)
+		msg := tgbotapi.NewMessage(chatID, text)
+		sender.Post(bot, msg)
+		return
+	}
+
+	// Отправка информации о фильме вместе с постером, без постера - с заглушкой
+	photo := tgbotapi.NewPhoto(chatID, nil)
+	photo.Caption = card.Text
+	photo.ParseMode = "HTML"
+	if keyboard := CardKeyboard(chatID, movie); keyboard != nil {
+		photo.ReplyMarkup = keyboard
+	}
+
+	if imageURL := posterURL(movie); imageURL != "" {
+		_, err = media.SendPhoto(bot, photo, imageURL)
 	} else {
-		msg := tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, "Фильм не найден.")
-		if _, err := bot.Send(msg); err != nil {
-			log.Println("Ошибка при отправке сообщения, фильм не найден:", err)
+		placeholder, placeholderErr := poster.Placeholder(movie.Name, movie.Year)
+		if placeholderErr != nil {
+			log.Println(placeholderErr)
+			sendTextCard(bot, chatID, movie, card)
+			return
 		}
+		photo.File = tgbotapi.FileBytes{Name: "poster.png", Bytes: placeholder}
+		_, err = sender.Send(bot, photo)
 	}
+	if err != nil {
+		text := fmt.Sprintf("Произошла ошибка в отправке карточки фильма: %s", err)
+		msg := tgbotapi.NewMessage(chatID, text)
+		sender.Post(bot, msg)
+		return
+	}
+	sendOverflow(bot, chatID, card)
 }
 
-// тип: фильм, сериал, аниме и т.д.
-func TypeFilm(TypeNumber int) string {
-	switch TypeNumber {
-	case 1:
-		return "Фильм"
-	case 2:
-		return "Сериал"
-	case 3:
-		return "Мультфильм"
-	case 4:
-		return "Аниме"
-	case 5:
-		return "Мультсериал"
-	default:
-		return ""
+// Карточка фильма без изображения
+func sendTextCard(bot *tgbotapi.BotAPI, chatID int64, movie *api.Cinema, card render.Card) {
+	msg := tgbotapi.NewMessage(chatID, card.Text)
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	if keyboard := CardKeyboard(chatID, movie); keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+	if _, err := sender.Send(bot, msg); err != nil {
+		log.Println("Ошибка при отправке карточки фильма:", err)
+		return
 	}
+	sendOverflow(bot, chatID, card)
 }
 
-// Функция для форматирования информации о фильме
-func FormatMovieInfo(movie *api.Cinema) (film string, picURL string, err error) {
+// Продолжение описания, которое не уместилось в подпись к постеру
+func sendOverflow(bot *tgbotapi.BotAPI, chatID int64, card render.Card) {
+	if card.Overflow == "" {
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, card.Overflow)
+	msg.ParseMode = "HTML"
+	sender.Post(bot, msg)
+}
+
+// тип: фильм, сериал, аниме и т.д. по номеру typeNumber, у неизвестного типа - пустая строка
+func TypeFilm(TypeNumber int) string {
+	return TypeName(api.TypeFromNumber(TypeNumber))
+}
+
+// Постер карточки: постер или фон фильма. Пустая строка - отправляется заглушка.
+func posterURL(movie *api.Cinema) string {
 	if movie.Poster != nil && movie.Poster.URL != "" {
-		picURL = movie.Poster.URL
-	} else if movie.BackDrop != nil && movie.BackDrop.URL != "" {
-		picURL = movie.BackDrop.URL
-	} else {
-		picURL = "https://habrastorage.org/webt/bh/ex/-z/bhex-zst09dlgq-y2rjespcpp0c.png"
+		return movie.Poster.URL
 	}
+	if movie.BackDrop != nil && movie.BackDrop.URL != "" {
+		return movie.BackDrop.URL
+	}
+	return ""
+}
 
-	rating := movie.Rating
+// Имя пользователя бота для ссылок t.me, задаётся при запуске. Без него имена в карточке без ссылок.
+var BotUserName string
 
-	// собираем всё в одну переменную
-	var sb strings.Builder
+// Префикс параметра /start, открывающего карточку человека: /start person_<ID>
+const PersonStartPrefix = "person_"
 
-	if movie.Name == "" {
-		sb.WriteString("Фильм не найден, попробуйте снова")
-	} else {
-		typeFilm := TypeFilm(movie.TypeNumber)
-		sb.WriteString(fmt.Sprintf("%s\n", typeFilm))
+// Сколько актёров и режиссёров показывать в подробной карточке
+const (
+	castSize      = 6
+	directorsSize = 3
+)
 
-		sb.WriteString(fmt.Sprintf("%v (%v) %v+\n", movie.Name, movie.Year, movie.AgeRating))
-		for _, genre := range movie.Genres {
-			sb.WriteString(fmt.Sprintf("#%v ", genre.Name))
-		}
-		sb.WriteString("\n")
+// Ссылка, которая открывает карточку человека в личке с ботом
+func PersonURL(personID int) string {
+	if BotUserName == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://t.me/%s?start=%s%d", BotUserName, PersonStartPrefix, personID)
+}
 
-		if movie.MovieLength != 0 {
-			hours := movie.MovieLength / 60
-			minutes := movie.MovieLength % 60
-			sb.WriteString(fmt.Sprintf("Длительность: %d ч %d мин\n", hours, minutes))
+// Режиссёры и актёры фильма со ссылками на их карточки
+func personLinks(movie *api.Cinema) (directors, cast []render.Link) {
+	for _, person := range movie.Persons {
+		name := person.Name
+		if name == "" {
+			name = person.EnName
 		}
+		if name == "" {
+			continue
+		}
+		link := render.Link{Name: name, URL: PersonURL(person.ID)}
+		switch {
+		case person.EnProfession == "director" && len(directors) < directorsSize:
+			directors = append(directors, link)
+		case person.EnProfession == "actor" && len(cast) < castSize:
+			cast = append(cast, link)
+		}
+	}
+	return directors, cast
+}
+
+// Данные карточки фильма для шаблона
+func CardData(movie *api.Cinema) render.CardData {
+	data := render.CardData{
+		Type:        TypeName(movie.MovieType()),
+		Name:        movie.Name,
+		Year:        movie.Year,
+		AgeRating:   movie.AgeRating,
+		Description: movie.Description,
+		Length:      movie.MovieLength,
+		Kp:          movie.Rating.Kp,
+		Imdb:        movie.Rating.Imdb,
+		URL:         fmt.Sprintf("https://www.kinopoisk.ru/film/%d/", movie.ID),
+		KpVotes:     movie.Votes.Kp,
+		ImdbVotes:   movie.Votes.Imdb,
 
-		sb.WriteString("Страны: ")
-		if len(movie.Countries) > 0 {
-			for i, country := range movie.Countries {
-				if i > 0 {
-					sb.WriteString(", ")
-				}
-				sb.WriteString(country.Name)
-			}
-		} else {
-			sb.WriteString("Страна не указана")
+		AlternativeName: movie.AlternativeName,
+		Status:          statusName(movie.Status),
+	}
+	if IsSeries(movie) {
+		data.Series = true
+		data.SeriesStatus = seriesStatus(movie)
+		data.SeriesLength = movie.SeriesLength
+	}
+	if data.Description == "" {
+		data.Description = movie.ShortDescription
+	}
+	for _, genre := range movie.Genres {
+		data.Genres = append(data.Genres, genre.Name)
+	}
+	for _, country := range movie.Countries {
+		data.Countries = append(data.Countries, country.Name)
+	}
+	if movie.Premiere != nil {
+		data.PremiereWorld = formatDate(movie.Premiere.World)
+		data.PremiereRussia = formatDate(movie.Premiere.Russia)
+		data.PremiereDigital = formatDate(movie.Premiere.Digital)
+	}
+	data.Directors, data.Cast = personLinks(movie)
+	// Нулевой сезон - спецвыпуски, в число сезонов не входит
+	for _, season := range movie.SeasonsInfo {
+		if season.Number > 0 {
+			data.Seasons++
+			data.Episodes += season.EpisodesCount
 		}
-		sb.WriteString("\n")
-
-		// полное или краткое описание
-		if utf8.RuneCountInString(movie.Description) < 350 {
-			sb.WriteString(fmt.Sprintf("\nОписание: %v\n", movie.Description))
-		} else if movie.ShortDescription != "" {
-			sb.WriteString(fmt.Sprintf("\nКраткое описание: %v\n", movie.ShortDescription))
-		} else {
-			sb.WriteString("\nОписание слишком длинное.\nДля ознакомления с ним нажми <u>Смотреть подробнее</u>👇🏻\n")
+	}
+	return data
+}
+
+// Статусы производства для подробной карточки
+var statusNames = map[string]string{
+	"announced":       "анонсирован",
+	"pre-production":  "подготовка к съёмкам",
+	"filming":         "идут съёмки",
+	"post-production": "постпродакшн",
+	"completed":       "завершён",
+}
+
+func statusName(status string) string {
+	if name, ok := statusNames[status]; ok {
+		return name
+	}
+	return status
+}
+
+// Сериал: по признаку из API или по типу
+func IsSeries(movie *api.Cinema) bool {
+	return movie.IsSeries || movie.MovieType().IsSeries()
+}
+
+// Состояние сериала: по статусу производства, а без статуса - по годам выхода
+func seriesStatus(movie *api.Cinema) string {
+	switch movie.Status {
+	case "completed":
+		return "завершён"
+	case "announced":
+		return "анонсирован"
+	case "":
+	default:
+		return "ещё выходит"
+	}
+	if n := len(movie.ReleaseYears); n > 0 {
+		if movie.ReleaseYears[n-1].End == 0 {
+			return "ещё выходит"
 		}
-		sb.WriteString("\n")
-		sb.WriteString(fmt.Sprintf("КП: %.1f IMDb: %.1f\n", rating.Kp, rating.Imdb))
-		filmURL := fmt.Sprintf("<a href=\"https://www.kinopoisk.ru/film/%d/\">Смотреть подробнее</a>\n",
-			movie.ID)
-		sb.WriteString(filmURL)
+		return "завершён"
 	}
+	return ""
+}
+
+// Дата из API (RFC 3339) в виде ДД.ММ.ГГГГ
+func formatDate(value string) string {
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	return date.Format("02.01.2006")
+}
 
-	return sb.String(), picURL, nil
+// Карточка фильма: подпись к постеру и продолжение описания, если оно не уместилось
+func FormatMovieCard(movie *api.Cinema) (render.Card, error) {
+	return render.RenderCard(render.DefaultLocale, CardData(movie))
+}
 
+// Функция для форматирования информации о фильме: подпись карточки и постер.
+// Если у фильма нет ни постера, ни фона, picURL пустой и вместо изображения отправляется заглушка.
+func FormatMovieInfo(movie *api.Cinema) (film string, picURL string, err error) {
+	card, err := FormatMovieCard(movie)
+	return card.Text, posterURL(movie), err
 }
@@ -2,85 +2,205 @@ package movies
 
 import (
 	"fmt"
-	"log"
+	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/groups"
+	"github.com/luzhnov-aleksei/kinobot/sender"
 )
 
 var UserMovieSelections = make(map[int64][]api.Cinema)
 var userPreviousMessages = make(map[int64]int)
 var userPreviousLists = make(map[int64]int)
 
-// URL API можно передать как параметр или установить глобально
-const apiURL = "https://api.kinopoisk.dev/v1.4/movie/search"
+// Время последнего поиска по ключу списка: результаты старше SelectionTTL удаляются
+var selectionTimes = make(map[int64]time.Time)
 
-// Обработчик поиска фильмов
-func HandleMovieSearch(bot *tgbotapi.BotAPI, update *tgbotapi.Update) {
-	userID := update.Message.From.ID
-
-	// Удаляем предыдущее сообщение пользователя, если оно существует
-	if msgID, ok := userPreviousMessages[userID]; ok && msgID != 0 {
-		deleteMsg := tgbotapi.NewDeleteMessage(update.Message.Chat.ID, msgID)
-		if _, err := bot.Request(deleteMsg); err != nil {
-			log.Println("Ошибка при удалении предыдущего сообщения:", err)
-		} else {
-			// Обнуляем, чтобы не было повторной попытки удаления
-			userPreviousMessages[userID] = 0
+// Сколько хранятся результаты поиска и ID сообщений для их очистки
+const SelectionTTL = 24 * time.Hour
+
+// Для скольких пользователей и чатов хранятся результаты поиска. При переполнении
+// удаляются самые старые: их кнопки просто перестанут работать.
+var MaxSelections = 5000
+
+// Доступ к спискам фильмов и ID сообщений - под selectionsMu
+var selectionsMu sync.Mutex
+
+// Дополнительные ряды над результатами поиска (например, найденные люди).
+// Другие пакеты добавляют их через AddSearchRow, found - уже найденные фильмы.
+var searchRows []func(query string, found []api.Cinema) []tgbotapi.InlineKeyboardButton
+
+// Регистрация ряда кнопок над результатами поиска. Пустой ряд не показывается.
+func AddSearchRow(row func(query string, found []api.Cinema) []tgbotapi.InlineKeyboardButton) {
+	searchRows = append(searchRows, row)
+}
+
+// Ключ списка фильмов: в группе список общий для всего чата, в личке - у пользователя свой
+func selectionKey(chat *tgbotapi.Chat, userID int64) int64 {
+	if groups.IsGroup(chat) {
+		return chat.ID
+	}
+	return userID
+}
+
+// Сохранение списка фильмов, из которого пользователь выбирает фильм кнопками
+func SetSelection(chat *tgbotapi.Chat, userID int64, movies []api.Cinema) {
+	setSelection(selectionKey(chat, userID), movies)
+}
+
+// Текущий список фильмов, из которого пользователь выбирает фильм кнопками
+func Selection(chat *tgbotapi.Chat, userID int64) []api.Cinema {
+	movies, _ := selection(selectionKey(chat, userID))
+	return movies
+}
+
+func setSelection(key int64, movies []api.Cinema) {
+	selectionsMu.Lock()
+	defer selectionsMu.Unlock()
+	dropExpiredSelections(key)
+	UserMovieSelections[key] = movies
+	selectionTimes[key] = time.Now()
+}
+
+func selection(key int64) ([]api.Cinema, bool) {
+	selectionsMu.Lock()
+	defer selectionsMu.Unlock()
+	movies, ok := UserMovieSelections[key]
+	return movies, ok
+}
+
+// Удаление устаревших результатов поиска, а если места для нового ключа всё равно нет -
+// самых старых. Вызывается под selectionsMu.
+func dropExpiredSelections(newKey int64) {
+	for key, at := range selectionTimes {
+		if time.Since(at) > SelectionTTL {
+			forgetKey(key)
 		}
 	}
+	if _, ok := selectionTimes[newKey]; ok {
+		return
+	}
+	for len(selectionTimes) > 0 && len(selectionTimes) >= MaxSelections {
+		var oldestKey int64
+		var oldest time.Time
+		for key, at := range selectionTimes {
+			if oldest.IsZero() || at.Before(oldest) {
+				oldestKey, oldest = key, at
+			}
+		}
+		forgetKey(oldestKey)
+	}
+}
 
-	// Удаляем предыдущее сообщение со списком фильмов, если оно существует
-	if listID, ok := userPreviousLists[userID]; ok && listID != 0 {
-		deleteMsg := tgbotapi.NewDeleteMessage(update.Message.Chat.ID, listID)
-		if _, err := bot.Request(deleteMsg); err != nil {
-			log.Println("Ошибка при удалении сообщения со списком фильмов:", err)
-		} else {
-			// Обнуляем, чтобы не было повторной попытки удаления
-			userPreviousLists[userID] = 0
+func forgetKey(key int64) {
+	delete(UserMovieSelections, key)
+	delete(userPreviousMessages, key)
+	delete(userPreviousLists, key)
+	delete(selectionTimes, key)
+}
+
+// Удаление результатов поиска и ID сообщений пользователя
+func Forget(userID int64) {
+	selectionsMu.Lock()
+	defer selectionsMu.Unlock()
+	forgetKey(userID)
+}
+
+// ID сообщения из карты ID сообщений под selectionsMu. Взятый ID обнуляется,
+// чтобы сообщение не удалялось повторно.
+func takePreviousID(ids map[int64]int, key int64) int {
+	selectionsMu.Lock()
+	defer selectionsMu.Unlock()
+	id := ids[key]
+	ids[key] = 0
+	return id
+}
+
+func setPreviousID(ids map[int64]int, key int64, id int) {
+	selectionsMu.Lock()
+	defer selectionsMu.Unlock()
+	ids[key] = id
+}
+
+// Обработчик поиска фильмов
+func HandleMovieSearch(bot *tgbotapi.BotAPI, update *tgbotapi.Update, query string) {
+	chatID := update.Message.Chat.ID
+	isGroup := groups.IsGroup(update.Message.Chat)
+	key := selectionKey(update.Message.Chat, update.Message.From.ID)
+	cleanup := !isGroup || groups.GetSettings(update.Message.Chat.ID).Cleanup
+
+	// Удаляем предыдущее сообщение пользователя, если оно существует.
+	// В группах бот не трогает чужие сообщения.
+	if !isGroup {
+		if msgID := takePreviousID(userPreviousMessages, key); msgID != 0 {
+			sender.Post(bot, tgbotapi.NewDeleteMessage(chatID, msgID))
 		}
 	}
 
+	// Удаляем предыдущее сообщение со списком фильмов, если оно существует. Его ID
+	// записывается после отправки, поэтому берём его, когда до удаления дойдёт очередь чата.
+	if cleanup {
+		sender.PostDeferred(bot, chatID, func() tgbotapi.Chattable {
+			if listID := takePreviousID(userPreviousLists, key); listID != 0 {
+				return tgbotapi.NewDeleteMessage(chatID, listID)
+			}
+			return nil
+		})
+	}
+
+	// Фильтр по типу («Тьма тип:сериал») API поиска не поддерживает, применяем его к результатам
+	query, types := SplitTypeFilter(query)
+
 	// Получаем список фильмов по запросу
-	movies, err := api.RequestMovies(apiURL, update.Message.Text)
+	movies, err := api.RequestMovies(api.BaseURL+"/movie/search", query)
 	if err != nil {
 		msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Произошла ошибка: %s", err))
-		if _, err := bot.Send(msg); err != nil {
-			log.Println("Ошибка при получении списка фильмов:", err)
-		}
+		sender.Post(bot, msg)
 		return
 	}
+	movies = FilterByType(movies, types)
 
-	if len(movies) == 0 {
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Фильм не найден, попробуйте другой запрос")
-		if _, err := bot.Send(msg); err != nil {
-			log.Println("Фильм не найден, ошибка при отправке сообщения:", err)
+	// Формируем inline-кнопки: сначала дополнительные ряды, с фильтром по типу их нет
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	if len(types) == 0 {
+		for _, row := range searchRows {
+			if extra := row(query, movies); len(extra) > 0 {
+				buttons = append(buttons, extra)
+			}
 		}
+	}
+
+	if len(movies) == 0 && len(buttons) == 0 {
+		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Фильм не найден, попробуйте другой запрос")
+		sender.Post(bot, msg)
 		return
 	}
 
 	// Сохраняем список фильмов
-	UserMovieSelections[userID] = movies
-	var countryName string
+	setSelection(key, movies)
 
-	// Формируем inline-кнопки
-	var buttons [][]tgbotapi.InlineKeyboardButton
-	for _, movie := range movies {
-		if len(movie.Countries) > 0 {
-			countryName = movie.Countries[0].Name
-		} else {
-			countryName = "Страна не указана"
-		}
-		button := tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s (%s, %s, %d)", movie.Name, TypeFilm(movie.TypeNumber), countryName, movie.Year), fmt.Sprint(movie.ID))
+	for i := range movies {
+		button := tgbotapi.NewInlineKeyboardButtonData(searchLabel(&movies[i]), fmt.Sprint(movies[i].ID))
 		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(button))
 	}
 
 	// Отправляем сообщение с выбором фильмов
-	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Выберите фильм:")
+	text := "Выберите фильм:"
+	if len(buttons) > len(movies) {
+		text = "Выберите фильм или человека:"
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	sentMsg, _ := bot.Send(msg)
 
-	// Сохраняем ID отправленного сообщения и ID запроса пользователя
-	userPreviousMessages[userID] = update.Message.MessageID
-	userPreviousLists[userID] = sentMsg.MessageID
+	// Сохраняем ID запроса пользователя и, после отправки, ID сообщения со списком
+	if !isGroup {
+		setPreviousID(userPreviousMessages, key, update.Message.MessageID)
+	}
+	sender.PostThen(bot, msg, func(sent tgbotapi.Message, err error) {
+		if err == nil {
+			setPreviousID(userPreviousLists, key, sent.MessageID)
+		}
+	})
 }
@@ -0,0 +1,101 @@
+package movies
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+)
+
+// Название и значок типа фильма
+type typeInfo struct {
+	Name  string
+	Emoji string
+}
+
+var typeInfos = map[api.MovieType]typeInfo{
+	api.TypeMovie:          {"Фильм", "🎬"},
+	api.TypeTVSeries:       {"Сериал", "📺"},
+	api.TypeCartoon:        {"Мультфильм", "🧸"},
+	api.TypeAnime:          {"Аниме", "🌸"},
+	api.TypeAnimatedSeries: {"Мультсериал", "🎨"},
+	api.TypeTVShow:         {"ТВ-шоу", "🎤"},
+}
+
+// Префикс фильтра по типу в поисковом запросе: «Тьма тип:сериал»
+const typeFilterPrefix = "тип:"
+
+// Название типа, у неизвестного типа - пустая строка
+func TypeName(movieType api.MovieType) string {
+	return typeInfos[movieType].Name
+}
+
+// Значок типа, у неизвестного типа - общий значок
+func TypeEmoji(movieType api.MovieType) string {
+	if info, ok := typeInfos[movieType]; ok {
+		return info.Emoji
+	}
+	return "🎞"
+}
+
+// Поисковый запрос без фильтров по типу и сами фильтры.
+// Нераспознанный фильтр остаётся частью запроса.
+func SplitTypeFilter(query string) (string, []api.MovieType) {
+	var words []string
+	var types []api.MovieType
+	for _, word := range strings.Fields(query) {
+		lower := strings.ToLower(word)
+		if !strings.HasPrefix(lower, typeFilterPrefix) {
+			words = append(words, word)
+			continue
+		}
+		found := false
+		for _, value := range strings.Split(strings.TrimPrefix(lower, typeFilterPrefix), ",") {
+			if movieType := api.ParseType(value); movieType.Known() {
+				types = append(types, movieType)
+				found = true
+			}
+		}
+		if !found {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " "), types
+}
+
+// Фильмы только указанных типов, без типов - все
+func FilterByType(movies []api.Cinema, types []api.MovieType) []api.Cinema {
+	if len(types) == 0 {
+		return movies
+	}
+	var filtered []api.Cinema
+	for _, movie := range movies {
+		movieType := movie.MovieType()
+		for _, t := range types {
+			if movieType == t {
+				filtered = append(filtered, movie)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// Подпись кнопки в результатах поиска: значок типа, название и известные тип, страна и год
+func searchLabel(movie *api.Cinema) string {
+	label := TypeEmoji(movie.MovieType()) + " " + movie.Name
+	var details []string
+	if name := TypeName(movie.MovieType()); name != "" {
+		details = append(details, name)
+	}
+	if len(movie.Countries) > 0 && movie.Countries[0].Name != "" {
+		details = append(details, movie.Countries[0].Name)
+	}
+	if movie.Year > 0 {
+		details = append(details, fmt.Sprint(movie.Year))
+	}
+	if len(details) == 0 {
+		return label
+	}
+	return fmt.Sprintf("%s (%s)", label, strings.Join(details, ", "))
+}
@@ -0,0 +1,405 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+	"github.com/luzhnov-aleksei/kinobot/streaming"
+	"github.com/luzhnov-aleksei/kinobot/watchlist"
+)
+
+const (
+	// Последнее известное состояние отслеживаемых фильмов, ключ - ID фильма
+	stateBucket = "notify_state"
+	// Отключённые уведомления, ключ - ID чата
+	mutesBucket = "notify_mutes"
+)
+
+// Префикс callback-данных кнопок уведомлений
+const CallbackPrefix = "nt:"
+
+// Бюджет запросов к API. Проверки идут небольшими пачками на каждом тике,
+// поэтому расходуются равномерно в течение суток.
+var (
+	// Фильмов в одном запросе
+	BatchSize = 10
+	// Запросов на проверки в сутки
+	DailyBudget = 24
+	// Как часто перепроверять один фильм
+	RecheckInterval = 24 * time.Hour
+	// Если за сутки к API ушло столько запросов, проверки откладываются,
+	// чтобы поиску пользователей хватило лимита
+	APIReserveThreshold = 150
+)
+
+// Последнее известное состояние фильма
+type State struct {
+	MovieID   uint32    `json:"movieId"`
+	Name      string    `json:"name"`
+	CheckedAt time.Time `json:"checkedAt"`
+	// Дата премьеры (в России, иначе мировой) в формате API
+	Premiere         string `json:"premiere,omitempty"`
+	PremiereNotified bool   `json:"premiereNotified,omitempty"`
+	Digital          string `json:"digital,omitempty"`
+	Seasons          int    `json:"seasons,omitempty"`
+	// Онлайн-кинотеатры, где фильм был при последней проверке
+	Services []string `json:"services,omitempty"`
+	// Записаны ли кинотеатры. У состояний, сохранённых до их появления, первая проверка
+	// кинотеатров только запоминает их, чтобы не прислать уведомления обо всех сразу.
+	ServicesChecked bool `json:"servicesChecked,omitempty"`
+}
+
+var (
+	mu sync.Mutex
+	// Расход бюджета за текущие сутки
+	budgetDay  string
+	budgetUsed int
+)
+
+// Отслеживаемый фильм и чаты, которые о нём уведомляются
+type tracked struct {
+	movie api.Cinema
+	chats []int64
+	// Фильм ждут ради премьеры или новых сезонов, а не только в онлайн-кинотеатрах
+	upcoming bool
+}
+
+func loadState(movieID uint32) (State, bool) {
+	var state State
+	ok := storage.Get(stateBucket, strconv.FormatUint(uint64(movieID), 10), &state)
+	return state, ok
+}
+
+func saveState(state State) {
+	if err := storage.Put(stateBucket, strconv.FormatUint(uint64(state.MovieID), 10), state); err != nil {
+		log.Println("Ошибка при сохранении состояния уведомлений:", err)
+	}
+}
+
+// Фильмы, уведомления о которых отключены в чате
+func Muted(chatID int64) []uint32 {
+	var muted []uint32
+	storage.Get(mutesBucket, strconv.FormatInt(chatID, 10), &muted)
+	return muted
+}
+
+// Удаление настроек уведомлений личного чата пользователя
+func Forget(userID int64) error {
+	return storage.Delete(mutesBucket, strconv.FormatInt(userID, 10))
+}
+
+func isMuted(chatID int64, movieID uint32) bool {
+	for _, id := range Muted(chatID) {
+		if id == movieID {
+			return true
+		}
+	}
+	return false
+}
+
+func setMuted(chatID int64, movieID uint32, muted bool) error {
+	var result []uint32
+	for _, id := range Muted(chatID) {
+		if id != movieID {
+			result = append(result, id)
+		}
+	}
+	if muted {
+		result = append(result, movieID)
+	}
+	return storage.Put(mutesBucket, strconv.FormatInt(chatID, 10), result)
+}
+
+// Стоит ли следить за фильмом: ещё не вышел или это сериал, у которого могут быть новые сезоны
+func isUpcomingOrOngoing(movie api.Cinema, now time.Time) bool {
+	if movie.IsSeries || movie.MovieType().IsSeries() {
+		return true
+	}
+	if movie.Status != "" && movie.Status != "completed" {
+		return true
+	}
+	return movie.Year == 0 || int(movie.Year) >= now.Year()
+}
+
+// Ждёт ли чат фильм в своих онлайн-кинотеатрах: подписки есть, а фильма в них пока нет
+func awaitsService(chatID int64, movie api.Cinema) bool {
+	preferred := streaming.Preferred(chatID)
+	return len(preferred) > 0 && len(streaming.Available(movie.Watchability, preferred)) == 0
+}
+
+// Непросмотренные фильмы из списков с чатами, где уведомления не отключены
+func trackedMovies(now time.Time) map[uint32]*tracked {
+	result := make(map[uint32]*tracked)
+	for _, chatID := range watchlist.Chats() {
+		for _, entry := range watchlist.Load(chatID).Unwatched() {
+			upcoming := isUpcomingOrOngoing(entry.Movie, now)
+			if !upcoming && !awaitsService(chatID, entry.Movie) {
+				continue
+			}
+			if isMuted(chatID, entry.Movie.ID) {
+				continue
+			}
+			item, ok := result[entry.Movie.ID]
+			if !ok {
+				item = &tracked{movie: entry.Movie}
+				result[entry.Movie.ID] = item
+			}
+			item.chats = append(item.chats, chatID)
+			item.upcoming = item.upcoming || upcoming
+		}
+	}
+	return result
+}
+
+// Один шаг проверки: уведомления о наступивших премьерах и перепроверка
+// одной пачки фильмов, которые давно не проверялись
+func Check(bot *tgbotapi.BotAPI, now time.Time) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	items := trackedMovies(now)
+	if len(items) == 0 {
+		return
+	}
+
+	// Наступившие премьеры известны заранее и не требуют запросов к API
+	var due []State
+	for id, item := range items {
+		state, ok := loadState(id)
+		if !ok {
+			state = State{MovieID: id, Name: item.movie.Name}
+		}
+		if state.Premiere != "" && !state.PremiereNotified {
+			if date, err := parseDate(state.Premiere); err == nil && !now.Before(date) {
+				state.PremiereNotified = true
+				saveState(state)
+				notifyChats(bot, item, fmt.Sprintf("🎬 Премьера! «%s» уже вышел (%s).", state.Name, formatDate(state.Premiere)))
+			}
+		}
+		if now.Sub(state.CheckedAt) >= RecheckInterval {
+			due = append(due, state)
+		}
+	}
+	if len(due) == 0 || !spendBudget(now) {
+		return
+	}
+
+	// Первыми проверяются ожидаемые премьеры и сериалы, фильмы, которых ждут только
+	// в онлайн-кинотеатрах, - на оставшиеся места в пачке. Внутри группы - те,
+	// что дольше всего не проверялись.
+	sort.Slice(due, func(i, j int) bool {
+		if ui, uj := items[due[i].MovieID].upcoming, items[due[j].MovieID].upcoming; ui != uj {
+			return ui
+		}
+		if !due[i].CheckedAt.Equal(due[j].CheckedAt) {
+			return due[i].CheckedAt.Before(due[j].CheckedAt)
+		}
+		return due[i].MovieID < due[j].MovieID
+	})
+	if len(due) > BatchSize {
+		due = due[:BatchSize]
+	}
+
+	ids := make([]uint32, 0, len(due))
+	for _, state := range due {
+		ids = append(ids, state.MovieID)
+	}
+	found, err := api.RequestMoviesByIDs(api.BaseURL+"/movie", ids)
+	if err != nil {
+		log.Println("Ошибка при проверке обновлений фильмов:", err)
+		return
+	}
+
+	fresh := make(map[uint32]api.Cinema, len(found))
+	for _, movie := range found {
+		fresh[movie.ID] = movie
+	}
+	for _, state := range due {
+		if movie, ok := fresh[state.MovieID]; ok {
+			firstServices, services := !state.ServicesChecked, state.Services
+			for _, text := range compare(&state, movie, now) {
+				notifyChats(bot, items[state.MovieID], text)
+			}
+			updateServices(bot, items[state.MovieID], movie, services, firstServices)
+		}
+		state.CheckedAt = now
+		saveState(state)
+	}
+}
+
+// Сравнение нового состояния фильма с сохранённым. Обновляет state
+// и возвращает тексты уведомлений. Первая проверка только запоминает состояние.
+func compare(state *State, movie api.Cinema, now time.Time) []string {
+	firstCheck := state.CheckedAt.IsZero()
+	state.Name = movie.Name
+
+	premiere, digital := "", ""
+	if movie.Premiere != nil {
+		premiere = movie.Premiere.Russia
+		if premiere == "" {
+			premiere = movie.Premiere.World
+		}
+		digital = movie.Premiere.Digital
+	}
+	seasons := len(movie.SeasonsInfo)
+
+	var notices []string
+	if !firstCheck {
+		if digital != "" && state.Digital == "" {
+			notices = append(notices, fmt.Sprintf("📺 «%s» вышел в цифровом релизе (%s).", movie.Name, formatDate(digital)))
+		}
+		if seasons > state.Seasons && state.Seasons > 0 {
+			notices = append(notices, fmt.Sprintf("🆕 У сериала «%s» анонсирован %d-й сезон.", movie.Name, seasons))
+		}
+	}
+
+	// Новая дата премьеры - повод уведомить ещё раз, когда она наступит
+	if premiere != state.Premiere {
+		state.Premiere = premiere
+		state.PremiereNotified = false
+		if date, err := parseDate(premiere); err == nil && firstCheck && !now.Before(date) {
+			state.PremiereNotified = true
+		}
+	}
+	state.Digital = digital
+	state.Seasons = seasons
+	state.Services = movie.Watchability.Names()
+	state.ServicesChecked = true
+	return notices
+}
+
+// Обновление онлайн-кинотеатров фильма в списках и уведомления чатам,
+// в чьих подписках фильм появился после прошлой проверки
+func updateServices(bot *tgbotapi.BotAPI, item *tracked, movie api.Cinema, previous []string, firstCheck bool) {
+	if item == nil {
+		return
+	}
+	for _, chatID := range item.chats {
+		if err := watchlist.SetWatchability(chatID, movie.ID, movie.Watchability); err != nil {
+			log.Println(err)
+		}
+		if firstCheck {
+			continue
+		}
+		preferred := streaming.Preferred(chatID)
+		for _, watch := range streaming.Sorted(movie.Watchability, preferred) {
+			if !containsName(preferred, watch.Name) || containsName(previous, watch.Name) {
+				continue
+			}
+			id := strconv.FormatUint(uint64(movie.ID), 10)
+			msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🍿 «%s» теперь можно посмотреть в %s.", movie.Name, watch.Name))
+			msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+				tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL("▶️ Смотреть в "+watch.Name, watch.URL)),
+				tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData("🎬 Карточка", watchlist.CallbackPrefix+"card:"+id),
+					tgbotapi.NewInlineKeyboardButtonData("🔕 Не уведомлять", CallbackPrefix+"mute:"+id),
+				),
+			)
+			sender.Post(bot, msg)
+		}
+	}
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Учёт суточного бюджета запросов. Возвращает false, если бюджет исчерпан.
+func spendBudget(now time.Time) bool {
+	day := now.UTC().Format("2006-01-02")
+	if budgetDay != day {
+		budgetDay = day
+		budgetUsed = 0
+	}
+	if budgetUsed >= DailyBudget || api.RequestsToday() >= APIReserveThreshold {
+		return false
+	}
+	budgetUsed++
+	return true
+}
+
+func notifyChats(bot *tgbotapi.BotAPI, item *tracked, text string) {
+	if item == nil {
+		return
+	}
+	id := strconv.FormatUint(uint64(item.movie.ID), 10)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🎬 Карточка", watchlist.CallbackPrefix+"card:"+id),
+		tgbotapi.NewInlineKeyboardButtonData("🔕 Не уведомлять", CallbackPrefix+"mute:"+id),
+	))
+	for _, chatID := range item.chats {
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ReplyMarkup = keyboard
+		sender.Post(bot, msg)
+	}
+}
+
+// Обработка кнопок nt:mute:<id> и nt:unmute:<id>
+func HandleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	parts := strings.Split(strings.TrimPrefix(callback.Data, CallbackPrefix), ":")
+	if len(parts) != 2 {
+		return
+	}
+	movieID, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return
+	}
+
+	chatID := callback.Message.Chat.ID
+	muted := parts[0] == "mute"
+	mu.Lock()
+	err = setMuted(chatID, uint32(movieID), muted)
+	mu.Unlock()
+	if err != nil {
+		log.Println("Ошибка при сохранении настроек уведомлений:", err)
+		return
+	}
+
+	id := strconv.FormatUint(movieID, 10)
+	toggle := tgbotapi.NewInlineKeyboardButtonData("🔕 Не уведомлять", CallbackPrefix+"mute:"+id)
+	if muted {
+		toggle = tgbotapi.NewInlineKeyboardButtonData("🔔 Снова уведомлять", CallbackPrefix+"unmute:"+id)
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🎬 Карточка", watchlist.CallbackPrefix+"card:"+id),
+		toggle,
+	))
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, callback.Message.MessageID, keyboard)
+	sender.Post(bot, edit)
+}
+
+// Фоновая проверка обновлений фильмов из списков
+func Run(bot *tgbotapi.BotAPI, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		Check(bot, now)
+	}
+}
+
+func parseDate(value string) (time.Time, error) {
+	return time.Parse(time.RFC3339, value)
+}
+
+func formatDate(value string) string {
+	date, err := parseDate(value)
+	if err != nil {
+		return value
+	}
+	return date.Format("02.01.2006")
+}
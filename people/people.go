@@ -0,0 +1,370 @@
+package people
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/media"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/render"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+)
+
+// Префикс callback-данных карточки человека:
+// pr:p:<ID> - карточка, pr:f:<ID>:<страница> - страница фильмографии, pr:m:<ID фильма> - карточка фильма
+const CallbackPrefix = "pr:"
+
+// Фильмов на одной странице фильмографии
+const PageSize = 8
+
+// Люди перезапрашиваются не чаще раза в час
+const cacheTTL = time.Hour
+
+type cachedPerson struct {
+	person *api.Person
+	at     time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[int]cachedPerson)
+)
+
+// Сколько запросов к API за сутки можно потратить, прежде чем поиск
+// перестанет искать людей, если фильмы по запросу уже нашлись
+var APIReserveThreshold = 150
+
+const usage = "Напишите имя после команды, например: /person Кристофер Нолан"
+
+func init() {
+	movies.AddSearchRow(searchRow)
+}
+
+func personData(personID int) string {
+	return fmt.Sprintf("%sp:%d", CallbackPrefix, personID)
+}
+
+func pageData(personID, page int) string {
+	return fmt.Sprintf("%sf:%d:%d", CallbackPrefix, personID, page)
+}
+
+// Похож ли запрос на имя: 2-3 слова с заглавной буквы без цифр, например «Кристофер Нолан»
+func LooksLikeName(query string) bool {
+	words := strings.Fields(query)
+	if len(words) < 2 || len(words) > 3 {
+		return false
+	}
+	for _, word := range words {
+		runes := []rune(word)
+		if !unicode.IsUpper(runes[0]) {
+			return false
+		}
+		for _, r := range runes {
+			if !unicode.IsLetter(r) && r != '-' && r != '\'' && r != '.' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Кнопка человека над результатами поиска, если запрос - его имя.
+// Это отдельный запрос к API, поэтому при исчерпанном лимите он делается,
+// только если фильмов не нашлось; человека всегда можно найти через /person.
+func searchRow(query string, movieResults []api.Cinema) []tgbotapi.InlineKeyboardButton {
+	if !LooksLikeName(query) {
+		return nil
+	}
+	if len(movieResults) > 0 && api.RequestsToday() >= APIReserveThreshold {
+		return nil
+	}
+	found, err := api.RequestPersons(api.BaseURL+"/person/search", query)
+	if err != nil {
+		log.Println("Ошибка при поиске людей:", err)
+		return nil
+	}
+	for _, person := range found {
+		if strings.EqualFold(person.Name, query) || strings.EqualFold(person.EnName, query) {
+			return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("👤 "+displayName(&person), personData(person.ID)))
+		}
+	}
+	return nil
+}
+
+// Подробная информация о человеке, из кэша или через API
+func GetPerson(personID int) (*api.Person, error) {
+	cacheMu.Lock()
+	cached, ok := cache[personID]
+	cacheMu.Unlock()
+	if ok && time.Since(cached.at) < cacheTTL {
+		return cached.person, nil
+	}
+
+	person, err := api.RequestPerson(api.BaseURL+"/person", personID)
+	if err != nil {
+		return nil, err
+	}
+	cacheMu.Lock()
+	cache[personID] = cachedPerson{person: person, at: time.Now()}
+	cacheMu.Unlock()
+	return person, nil
+}
+
+// Обработка команды /person: поиск людей по имени
+func HandleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	query := strings.TrimSpace(message.CommandArguments())
+	if query == "" {
+		sendMessage(bot, chatID, usage)
+		return
+	}
+
+	found, err := api.RequestPersons(api.BaseURL+"/person/search", query)
+	if err != nil {
+		sendMessage(bot, chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+		return
+	}
+	switch len(found) {
+	case 0:
+		sendMessage(bot, chatID, fmt.Sprintf("По запросу «%s» никого не найдено.", query))
+	case 1:
+		Show(bot, chatID, found[0].ID)
+	default:
+		var rows [][]tgbotapi.InlineKeyboardButton
+		for i := range found {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(searchLabel(&found[i]), personData(found[i].ID))))
+		}
+		msg := tgbotapi.NewMessage(chatID, "Выберите человека:")
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+		sender.Post(bot, msg)
+	}
+}
+
+// Подпись кнопки в результатах поиска: имя, профессия и год рождения
+func searchLabel(person *api.Person) string {
+	var details []string
+	if len(person.Profession) > 0 {
+		details = append(details, person.Profession[0].Value)
+	}
+	if birthday, err := time.Parse(time.RFC3339, person.Birthday); err == nil {
+		details = append(details, strconv.Itoa(birthday.Year()))
+	}
+	if len(details) == 0 {
+		return displayName(person)
+	}
+	return fmt.Sprintf("%s (%s)", displayName(person), strings.Join(details, ", "))
+}
+
+func displayName(person *api.Person) string {
+	if person.Name != "" {
+		return person.Name
+	}
+	return person.EnName
+}
+
+// Фильмография без повторов (у человека может быть несколько ролей в одном фильме),
+// лучшие по рейтингу первыми
+func Filmography(person *api.Person) []api.PersonMovie {
+	seen := make(map[uint32]bool)
+	var result []api.PersonMovie
+	for _, movie := range person.Movies {
+		if seen[movie.ID] || (movie.Name == "" && movie.AlternativeName == "") {
+			continue
+		}
+		seen[movie.ID] = true
+		result = append(result, movie)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Rating > result[j].Rating })
+	return result
+}
+
+// Карточка человека с фотографией и первой страницей фильмографии
+func Show(bot *tgbotapi.BotAPI, chatID int64, personID int) {
+	person, err := GetPerson(personID)
+	if err != nil {
+		sendMessage(bot, chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+		return
+	}
+	text := formatPerson(person)
+	keyboard := filmographyKeyboard(person, 0)
+
+	if person.Photo != "" {
+		photo := tgbotapi.NewPhoto(chatID, nil)
+		photo.Caption = text
+		photo.ParseMode = "HTML"
+		if keyboard != nil {
+			photo.ReplyMarkup = keyboard
+		}
+		_, err := media.SendPhoto(bot, photo, person.Photo)
+		if err == nil {
+			return
+		}
+		log.Println("Ошибка при отправке фотографии человека:", err)
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+	sender.Post(bot, msg)
+}
+
+func formatPerson(person *api.Person) string {
+	var sb strings.Builder
+	sb.WriteString("👤 <b>" + render.Escape(displayName(person)) + "</b>")
+	if person.EnName != "" && person.EnName != person.Name {
+		sb.WriteString(" / " + render.Escape(person.EnName))
+	}
+	sb.WriteString("\n")
+
+	var professions []string
+	for _, profession := range person.Profession {
+		professions = append(professions, profession.Value)
+	}
+	if len(professions) > 0 {
+		sb.WriteString(render.Escape(strings.Join(professions, ", ")) + "\n")
+	}
+	if birthday := formatDate(person.Birthday); birthday != "" {
+		sb.WriteString("Дата рождения: " + birthday)
+		var places []string
+		for _, place := range person.BirthPlace {
+			places = append(places, place.Value)
+		}
+		if len(places) > 0 {
+			sb.WriteString(", " + render.Escape(strings.Join(places, ", ")))
+		}
+		sb.WriteString("\n")
+	}
+	if death := formatDate(person.Death); death != "" {
+		sb.WriteString("Дата смерти: " + death + "\n")
+	}
+	if person.Age > 0 {
+		sb.WriteString(fmt.Sprintf("Возраст: %d\n", person.Age))
+	}
+	if films := Filmography(person); len(films) > 0 {
+		sb.WriteString(fmt.Sprintf("Фильмография: %d\n", len(films)))
+	}
+	sb.WriteString(fmt.Sprintf(`<a href="https://www.kinopoisk.ru/name/%d/">Страница на Кинопоиске</a>`, person.ID))
+	return sb.String()
+}
+
+// Страница фильмографии: фильмы открывают обычную карточку
+func filmographyKeyboard(person *api.Person, page int) *tgbotapi.InlineKeyboardMarkup {
+	films := Filmography(person)
+	if len(films) == 0 {
+		return nil
+	}
+	pages := (len(films) + PageSize - 1) / PageSize
+	if page < 0 || page >= pages {
+		page = 0
+	}
+	start := page * PageSize
+	end := start + PageSize
+	if end > len(films) {
+		end = len(films)
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, movie := range films[start:end] {
+		name := movie.Name
+		if name == "" {
+			name = movie.AlternativeName
+		}
+		label := "🎬 " + name
+		if movie.Rating > 0 {
+			label += fmt.Sprintf(" · КП %.1f", movie.Rating)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("%sm:%d", CallbackPrefix, movie.ID))))
+	}
+	if pages > 1 {
+		var nav []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⬅️ %d/%d", page, pages), pageData(person.ID, page-1)))
+		}
+		if page < pages-1 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d ➡️", page+2, pages), pageData(person.ID, page+1)))
+		}
+		rows = append(rows, nav)
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &keyboard
+}
+
+// Обработка кнопок карточки человека
+func HandleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	parts := strings.Split(strings.TrimPrefix(callback.Data, CallbackPrefix), ":")
+	if len(parts) < 2 {
+		return
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil || id <= 0 {
+		return
+	}
+	chatID := callback.Message.Chat.ID
+
+	switch {
+	case parts[0] == "p" && len(parts) == 2:
+		Show(bot, chatID, id)
+	case parts[0] == "m" && len(parts) == 2:
+		movie, err := movies.GetMovie(uint32(id))
+		if err != nil {
+			sendMessage(bot, chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+			return
+		}
+		movies.SendMovieCard(bot, chatID, movie)
+	case parts[0] == "f" && len(parts) == 3:
+		page, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return
+		}
+		person, err := GetPerson(id)
+		if err != nil {
+			sendMessage(bot, chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+			return
+		}
+		keyboard := filmographyKeyboard(person, page)
+		if keyboard == nil {
+			return
+		}
+		edit := tgbotapi.NewEditMessageReplyMarkup(chatID, callback.Message.MessageID, *keyboard)
+		sender.Post(bot, edit)
+	}
+}
+
+// Обработка /start person_<ID> из ссылок в подробной карточке фильма
+func HandleStart(bot *tgbotapi.BotAPI, message *tgbotapi.Message) bool {
+	args := message.CommandArguments()
+	if !strings.HasPrefix(args, movies.PersonStartPrefix) {
+		return false
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(args, movies.PersonStartPrefix))
+	if err != nil || id <= 0 {
+		return false
+	}
+	Show(bot, message.Chat.ID, id)
+	return true
+}
+
+func formatDate(value string) string {
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return ""
+	}
+	return date.Format("02.01.2006")
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}
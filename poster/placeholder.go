@@ -0,0 +1,164 @@
+package poster
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// Размер заглушки - пропорции постера 2:3
+const (
+	Width  = 600
+	Height = 900
+)
+
+// Отступ текста от края карточки
+const margin = 60
+
+// Не больше строк названия, остальное обрезается
+const maxLines = 6
+
+var (
+	background = color.RGBA{0x1c, 0x1f, 0x2b, 0xff}
+	border     = color.RGBA{0x3a, 0x40, 0x55, 0xff}
+	titleColor = color.RGBA{0xf2, 0xf2, 0xf2, 0xff}
+	accent     = color.RGBA{0xff, 0x66, 0x00, 0xff}
+)
+
+// Заглушка постера в PNG: название и год на тёмной карточке.
+// Отправляется вместо постера, если у фильма нет ни постера, ни фона.
+func Placeholder(title string, year uint16) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: background}, image.Point{}, draw.Src)
+	drawBorder(img, 24, 4)
+
+	lines, scale := layout(title)
+	lineHeight := (glyphHeight + 3) * scale
+	yearScale := 5
+	total := len(lines) * lineHeight
+	if year != 0 {
+		total += (glyphHeight + 6) * yearScale
+	}
+
+	y := (Height - total) / 2
+	for _, line := range lines {
+		drawText(img, line, y, scale, titleColor)
+		y += lineHeight
+	}
+	if year != 0 {
+		fill(img, image.Rect(Width/2-40, y, Width/2+40, y+yearScale), accent)
+		y += 3 * yearScale
+		drawText(img, strconv.Itoa(int(year)), y, yearScale, accent)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("ошибка при кодировании заглушки постера: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Разбивка названия на строки: самый крупный масштаб, при котором название
+// помещается в maxLines строк без разрыва слов. На мелком масштабе слова
+// переносятся по буквам, а лишнее обрезается многоточием.
+func layout(title string) ([]string, int) {
+	title = strings.Join(strings.Fields(title), " ")
+	if title == "" {
+		title = "Без названия"
+	}
+	longest := 0
+	for _, word := range strings.Fields(title) {
+		longest = max(longest, len([]rune(word)))
+	}
+
+	scales := []int{10, 8, 6, 5, 4}
+	var lines []string
+	var scale int
+	for _, scale = range scales {
+		width := (Width - 2*margin) / ((glyphWidth + 1) * scale)
+		lines = wrap(title, width)
+		if len(lines) <= maxLines && longest <= width {
+			return lines, scale
+		}
+	}
+	if len(lines) <= maxLines {
+		return lines, scale
+	}
+
+	lines = lines[:maxLines]
+	last := []rune(lines[maxLines-1])
+	width := (Width - 2*margin) / ((glyphWidth + 1) * scale)
+	if len(last)+3 > width {
+		last = last[:width-3]
+	}
+	lines[maxLines-1] = string(last) + "..."
+	return lines, scale
+}
+
+// Перенос по словам в строки не длиннее width символов, длинные слова режутся
+func wrap(text string, width int) []string {
+	var lines []string
+	var line []rune
+	for _, word := range strings.Fields(text) {
+		runes := []rune(word)
+		for len(runes) > width {
+			if len(line) > 0 {
+				lines = append(lines, string(line))
+				line = nil
+			}
+			lines = append(lines, string(runes[:width]))
+			runes = runes[width:]
+		}
+		if len(line) > 0 && len(line)+1+len(runes) > width {
+			lines = append(lines, string(line))
+			line = nil
+		}
+		if len(line) > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, runes...)
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// Строка текста по центру карточки, y - верхний край
+func drawText(img *image.RGBA, text string, y int, scale int, c color.Color) {
+	runes := []rune(text)
+	width := len(runes)*(glyphWidth+1)*scale - scale
+	x := (Width - width) / 2
+	for _, r := range runes {
+		g := glyphOf(r)
+		for row, bits := range g {
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(1<<(glyphWidth-1-col)) == 0 {
+					continue
+				}
+				px, py := x+col*scale, y+row*scale
+				fill(img, image.Rect(px, py, px+scale, py+scale), c)
+			}
+		}
+		x += (glyphWidth + 1) * scale
+	}
+}
+
+// Рамка толщиной thickness на расстоянии inset от края
+func drawBorder(img *image.RGBA, inset, thickness int) {
+	outer := image.Rect(inset, inset, Width-inset, Height-inset)
+	inner := outer.Inset(thickness)
+	fill(img, image.Rect(outer.Min.X, outer.Min.Y, outer.Max.X, inner.Min.Y), border)
+	fill(img, image.Rect(outer.Min.X, inner.Max.Y, outer.Max.X, outer.Max.Y), border)
+	fill(img, image.Rect(outer.Min.X, inner.Min.Y, inner.Min.X, inner.Max.Y), border)
+	fill(img, image.Rect(inner.Max.X, inner.Min.Y, outer.Max.X, inner.Max.Y), border)
+}
+
+func fill(img *image.RGBA, rect image.Rectangle, c color.Color) {
+	draw.Draw(img, rect, &image.Uniform{C: c}, image.Point{}, draw.Src)
+}
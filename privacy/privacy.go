@@ -0,0 +1,271 @@
+package privacy
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/admin"
+	"github.com/luzhnov-aleksei/kinobot/dialog"
+	"github.com/luzhnov-aleksei/kinobot/diary"
+	"github.com/luzhnov-aleksei/kinobot/digest"
+	"github.com/luzhnov-aleksei/kinobot/feedback"
+	"github.com/luzhnov-aleksei/kinobot/history"
+	"github.com/luzhnov-aleksei/kinobot/limiter"
+	"github.com/luzhnov-aleksei/kinobot/movienight"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/notify"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/streaming"
+	"github.com/luzhnov-aleksei/kinobot/transfer"
+	"github.com/luzhnov-aleksei/kinobot/watchlist"
+)
+
+// Префикс callback-данных кнопок подтверждения /forget
+const CallbackPrefix = "pv:"
+
+// Сроки хранения истории поиска и журналов (переменные окружения HISTORY_RETENTION_DAYS
+// и LOG_RETENTION_DAYS). Ноль - хранить без ограничения.
+var (
+	HistoryRetention = 90 * 24 * time.Hour
+	LogRetention     = 30 * 24 * time.Hour
+)
+
+// Разбор срока хранения в днях
+func ParseRetention(days string) (time.Duration, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(days))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("ошибка в сроке хранения %q: нужно число дней", days)
+	}
+	return time.Duration(n) * 24 * time.Hour, nil
+}
+
+// Обработка команды /mydata: что бот хранит о пользователе
+func HandleMyDataCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	if !message.Chat.IsPrivate() {
+		sendMessage(bot, message.Chat.ID, "Чтобы посмотреть или удалить свои данные, напишите /mydata или /forget боту в личные сообщения.")
+		return
+	}
+	sendMessage(bot, message.Chat.ID, Summary(message.From.ID))
+}
+
+// Обработка команды /forget: удаление данных после подтверждения
+func HandleForgetCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	if !message.Chat.IsPrivate() {
+		sendMessage(bot, message.Chat.ID, "Чтобы посмотреть или удалить свои данные, напишите /mydata или /forget боту в личные сообщения.")
+		return
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, "⚠️ Удалить все данные о вас: профиль, историю поиска, дневник, личный список, "+
+		"обращения, подписки и настройки? В списках и киновечерах групп фильмы останутся, но без вашего имени и голосов.\n\n"+
+		"Это действие нельзя отменить. Сначала можно сохранить список и дневник через /export.")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить всё", CallbackPrefix+"forget"),
+		tgbotapi.NewInlineKeyboardButtonData("Отмена", CallbackPrefix+"cancel"),
+	))
+	sender.Post(bot, msg)
+}
+
+// Обработка кнопок подтверждения: pv:forget и pv:cancel
+func HandleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	// Кнопки есть только в личке, нажать их может только сам пользователь
+	if callback.Message.Chat.ID != callback.From.ID {
+		return
+	}
+
+	text := "Удаление данных отменено."
+	if callback.Data == CallbackPrefix+"forget" {
+		text = "🗑 Все данные о вас удалены. Если снова напишете боту, он запомнит только то, что нужно для ответа."
+		if err := Forget(callback.From.ID); err != nil {
+			log.Printf("Ошибка при удалении данных пользователя [%d]: %v", callback.From.ID, err)
+			text = "Не удалось удалить часть данных, попробуйте ещё раз: /forget"
+		} else {
+			log.Printf("Данные пользователя [%d] удалены по его запросу", callback.From.ID)
+		}
+	}
+	edit := tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
+	sender.Post(bot, edit)
+}
+
+// Удаление всех данных пользователя из всех хранилищ
+func Forget(userID int64) error {
+	steps := []struct {
+		name   string
+		forget func(int64) error
+	}{
+		{"профиль", admin.Forget},
+		{"история", history.Forget},
+		{"дневник", diary.Forget},
+		{"список", watchlist.Forget},
+		{"обращения", feedback.Forget},
+		{"дайджест", digest.Forget},
+		{"уведомления", notify.Forget},
+		{"диалоги", dialog.Forget},
+		{"импорт", transfer.Forget},
+		{"онлайн-кинотеатры", streaming.Forget},
+	}
+	var failed []string
+	for _, step := range steps {
+		if err := step.forget(userID); err != nil {
+			log.Printf("Ошибка при удалении данных пользователя [%d] (%s): %v", userID, step.name, err)
+			failed = append(failed, step.name)
+		}
+	}
+
+	// Счётчик сообщений сбрасывается, а лимит, заданный администратором, остаётся
+	limiter.Reset(userID)
+	movienight.Forget(userID)
+	movies.Forget(userID)
+	if _, err := sender.PurgeDeadLetters(func(letter sender.DeadLetter) bool { return letter.ChatID != userID }); err != nil {
+		log.Println(err)
+		failed = append(failed, "журнал")
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("ошибка при удалении данных: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Сводка данных, которые бот хранит о пользователе
+func Summary(userID int64) string {
+	var sb strings.Builder
+	sb.WriteString("🔐 Что бот хранит о вас:\n\n")
+
+	if user, ok := admin.LoadUser(userID); ok {
+		name := user.FirstName
+		if user.UserName != "" {
+			name += " @" + user.UserName
+		}
+		sb.WriteString(fmt.Sprintf("👤 Профиль: %s, ID %d. Первое сообщение %s, последнее %s.\n",
+			name, userID, formatDate(user.FirstSeen), formatDate(user.LastSeen)))
+	}
+
+	h := history.Load(userID)
+	if len(h.Selections) > 0 || h.LastQuery != "" {
+		sb.WriteString(fmt.Sprintf("🔎 История: открыто фильмов - %d", len(h.Selections)))
+		if h.LastQuery != "" {
+			sb.WriteString(fmt.Sprintf(", последний запрос «%s» (%s)", h.LastQuery, formatDate(h.LastQueryAt)))
+		}
+		sb.WriteString(".\n")
+	}
+
+	if entries := diary.Load(userID).Entries; len(entries) > 0 {
+		notes := 0
+		for _, entry := range entries {
+			if entry.Note != "" {
+				notes++
+			}
+		}
+		sb.WriteString(fmt.Sprintf("📔 Дневник: оценок - %d, заметок - %d.\n", len(entries), notes))
+	}
+	if entries := watchlist.Load(userID).Entries; len(entries) > 0 {
+		sb.WriteString(fmt.Sprintf("📝 Личный список: фильмов - %d.\n", len(entries)))
+	}
+
+	added, votes := 0, 0
+	for _, chatID := range watchlist.Chats() {
+		if chatID == userID {
+			continue
+		}
+		for _, entry := range watchlist.Load(chatID).Entries {
+			if entry.AddedBy == userID {
+				added++
+			}
+			for _, voter := range entry.Votes {
+				if voter == userID {
+					votes++
+				}
+			}
+		}
+	}
+	if added > 0 || votes > 0 {
+		sb.WriteString(fmt.Sprintf("👥 Списки групп: добавлено вами - %d, голосов - %d.\n", added, votes))
+	}
+
+	tickets := 0
+	for _, ticket := range feedback.Tickets() {
+		if ticket.UserID == userID {
+			tickets++
+		}
+	}
+	if tickets > 0 {
+		sb.WriteString(fmt.Sprintf("🆘 Обращения к разработчикам: %d.\n", tickets))
+	}
+	if subscription, ok := digest.Load(userID); ok {
+		sb.WriteString(fmt.Sprintf("📰 Дайджест: %s.\n", subscription.Schedule))
+	}
+	if muted := notify.Muted(userID); len(muted) > 0 {
+		sb.WriteString(fmt.Sprintf("🔕 Отключены уведомления о фильмах: %d.\n", len(muted)))
+	}
+	if preferred := streaming.Preferred(userID); len(preferred) > 0 {
+		sb.WriteString(fmt.Sprintf("🍿 Онлайн-кинотеатры: %s.\n", strings.Join(preferred, ", ")))
+	}
+	if _, ok := dialog.Active(userID, userID); ok {
+		sb.WriteString("💬 Незавершённый диалог с ботом.\n")
+	}
+	if pending, ok := transfer.LoadPending(userID); ok {
+		sb.WriteString(fmt.Sprintf("📥 Незавершённый импорт: осталось уточнить фильмов - %d.\n", len(pending.Questions)))
+	}
+	count, limit := limiter.Usage(userID)
+	sb.WriteString(fmt.Sprintf("⏳ Лимит сообщений: использовано %d из %d за сутки (хранится в памяти до перезапуска, лимит, заданный администратором, /forget не меняет).\n", count, limit))
+
+	sb.WriteString("\n" + retentionText() + "\n")
+	sb.WriteString("/export - скачать список и дневник, /forget - удалить все данные.")
+	return sb.String()
+}
+
+func retentionText() string {
+	describe := func(retention time.Duration) string {
+		if retention <= 0 {
+			return "без ограничения"
+		}
+		return fmt.Sprintf("%d дн.", int(retention.Hours()/24))
+	}
+	return fmt.Sprintf("Сроки хранения: история поиска - %s, журнал недоставленных сообщений - %s",
+		describe(HistoryRetention), describe(LogRetention))
+}
+
+// Удаление истории поиска и журналов старше сроков хранения, а также брошенных диалогов
+func Purge(now time.Time) {
+	if purged := dialog.PurgeExpired(now); purged > 0 {
+		log.Printf("Удалено брошенных диалогов: %d", purged)
+	}
+	if HistoryRetention > 0 {
+		if purged := history.Purge(now.Add(-HistoryRetention)); purged > 0 {
+			log.Printf("Очищена устаревшая история поиска у пользователей: %d", purged)
+		}
+	}
+	if LogRetention > 0 {
+		before := now.Add(-LogRetention)
+		removed, err := sender.PurgeDeadLetters(func(letter sender.DeadLetter) bool { return !letter.At.Before(before) })
+		if err != nil {
+			log.Println(err)
+		} else if removed > 0 {
+			log.Printf("Из журнала недоставленных сообщений удалено устаревших записей: %d", removed)
+		}
+	}
+}
+
+// Фоновая очистка по срокам хранения
+func Run(interval time.Duration) {
+	Purge(time.Now())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		Purge(now)
+	}
+}
+
+func formatDate(t time.Time) string {
+	return t.Format("02.01.2006")
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}
@@ -0,0 +1,521 @@
+package random
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/render"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/watchlist"
+)
+
+// Префикс callback-данных: rn:r - другой вариант, rn:c:<ID фильма> - карточка фильма
+const CallbackPrefix = "rn:"
+
+// Фильмов в одном запросе к API и сколько страниц можно перебрать за один подбор
+const (
+	PageSize = 30
+	MaxPages = 5
+)
+
+// Сколько хранится подбор с момента команды: после этого «другой вариант» просит отправить /random заново
+const SessionTTL = 6 * time.Hour
+
+// Сколько запросов к API за сутки можно потратить, прежде чем «другой вариант»
+// перестанет загружать новые страницы: кнопки не проходят через лимит сообщений
+var APIReserveThreshold = 150
+
+var errBudgetLow = errors.New("лимит запросов к Кинопоиску на сегодня почти исчерпан")
+
+// Длина описания в сообщении с вариантом, полное описание - в карточке
+const descriptionLimit = 300
+
+const usage = "Условия можно комбинировать: /random комедия 2000-2010 кп7 сериал до120\n" +
+	"жанр - любое слово, год - 2010 или 2000-2010, рейтинг - кп7, длина - до120, тип - фильм, сериал, аниме и т.д.\n" +
+	"/random список - выбор только из списка /list."
+
+// Условия подбора. Нулевые значения - без ограничения.
+type Constraints struct {
+	Genres    []string
+	YearFrom  int
+	YearTo    int
+	MinRating float64
+	Types     []api.MovieType
+	MaxLength int
+	// Выбор из списка просмотра, а не из всего Кинопоиска
+	FromList bool
+}
+
+var (
+	yearPattern   = regexp.MustCompile(`^(\d{4})?(?:-|–)(\d{4})?$|^(\d{4})$`)
+	ratingPattern = regexp.MustCompile(`^(?:(?:кп|kp)[:>=]*(\d+(?:[.,]\d+)?)|(\d+(?:[.,]\d+)?)\+)$`)
+	lengthPattern = regexp.MustCompile(`^(?:(?:до|<=?)[:]?(\d+)(?:мин)?|(\d+)мин)$`)
+)
+
+// Условия из аргументов команды. Слово, которое не похоже на год, рейтинг,
+// длительность или тип, считается жанром.
+func Parse(args string) Constraints {
+	var c Constraints
+	for _, word := range strings.Fields(strings.ToLower(args)) {
+		switch {
+		case word == "список" || word == "list":
+			c.FromList = true
+		case yearPattern.MatchString(word):
+			match := yearPattern.FindStringSubmatch(word)
+			if match[3] != "" {
+				c.YearFrom, _ = strconv.Atoi(match[3])
+				c.YearTo = c.YearFrom
+				continue
+			}
+			c.YearFrom, _ = strconv.Atoi(match[1])
+			c.YearTo, _ = strconv.Atoi(match[2])
+		case ratingPattern.MatchString(word):
+			match := ratingPattern.FindStringSubmatch(word)
+			value := strings.Replace(match[1]+match[2], ",", ".", 1)
+			if rating, err := strconv.ParseFloat(value, 64); err == nil && rating <= 10 {
+				c.MinRating = rating
+			}
+		case lengthPattern.MatchString(word):
+			match := lengthPattern.FindStringSubmatch(word)
+			c.MaxLength, _ = strconv.Atoi(match[1] + match[2])
+		case strings.HasPrefix(word, "тип:"):
+			for _, value := range strings.Split(strings.TrimPrefix(word, "тип:"), ",") {
+				if movieType := api.ParseType(value); movieType.Known() {
+					c.Types = append(c.Types, movieType)
+				}
+			}
+		case isTypeName(word):
+			c.Types = append(c.Types, api.ParseType(word))
+		default:
+			c.Genres = append(c.Genres, word)
+		}
+	}
+	return c
+}
+
+// Тип, написанный словом. Числа ParseType понимает как typeNumber, а здесь это не тип.
+func isTypeName(word string) bool {
+	if _, err := strconv.Atoi(word); err == nil {
+		return false
+	}
+	return api.ParseType(word).Known()
+}
+
+// Выбор из списка просмотра: без условий или со словом «список». Такой выбор не обращается к API.
+func FromList(args string) bool {
+	c := Parse(args)
+	return c.FromList || c.Empty()
+}
+
+// Условия не заданы
+func (c Constraints) Empty() bool {
+	return len(c.Genres) == 0 && c.YearFrom == 0 && c.YearTo == 0 && c.MinRating == 0 &&
+		len(c.Types) == 0 && c.MaxLength == 0
+}
+
+// Условия для заголовка: «комедия, 2000-2010, КП от 7.0, Сериал, до 120 мин»
+func (c Constraints) String() string {
+	var parts []string
+	parts = append(parts, c.Genres...)
+	switch {
+	case c.YearFrom != 0 && c.YearFrom == c.YearTo:
+		parts = append(parts, fmt.Sprint(c.YearFrom))
+	case c.YearFrom != 0 && c.YearTo != 0:
+		parts = append(parts, fmt.Sprintf("%d-%d", c.YearFrom, c.YearTo))
+	case c.YearFrom != 0:
+		parts = append(parts, fmt.Sprintf("с %d", c.YearFrom))
+	case c.YearTo != 0:
+		parts = append(parts, fmt.Sprintf("до %d года", c.YearTo))
+	}
+	if c.MinRating > 0 {
+		parts = append(parts, fmt.Sprintf("КП от %.1f", c.MinRating))
+	}
+	for _, movieType := range c.Types {
+		parts = append(parts, strings.ToLower(movies.TypeName(movieType)))
+	}
+	if c.MaxLength > 0 {
+		parts = append(parts, fmt.Sprintf("до %d мин", c.MaxLength))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Параметры запроса фильмов по условиям (FilterMovies). Первыми идут фильмы с большим числом голосов.
+// Длина сериалов - в seriesLength, поэтому при смеси сериалов и фильмов её проверяет load.
+func Params(c Constraints, page int) url.Values {
+	params := url.Values{}
+	params.Set("page", strconv.Itoa(page))
+	params.Set("limit", strconv.Itoa(PageSize))
+	params.Set("sortField", "votes.kp")
+	params.Set("sortType", "-1")
+	params.Add("notNullFields", "name")
+	for _, genre := range c.Genres {
+		params.Add("genres.name", genre)
+	}
+	if c.YearFrom != 0 || c.YearTo != 0 {
+		from, to := c.YearFrom, c.YearTo
+		if from == 0 {
+			from = 1874
+		}
+		if to == 0 {
+			to = time.Now().Year() + 1
+		}
+		params.Set("year", fmt.Sprintf("%d-%d", from, to))
+	}
+	if c.MinRating > 0 {
+		params.Set("rating.kp", fmt.Sprintf("%.1f-10", c.MinRating))
+	}
+	for _, slug := range api.TypeFilter(c.Types...) {
+		params.Add("type", slug)
+	}
+	if c.MaxLength > 0 {
+		series := seriesTypes(c.Types)
+		switch {
+		case series == 0:
+			params.Set("movieLength", fmt.Sprintf("1-%d", c.MaxLength))
+		case series == len(c.Types):
+			params.Set("seriesLength", fmt.Sprintf("1-%d", c.MaxLength))
+		}
+	}
+	return params
+}
+
+// Сколько из типов многосерийные
+func seriesTypes(types []api.MovieType) int {
+	count := 0
+	for _, movieType := range types {
+		if movieType.IsSeries() {
+			count++
+		}
+	}
+	return count
+}
+
+// Подходит ли фильм из списка под условия. Неизвестная длительность условию длины не подходит.
+func Matches(c Constraints, movie *api.Cinema) bool {
+	if len(c.Genres) > 0 && !hasGenre(movie, c.Genres) {
+		return false
+	}
+	if c.YearFrom != 0 && int(movie.Year) < c.YearFrom {
+		return false
+	}
+	if c.YearTo != 0 && (movie.Year == 0 || int(movie.Year) > c.YearTo) {
+		return false
+	}
+	if c.MinRating > 0 && float64(movie.Rating.Kp) < c.MinRating {
+		return false
+	}
+	if len(movies.FilterByType([]api.Cinema{*movie}, c.Types)) == 0 {
+		return false
+	}
+	return fitsLength(c, movie)
+}
+
+// Подходит ли длительность: у сериалов - длина серии. Неизвестная длительность не подходит.
+func fitsLength(c Constraints, movie *api.Cinema) bool {
+	if c.MaxLength == 0 {
+		return true
+	}
+	length := int(movie.MovieLength)
+	if movies.IsSeries(movie) {
+		length = int(movie.SeriesLength)
+	}
+	return length != 0 && length <= c.MaxLength
+}
+
+func hasGenre(movie *api.Cinema, genres []string) bool {
+	for _, genre := range movie.Genres {
+		for _, wanted := range genres {
+			if strings.EqualFold(genre.Name, wanted) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Вариант подбора: фильм и кто добавил его в список (для выбора из списка)
+type candidate struct {
+	Movie       api.Cinema
+	AddedByName string
+}
+
+// Подбор в одном сообщении. Показанные фильмы не повторяются, пока подбор не устареет.
+type session struct {
+	// Запрос следующей страницы идёт под этой блокировкой, чтобы не задерживать другие подборы
+	mu          sync.Mutex
+	constraints Constraints
+	// Ещё не показанные варианты в случайном порядке
+	candidates []candidate
+	shown      map[uint32]api.Cinema
+	// Последняя загруженная страница API, у выбора из списка - 0
+	page    int
+	started time.Time
+}
+
+// Подборы по сообщениям, ключ - ID чата и ID сообщения
+var (
+	mu       sync.Mutex
+	sessions = make(map[string]*session)
+)
+
+func sessionKey(chatID int64, messageID int) string {
+	return fmt.Sprintf("%d:%d", chatID, messageID)
+}
+
+// Удаление устаревших подборов, вызывается под mu
+func dropExpired() {
+	for key, s := range sessions {
+		if time.Since(s.started) > SessionTTL {
+			delete(sessions, key)
+		}
+	}
+}
+
+// Следующий непоказанный вариант. Когда варианты из API кончились, загружается следующая страница.
+func (s *session) next() (candidate, bool, error) {
+	for {
+		for len(s.candidates) > 0 {
+			next := s.candidates[0]
+			s.candidates = s.candidates[1:]
+			if _, ok := s.shown[next.Movie.ID]; ok {
+				continue
+			}
+			s.shown[next.Movie.ID] = next.Movie
+			return next, true, nil
+		}
+		if s.page == 0 || s.page >= MaxPages {
+			return candidate{}, false, nil
+		}
+		if api.RequestsToday() >= APIReserveThreshold {
+			return candidate{}, false, errBudgetLow
+		}
+		found, err := s.load(s.page + 1)
+		if err != nil || found == 0 {
+			return candidate{}, false, err
+		}
+	}
+}
+
+// Загрузка страницы фильмов по условиям, возвращает число найденных фильмов.
+// При смеси сериалов и фильмов длину API не фильтрует, она проверяется здесь.
+func (s *session) load(page int) (int, error) {
+	found, err := api.FilterMovies(api.BaseURL+"/movie", Params(s.constraints, page))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при подборе случайного фильма: %v", err)
+	}
+	s.page = page
+	series := seriesTypes(s.constraints.Types)
+	mixed := series > 0 && series < len(s.constraints.Types)
+	for i := range found {
+		if !mixed || fitsLength(s.constraints, &found[i]) {
+			s.candidates = append(s.candidates, candidate{Movie: found[i]})
+		}
+	}
+	shuffle(s.candidates)
+	return len(found), nil
+}
+
+func shuffle(candidates []candidate) {
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+}
+
+// Обработка команды /random: случайный фильм из списка просмотра или из Кинопоиска по условиям
+func HandleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	c := Parse(message.CommandArguments())
+	s := &session{constraints: c, shown: make(map[uint32]api.Cinema), started: time.Now()}
+
+	if c.FromList || c.Empty() {
+		for _, entry := range watchlist.Load(chatID).Unwatched() {
+			if Matches(c, &entry.Movie) {
+				s.candidates = append(s.candidates, candidate{Movie: entry.Movie, AddedByName: entry.AddedByName})
+			}
+		}
+		shuffle(s.candidates)
+		if len(s.candidates) == 0 && c.Empty() {
+			sendMessage(bot, chatID, "В списке нет непросмотренных фильмов. Добавьте их кнопкой «➕ В список» на карточке фильма.\n\n"+
+				"Или выберите из всего Кинопоиска по условиям. "+usage)
+			return
+		}
+	} else {
+		found, err := s.load(1)
+		if err != nil {
+			log.Println(err)
+			sendMessage(bot, chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+			return
+		}
+		if found == 0 {
+			sendMessage(bot, chatID, fmt.Sprintf("Не нашлось фильмов с условиями: %s. Попробуйте смягчить их.\n\n%s", c, usage))
+			return
+		}
+	}
+
+	pick, ok, err := s.next()
+	switch {
+	case errors.Is(err, errBudgetLow):
+		sendMessage(bot, chatID, "Лимит запросов к Кинопоиску на сегодня почти исчерпан, попробуйте завтра или выберите из списка: /random список.")
+		return
+	case err != nil:
+		log.Println(err)
+		sendMessage(bot, chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+		return
+	case !ok && s.page == 0:
+		sendMessage(bot, chatID, fmt.Sprintf("В списке нет непросмотренных фильмов с условиями: %s.\n\n%s", c, usage))
+		return
+	case !ok:
+		sendMessage(bot, chatID, fmt.Sprintf("Не нашлось фильмов с условиями: %s. Попробуйте смягчить их.\n\n%s", c, usage))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, formatPick(c, pick, len(s.shown)))
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	msg.ReplyMarkup = keyboard(pick.Movie.ID)
+	sent, err := sender.Send(bot, msg)
+	if err != nil {
+		log.Println("Ошибка при отправке случайного фильма:", err)
+		return
+	}
+
+	mu.Lock()
+	dropExpired()
+	sessions[sessionKey(chatID, sent.MessageID)] = s
+	mu.Unlock()
+}
+
+// Обработка кнопок «другой вариант» и «карточка»
+func HandleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	data := strings.TrimPrefix(callback.Data, CallbackPrefix)
+
+	mu.Lock()
+	s, ok := sessions[sessionKey(chatID, callback.Message.MessageID)]
+	mu.Unlock()
+
+	if strings.HasPrefix(data, "c:") {
+		movieID, err := strconv.ParseUint(strings.TrimPrefix(data, "c:"), 10, 32)
+		if err != nil {
+			return
+		}
+		showCard(bot, chatID, s, uint32(movieID))
+		return
+	}
+	if data != "r" {
+		return
+	}
+	if !ok {
+		sendMessage(bot, chatID, "Этот подбор устарел, отправьте /random заново.")
+		return
+	}
+	reroll(bot, callback, s)
+}
+
+// Другой вариант вместо показанного в том же сообщении
+func reroll(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery, s *session) {
+	chatID := callback.Message.Chat.ID
+
+	s.mu.Lock()
+	pick, ok, err := s.next()
+	count := len(s.shown)
+	s.mu.Unlock()
+	if errors.Is(err, errBudgetLow) {
+		sendMessage(bot, chatID, "Лимит запросов к Кинопоиску на сегодня почти исчерпан: новые варианты можно будет подобрать завтра.")
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		sendMessage(bot, chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+		return
+	}
+	if !ok {
+		sendMessage(bot, chatID, "Других вариантов с такими условиями нет - все уже были показаны.")
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, callback.Message.MessageID, formatPick(s.constraints, pick, count))
+	edit.ParseMode = "HTML"
+	edit.DisableWebPagePreview = true
+	markup := keyboard(pick.Movie.ID)
+	edit.ReplyMarkup = &markup
+	sender.Post(bot, edit)
+}
+
+// Карточка фильма: показанный в подборе фильм берётся из него, без подбора - из кэша или API
+func showCard(bot *tgbotapi.BotAPI, chatID int64, s *session, movieID uint32) {
+	if s != nil {
+		s.mu.Lock()
+		movie, ok := s.shown[movieID]
+		s.mu.Unlock()
+		if ok {
+			movies.SendMovieCard(bot, chatID, &movie)
+			return
+		}
+	}
+	movie, err := movies.GetMovie(movieID)
+	if err != nil {
+		sendMessage(bot, chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+		return
+	}
+	movies.SendMovieCard(bot, chatID, movie)
+}
+
+// Текст варианта: откуда выбран, условия, краткая строка о фильме и начало описания
+func formatPick(c Constraints, pick candidate, number int) string {
+	var sb strings.Builder
+	if c.FromList || c.Empty() {
+		sb.WriteString(fmt.Sprintf("🎲 Случайный выбор из списка (добавил(а) %s)", render.Escape(pick.AddedByName)))
+	} else {
+		sb.WriteString("🎲 Случайный выбор")
+	}
+	if !c.Empty() {
+		sb.WriteString(fmt.Sprintf("\nУсловия: %s", render.Escape(c.String())))
+	}
+	sb.WriteString("\n\n")
+	sb.WriteString(movies.CompactLine(&pick.Movie))
+	if description := shortDescription(&pick.Movie); description != "" {
+		sb.WriteString("\n" + render.Escape(description))
+	}
+	sb.WriteString(fmt.Sprintf("\n\nВариант %d. Не то - нажмите «🎲 Другой», повторов не будет.", number))
+	return sb.String()
+}
+
+// Краткое описание, а без него - начало полного, обрезанное по словам
+func shortDescription(movie *api.Cinema) string {
+	if movie.ShortDescription != "" {
+		return movie.ShortDescription
+	}
+	runes := []rune(strings.TrimSpace(movie.Description))
+	if len(runes) <= descriptionLimit {
+		return string(runes)
+	}
+	cut := string(runes[:descriptionLimit])
+	if space := strings.LastIndex(cut, " "); space > 0 {
+		cut = cut[:space]
+	}
+	return cut + "…"
+}
+
+func keyboard(movieID uint32) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🎲 Другой", CallbackPrefix+"r"),
+		tgbotapi.NewInlineKeyboardButtonData("🎬 Карточка", fmt.Sprintf("%sc:%d", CallbackPrefix, movieID)),
+	))
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}
@@ -0,0 +1,141 @@
+package recommend
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/diary"
+	"github.com/luzhnov-aleksei/kinobot/history"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/watchlist"
+)
+
+const (
+	// Сколько рекомендаций показывать
+	maxRecommendations = 5
+	// Для скольких понравившихся фильмов запрашивать похожие
+	maxSimilarSources = 2
+)
+
+// Сколько запросов к API за сутки можно потратить, прежде чем /recommend перестанет
+// подбирать фильмы: остаток дневного лимита нужен поиску
+var APIReserveThreshold = 150
+
+// Сигналы о вкусе пользователя: оценки из дневника, список просмотра и история выбора.
+// Один фильм учитывается один раз - по самому сильному сигналу.
+func CollectSignals(userID int64, chatID int64) []Signal {
+	var signals []Signal
+	seen := make(map[uint32]bool)
+	add := func(movie api.Cinema, weight float64, kind SignalKind) {
+		if seen[movie.ID] {
+			return
+		}
+		seen[movie.ID] = true
+		signals = append(signals, Signal{Movie: movie, Weight: weight, Kind: kind})
+	}
+
+	for _, entry := range diary.Load(userID).Entries {
+		add(entry.Movie, ScoreWeight(entry.Score), SignalRating)
+	}
+	for _, entry := range watchlist.Load(chatID).Entries {
+		add(entry.Movie, WatchlistWeight, SignalWatchlist)
+	}
+	for _, selection := range history.Load(userID).Selections {
+		add(selection.Movie, SelectionWeight, SignalSelection)
+	}
+	return signals
+}
+
+// Обработка команды /recommend
+func HandleRecommendCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	signals := CollectSignals(message.From.ID, chatID)
+	if len(signals) == 0 {
+		sendMessage(bot, chatID, "Пока не из чего составить рекомендации: оцените просмотренные фильмы, "+
+			"добавьте что-нибудь в список или просто найдите пару фильмов.")
+		return
+	}
+
+	if api.RequestsToday() >= APIReserveThreshold {
+		sendMessage(bot, chatID, "Лимит запросов к Кинопоиску на сегодня почти исчерпан, рекомендации можно будет получить завтра.")
+		return
+	}
+
+	profile := BuildProfile(signals)
+	candidates := fetchCandidates(profile)
+	recommendations := Rank(profile, candidates, maxRecommendations)
+	if len(recommendations) == 0 {
+		sendMessage(bot, chatID, "Не удалось подобрать новые фильмы, попробуйте позже.")
+		return
+	}
+
+	// Рекомендации открываются теми же кнопками, что и результаты поиска
+	var found []api.Cinema
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	var sb strings.Builder
+	sb.WriteString("🎯 Рекомендации для вас:\n\n")
+	for i, recommendation := range recommendations {
+		movie := recommendation.Movie
+		found = append(found, movie)
+		sb.WriteString(fmt.Sprintf("%d. %s (%d) - %s\n", i+1, movie.Name, movie.Year, recommendation.Reason))
+		button := tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s (%d)", movie.Name, movie.Year), fmt.Sprint(movie.ID))
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(button))
+	}
+	movies.SetSelection(message.Chat, message.From.ID, found)
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	sender.Post(bot, msg)
+}
+
+// Кандидаты: похожие на самые понравившиеся (или отмеченные) фильмы и подборка по любимому жанру
+func fetchCandidates(profile Profile) []Candidate {
+	var candidates []Candidate
+
+	for _, signal := range profile.Sources(maxSimilarSources) {
+		source := signal.Movie
+		details, err := movies.GetDetails(source.ID)
+		if err != nil {
+			log.Println("Ошибка при получении похожих фильмов:", err)
+			continue
+		}
+		for _, similar := range details.SimilarMovies {
+			candidates = append(candidates, Candidate{Movie: similar.Cinema(), Source: &source, SourceKind: signal.Kind})
+		}
+	}
+
+	genres := profile.TopGenres(1)
+	if len(genres) > 0 {
+		params := url.Values{}
+		params.Set("page", "1")
+		params.Set("limit", "20")
+		params.Set("genres.name", genres[0])
+		params.Set("sortField", "rating.kp")
+		params.Set("sortType", "-1")
+		params.Add("notNullFields", "name")
+		minRating := profile.MinRating
+		if minRating < 6 {
+			minRating = 6
+		}
+		params.Set("rating.kp", fmt.Sprintf("%.1f-10", minRating))
+
+		found, err := api.FilterMovies(api.BaseURL+"/movie", params)
+		if err != nil {
+			log.Println("Ошибка при подборе фильмов по жанру:", err)
+		}
+		for _, movie := range found {
+			candidates = append(candidates, Candidate{Movie: movie})
+		}
+	}
+	return candidates
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}
@@ -0,0 +1,310 @@
+package recommend
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+)
+
+// Вес сигналов о вкусе пользователя
+const (
+	// Фильм из списка просмотра: пользователь хочет его посмотреть
+	WatchlistWeight = 0.5
+	// Фильм, открытый из результатов поиска
+	SelectionWeight = 0.25
+)
+
+// Вклад признаков в итоговую оценку кандидата
+const (
+	genreFactor      = 2.0
+	countryFactor    = 1.0
+	decadeFactor     = 0.5
+	typeFactor       = 1.0
+	ratingFactor     = 1.0
+	similarityFactor = 1.5
+	// Штраф за рейтинг ниже привычного пользователю
+	lowRatingPenalty = 1.0
+)
+
+// Источник сигнала о вкусе
+type SignalKind int
+
+const (
+	// Оценка из дневника: пользователь фильм видел
+	SignalRating SignalKind = iota
+	// Фильм из списка просмотра
+	SignalWatchlist
+	// Фильм, открытый из результатов поиска
+	SignalSelection
+)
+
+// Сигнал о вкусе: фильм и его вес от -1 (не понравился) до 1 (очень понравился)
+type Signal struct {
+	Movie  api.Cinema
+	Weight float64
+	Kind   SignalKind
+}
+
+// Вес оценки из дневника: 1 -> -1, 10 -> 1
+func ScoreWeight(score int) float64 {
+	return (float64(score) - 5.5) / 4.5
+}
+
+// Профиль вкуса пользователя. Веса признаков нормированы в диапазон [-1, 1].
+type Profile struct {
+	Genres    map[string]float64
+	Countries map[string]float64
+	Decades   map[int]float64
+	Types     map[api.MovieType]float64
+	// Нижняя граница привычного рейтинга КП (0 - неизвестна)
+	MinRating float64
+	// Фильмы, которые пользователь уже видел или отметил
+	Seen map[uint32]bool
+	// Понравившиеся фильмы - с положительной оценкой в дневнике - по убыванию веса
+	Liked []Signal
+	// Фильмы из списка и поиска, которые пользователь не оценивал, по убыванию веса
+	Interests []Signal
+}
+
+// Построение профиля по сигналам. Результат не зависит от порядка сигналов с равными весами.
+func BuildProfile(signals []Signal) Profile {
+	profile := Profile{
+		Genres:    make(map[string]float64),
+		Countries: make(map[string]float64),
+		Decades:   make(map[int]float64),
+		Types:     make(map[api.MovieType]float64),
+		Seen:      make(map[uint32]bool),
+	}
+
+	var ratingSum, ratingWeight float64
+	minLiked := math.MaxFloat64
+	for _, signal := range signals {
+		movie := signal.Movie
+		profile.Seen[movie.ID] = true
+		for _, genre := range movie.Genres {
+			profile.Genres[genre.Name] += signal.Weight
+		}
+		for _, country := range movie.Countries {
+			profile.Countries[country.Name] += signal.Weight
+		}
+		if movie.Year > 0 {
+			profile.Decades[decade(movie.Year)] += signal.Weight
+		}
+		if movieType := movie.MovieType(); movieType.Known() {
+			profile.Types[movieType] += signal.Weight
+		}
+
+		if signal.Weight > 0 {
+			if signal.Kind == SignalRating {
+				profile.Liked = append(profile.Liked, signal)
+			} else {
+				profile.Interests = append(profile.Interests, signal)
+			}
+			if movie.Rating.Kp > 0 {
+				ratingSum += float64(movie.Rating.Kp) * signal.Weight
+				ratingWeight += signal.Weight
+				minLiked = math.Min(minLiked, float64(movie.Rating.Kp))
+			}
+		}
+	}
+
+	normalize(profile.Genres)
+	normalize(profile.Countries)
+	normalize(profile.Decades)
+	normalize(profile.Types)
+
+	// Граница чуть ниже и среднего, и самого низкого понравившегося рейтинга
+	if ratingWeight > 0 {
+		profile.MinRating = math.Min(ratingSum/ratingWeight-1, minLiked) - 0.5
+	}
+
+	sortSignals(profile.Liked)
+	sortSignals(profile.Interests)
+	return profile
+}
+
+// Сортировка сигналов по убыванию веса, при равенстве - по ID фильма
+func sortSignals(signals []Signal) {
+	sort.SliceStable(signals, func(i, j int) bool {
+		if signals[i].Weight != signals[j].Weight {
+			return signals[i].Weight > signals[j].Weight
+		}
+		return signals[i].Movie.ID < signals[j].Movie.ID
+	})
+}
+
+// Фильмы, для которых запрашиваются похожие: сначала понравившиеся, затем из списка и поиска
+func (p Profile) Sources(limit int) []Signal {
+	sources := append(append([]Signal(nil), p.Liked...), p.Interests...)
+	if len(sources) > limit {
+		sources = sources[:limit]
+	}
+	return sources
+}
+
+// Деление всех весов на максимальный по модулю
+func normalize[K comparable](weights map[K]float64) {
+	maxAbs := 0.0
+	for _, weight := range weights {
+		maxAbs = math.Max(maxAbs, math.Abs(weight))
+	}
+	if maxAbs == 0 {
+		return
+	}
+	for key := range weights {
+		weights[key] /= maxAbs
+	}
+}
+
+func decade(year uint16) int {
+	return int(year) / 10 * 10
+}
+
+// Любимые жанры по убыванию веса
+func (p Profile) TopGenres(limit int) []string {
+	type weighted struct {
+		name   string
+		weight float64
+	}
+	var genres []weighted
+	for name, weight := range p.Genres {
+		if weight > 0 {
+			genres = append(genres, weighted{name, weight})
+		}
+	}
+	sort.Slice(genres, func(i, j int) bool {
+		if genres[i].weight != genres[j].weight {
+			return genres[i].weight > genres[j].weight
+		}
+		return genres[i].name < genres[j].name
+	})
+
+	var result []string
+	for i := 0; i < len(genres) && i < limit; i++ {
+		result = append(result, genres[i].name)
+	}
+	return result
+}
+
+// Кандидат в рекомендации. Source - фильм, похожим на который является кандидат,
+// SourceKind - откуда пользователь его знает.
+type Candidate struct {
+	Movie      api.Cinema
+	Source     *api.Cinema
+	SourceKind SignalKind
+}
+
+// Рекомендация с оценкой и объяснением
+type Recommendation struct {
+	Movie  api.Cinema
+	Score  float64
+	Reason string
+}
+
+// Оценка кандидата по профилю и объяснение главной причины
+func Score(profile Profile, candidate Candidate) (float64, string) {
+	movie := candidate.Movie
+	score := 0.0
+
+	genreScore, bestGenre := averageMatch(profile.Genres, genreNames(movie))
+	score += genreFactor * genreScore
+	countryScore, bestCountry := averageMatch(profile.Countries, countryNames(movie))
+	score += countryFactor * countryScore
+	if movie.Year > 0 {
+		score += decadeFactor * profile.Decades[decade(movie.Year)]
+	}
+	if movieType := movie.MovieType(); movieType.Known() {
+		score += typeFactor * profile.Types[movieType]
+	}
+
+	kp := float64(movie.Rating.Kp)
+	if kp > 0 {
+		score += ratingFactor * math.Max(-1, math.Min(1, (kp-6)/4))
+		if profile.MinRating > 0 && kp < profile.MinRating {
+			score -= lowRatingPenalty
+		}
+	}
+	if candidate.Source != nil {
+		score += similarityFactor
+	}
+
+	switch {
+	case candidate.Source != nil && candidate.SourceKind == SignalWatchlist:
+		return score, fmt.Sprintf("похож на «%s» из вашего списка", candidate.Source.Name)
+	case candidate.Source != nil && candidate.SourceKind == SignalSelection:
+		return score, fmt.Sprintf("похож на «%s», который вы недавно открывали", candidate.Source.Name)
+	case candidate.Source != nil:
+		return score, fmt.Sprintf("потому что вам понравился «%s»", candidate.Source.Name)
+	case bestGenre != "" && profile.Genres[bestGenre] > 0:
+		return score, fmt.Sprintf("вы любите жанр «%s»", bestGenre)
+	case bestCountry != "" && profile.Countries[bestCountry] > 0:
+		return score, fmt.Sprintf("вы часто смотрите фильмы из страны «%s»", bestCountry)
+	default:
+		return score, fmt.Sprintf("высокий рейтинг КП: %.1f", kp)
+	}
+}
+
+// Средний вес совпавших признаков и признак с наибольшим весом
+func averageMatch(weights map[string]float64, names []string) (float64, string) {
+	if len(names) == 0 {
+		return 0, ""
+	}
+	sum := 0.0
+	best := ""
+	for _, name := range names {
+		sum += weights[name]
+		if best == "" || weights[name] > weights[best] {
+			best = name
+		}
+	}
+	return sum / float64(len(names)), best
+}
+
+func genreNames(movie api.Cinema) []string {
+	names := make([]string, 0, len(movie.Genres))
+	for _, genre := range movie.Genres {
+		names = append(names, genre.Name)
+	}
+	return names
+}
+
+func countryNames(movie api.Cinema) []string {
+	names := make([]string, 0, len(movie.Countries))
+	for _, country := range movie.Countries {
+		names = append(names, country.Name)
+	}
+	return names
+}
+
+// Ранжирование кандидатов: без уже виденных и повторов, по убыванию оценки,
+// при равенстве - по ID фильма, чтобы результат был детерминированным
+func Rank(profile Profile, candidates []Candidate, limit int) []Recommendation {
+	best := make(map[uint32]Recommendation)
+	for _, candidate := range candidates {
+		if candidate.Movie.ID == 0 || candidate.Movie.Name == "" || profile.Seen[candidate.Movie.ID] {
+			continue
+		}
+		score, reason := Score(profile, candidate)
+		if prev, ok := best[candidate.Movie.ID]; ok && prev.Score >= score {
+			continue
+		}
+		best[candidate.Movie.ID] = Recommendation{Movie: candidate.Movie, Score: score, Reason: reason}
+	}
+
+	result := make([]Recommendation, 0, len(best))
+	for _, recommendation := range best {
+		result = append(result, recommendation)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].Movie.ID < result[j].Movie.ID
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
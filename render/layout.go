@@ -0,0 +1,167 @@
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Виды карточек
+const (
+	// Постер с подписью - вид по умолчанию
+	LayoutPhoto = "photo"
+	// Одна строка на фильм: для списков
+	LayoutCompact = "compact"
+	// Текст с полным описанием и всеми сведениями о фильме
+	LayoutDetailed = "detailed"
+	// Таблица из 2-3 фильмов рядом
+	LayoutCompare = "compare"
+)
+
+// Вид карточки: как из данных фильмов получается текст сообщения
+type Layout interface {
+	// Текст карточки - подпись к постеру, а не отдельное сообщение
+	Photo() bool
+	Render(locale string, items ...CardData) (Card, error)
+}
+
+var layouts = map[string]Layout{
+	LayoutPhoto:    single{name: "caption", limit: CaptionLimit, photo: true},
+	LayoutDetailed: single{name: "detailed", limit: MessageLimit},
+	LayoutCompact:  compact{},
+	LayoutCompare:  compare{},
+}
+
+// Вид карточки по названию
+func GetLayout(name string) (Layout, bool) {
+	layout, ok := layouts[name]
+	return layout, ok
+}
+
+// Карточка в виде layout на языке locale
+func Render(layout, locale string, items ...CardData) (Card, error) {
+	l, ok := layouts[layout]
+	if !ok {
+		return Card{}, fmt.Errorf("ошибка при подготовке карточки: неизвестный вид %q", layout)
+	}
+	return l.Render(locale, items...)
+}
+
+// Карточка одного фильма по шаблону name
+type single struct {
+	name  string
+	limit int
+	photo bool
+}
+
+func (s single) Photo() bool {
+	return s.photo
+}
+
+func (s single) Render(locale string, items ...CardData) (Card, error) {
+	if len(items) != 1 {
+		return Card{}, fmt.Errorf("ошибка при подготовке карточки: вид %s показывает один фильм, а не %d", s.name, len(items))
+	}
+	return renderSingle(lookup(locale), s.name, s.limit, items[0])
+}
+
+// Строка на каждый фильм
+type compact struct{}
+
+func (compact) Photo() bool {
+	return false
+}
+
+func (compact) Render(locale string, items ...CardData) (Card, error) {
+	tmpl := lookup(locale)
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		line, err := execute(tmpl, "compact", newView(item))
+		if err != nil {
+			return Card{}, err
+		}
+		lines = append(lines, line)
+	}
+	text := strings.Join(lines, "\n")
+	if Length(text) > MessageLimit {
+		return Card{}, fmt.Errorf("ошибка при подготовке карточки: список длиннее %d символов", MessageLimit)
+	}
+	return Card{Text: text}, nil
+}
+
+// Сравнение фильмов: моноширинная таблица, столбец на фильм
+type compare struct{}
+
+// Ширина таблицы сравнения и столбца с подписями в символах - столько помещается на экране телефона
+const (
+	tableWidth = 34
+	labelWidth = 8
+)
+
+// Данные шаблона сравнения. Строки не экранированы: ячейки экранирует cell, остальное - escape.
+type compareView struct {
+	Label int
+	Width int
+	Items []compareItem
+}
+
+// Фильм в сравнении с отметками лучших значений в строках рейтингов и голосов
+type compareItem struct {
+	CardData
+	BestKp        bool
+	BestImdb      bool
+	BestKpVotes   bool
+	BestImdbVotes bool
+}
+
+func (compare) Photo() bool {
+	return false
+}
+
+func (compare) Render(locale string, items ...CardData) (Card, error) {
+	if len(items) < 2 || len(items) > 3 {
+		return Card{}, fmt.Errorf("ошибка при подготовке сравнения: нужно от 2 до 3 фильмов, а не %d", len(items))
+	}
+	view := compareView{Label: labelWidth, Width: (tableWidth - labelWidth) / len(items), Items: make([]compareItem, len(items))}
+	kp, imdb := make([]float64, len(items)), make([]float64, len(items))
+	kpVotes, imdbVotes := make([]float64, len(items)), make([]float64, len(items))
+	for i, item := range items {
+		kp[i], imdb[i] = float64(item.Kp), float64(item.Imdb)
+		kpVotes[i], imdbVotes[i] = float64(item.KpVotes), float64(item.ImdbVotes)
+	}
+	bestKp, bestImdb, bestKpVotes, bestImdbVotes := best(kp), best(imdb), best(kpVotes), best(imdbVotes)
+	for i, item := range items {
+		view.Items[i] = compareItem{CardData: item, BestKp: bestKp[i], BestImdb: bestImdb[i],
+			BestKpVotes: bestKpVotes[i], BestImdbVotes: bestImdbVotes[i]}
+	}
+	text, err := execute(lookup(locale), "compare", view)
+	if err != nil {
+		return Card{}, err
+	}
+
+	return Card{Text: trailingSpaces.ReplaceAllString(text, "$1")}, nil
+}
+
+// Лучшие значения строки - максимальные. Если все значения равны, лучшего нет.
+func best(values []float64) []bool {
+	max, min := values[0], values[0]
+	for _, value := range values {
+		if value > max {
+			max = value
+		}
+		if value < min {
+			min = value
+		}
+	}
+	marks := make([]bool, len(values))
+	if max == min {
+		return marks
+	}
+	for i, value := range values {
+		marks[i] = value == max
+	}
+	return marks
+}
+
+// Пробелы в конце строк таблицы, которые оставляет cell
+var trailingSpaces = regexp.MustCompile(` +(\n|</pre>)`)
@@ -0,0 +1,322 @@
+package render
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Шаблоны карточек по языкам: templates/card.<язык>.tmpl.
+// Новый язык - новый файл с шаблонами caption, detailed, compact, compare, overflow и not_found.
+//
+//go:embed templates/*.tmpl
+var files embed.FS
+
+// Язык карточек по умолчанию и для языков без своего шаблона
+const DefaultLocale = "ru"
+
+// Лимиты Telegram в символах UTF-16 после разбора HTML: подпись к фото и текст сообщения
+const (
+	CaptionLimit = 1024
+	MessageLimit = 4096
+)
+
+var cards = make(map[string]*template.Template)
+
+// Функции шаблонов. escape и cell нужны там, где строки не экранированы заранее (сравнение).
+var funcs = template.FuncMap{
+	"join":   strings.Join,
+	"first":  first,
+	"escape": Escape,
+	"cell":   cell,
+	"votes":  votes,
+	"mark":   mark,
+}
+
+func init() {
+	names, err := fs.Glob(files, "templates/card.*.tmpl")
+	if err != nil {
+		panic(err)
+	}
+	for _, name := range names {
+		base := path.Base(name)
+		locale := strings.TrimSuffix(strings.TrimPrefix(base, "card."), ".tmpl")
+		cards[locale] = template.Must(template.New(base).Funcs(funcs).ParseFS(files, name))
+	}
+}
+
+// Данные карточки фильма - обычный текст, экранирование делает Card
+type CardData struct {
+	Type        string
+	Name        string
+	Year        uint16
+	AgeRating   uint16
+	Genres      []string
+	Countries   []string
+	Description string
+	// Длительность в минутах
+	Length uint16
+	Kp     float32
+	Imdb   float32
+	URL    string
+	// Число голосов за рейтинги
+	KpVotes   int
+	ImdbVotes int
+
+	// Сериал: вместо длительности показываются сезоны, серии и состояние
+	Series       bool
+	SeriesStatus string
+	// Длительность серии в минутах
+	SeriesLength uint16
+
+	// Поля подробной карточки
+	AlternativeName string
+	Status          string
+	// Даты премьер, уже в виде для показа
+	PremiereWorld   string
+	PremiereRussia  string
+	PremiereDigital string
+	Seasons         int
+	Episodes        int
+	// Режиссёры и актёры, ссылки ведут в карточку человека в боте
+	Directors []Link
+	Cast      []Link
+}
+
+// Имя со ссылкой, без ссылки показывается просто имя
+type Link struct {
+	Name string
+	URL  string
+}
+
+// Готовая карточка: подпись к постеру или текст сообщения и продолжение описания отдельным сообщением
+type Card struct {
+	Text     string
+	Overflow string
+}
+
+// Данные для шаблона: строки экранированы, Text - показываемая часть описания
+type cardView struct {
+	Type      string
+	Name      string
+	Year      uint16
+	AgeRating uint16
+	Genres    []string
+	Countries []string
+	Hours     int
+	Minutes   int
+	Kp        float32
+	Imdb      float32
+	URL       string
+	Text      string
+	// Описание продолжается в следующей части
+	Continued bool
+
+	Series       bool
+	SeriesStatus string
+	SeriesLength uint16
+
+	AlternativeName string
+	Status          string
+	PremiereWorld   string
+	PremiereRussia  string
+	PremiereDigital string
+	Seasons         int
+	Episodes        int
+	Directors       []Link
+	Cast            []Link
+}
+
+func newView(data CardData) cardView {
+	return cardView{
+		Type:            Escape(data.Type),
+		Name:            Escape(data.Name),
+		Year:            data.Year,
+		AgeRating:       data.AgeRating,
+		Genres:          escapeAll(data.Genres),
+		Countries:       escapeAll(data.Countries),
+		Hours:           int(data.Length) / 60,
+		Minutes:         int(data.Length) % 60,
+		Kp:              data.Kp,
+		Imdb:            data.Imdb,
+		URL:             Escape(data.URL),
+		Series:          data.Series,
+		SeriesStatus:    Escape(data.SeriesStatus),
+		SeriesLength:    data.SeriesLength,
+		AlternativeName: Escape(data.AlternativeName),
+		Status:          Escape(data.Status),
+		PremiereWorld:   Escape(data.PremiereWorld),
+		PremiereRussia:  Escape(data.PremiereRussia),
+		PremiereDigital: Escape(data.PremiereDigital),
+		Seasons:         data.Seasons,
+		Episodes:        data.Episodes,
+		Directors:       escapeLinks(data.Directors),
+		Cast:            escapeLinks(data.Cast),
+	}
+}
+
+func escapeLinks(links []Link) []Link {
+	var escaped []Link
+	for _, link := range links {
+		escaped = append(escaped, Link{Name: Escape(link.Name), URL: Escape(link.URL)})
+	}
+	return escaped
+}
+
+func lookup(locale string) *template.Template {
+	if tmpl, ok := cards[locale]; ok {
+		return tmpl
+	}
+	return cards[DefaultLocale]
+}
+
+// Карточка фильма с постером на языке locale. Если подпись длиннее CaptionLimit, описание обрезается
+// по словам, а остаток уходит в Overflow (не длиннее MessageLimit).
+func RenderCard(locale string, data CardData) (Card, error) {
+	return renderSingle(lookup(locale), "caption", CaptionLimit, data)
+}
+
+// Карточка одного фильма по шаблону name: описание, не уместившееся в limit, уходит в Overflow
+func renderSingle(tmpl *template.Template, name string, limit int, data CardData) (Card, error) {
+	if data.Name == "" {
+		text, err := execute(tmpl, "not_found", nil)
+		return Card{Text: text}, err
+	}
+
+	view := newView(data)
+	description := []rune(strings.TrimSpace(data.Description))
+
+	text, cut, err := fit(description, limit, func(text []rune, continued bool) (string, error) {
+		view.Text, view.Continued = Escape(string(text)), continued
+		return execute(tmpl, name, view)
+	})
+	if err != nil || cut == len(description) {
+		return Card{Text: text}, err
+	}
+
+	rest := []rune(strings.TrimLeftFunc(string(description[cut:]), unicode.IsSpace))
+	overflow, _, err := fit(rest, MessageLimit, func(text []rune, continued bool) (string, error) {
+		view.Text, view.Continued = Escape(string(text)), continued
+		return execute(tmpl, "overflow", view)
+	})
+	return Card{Text: text, Overflow: overflow}, err
+}
+
+// Самый длинный префикс текста по границе слова, с которым результат render укладывается в limit.
+// Возвращает результат и длину префикса в символах.
+func fit(text []rune, limit int, render func(text []rune, continued bool) (string, error)) (string, int, error) {
+	out, err := render(text, false)
+	if err != nil || Length(out) <= limit {
+		return out, len(text), err
+	}
+
+	// Границы слов по возрастанию: чем длиннее префикс, тем длиннее результат
+	cuts := []int{0}
+	for i := 1; i < len(text); i++ {
+		if unicode.IsSpace(text[i]) && !unicode.IsSpace(text[i-1]) {
+			cuts = append(cuts, i)
+		}
+	}
+	lo, hi := 0, len(cuts)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		out, err := render(text[:cuts[mid]], true)
+		if err != nil {
+			return "", 0, err
+		}
+		if Length(out) <= limit {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	cut := cuts[lo]
+	out, err = render(text[:cut], cut > 0)
+	if err == nil && Length(out) > limit {
+		err = fmt.Errorf("ошибка при подготовке карточки: текст без описания длиннее %d символов", limit)
+	}
+	return out, cut, err
+}
+
+func execute(tmpl *template.Template, name string, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("ошибка в шаблоне карточки %s: %v", name, err)
+	}
+	return buf.String(), nil
+}
+
+// Экранирование текста для режима HTML в Telegram
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// Экранирование строки для подстановки в HTML-шаблон
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func escapeAll(values []string) []string {
+	escaped := make([]string, len(values))
+	for i, value := range values {
+		escaped[i] = Escape(value)
+	}
+	return escaped
+}
+
+// Первые n значений списка
+func first(n int, values []string) []string {
+	if len(values) > n {
+		return values[:n]
+	}
+	return values
+}
+
+// Ячейка моноширинной таблицы: значение обрезается и дополняется пробелами до width символов
+// (последний символ всегда пробел), затем экранируется
+func cell(value string, width int) string {
+	runes := []rune(value)
+	if len(runes) > width-1 {
+		runes = append(runes[:width-2], '…')
+	}
+	return Escape(string(runes) + strings.Repeat(" ", width-len(runes)))
+}
+
+// Число голосов коротко, чтобы поместилось в ячейку: 950, 14К, 1,1М
+func votes(n int) string {
+	switch {
+	case n >= 1000000:
+		return strings.Replace(fmt.Sprintf("%.1fМ", float64(n)/1000000), ".", ",", 1)
+	case n >= 1000:
+		return fmt.Sprintf("%dК", n/1000)
+	default:
+		return fmt.Sprint(n)
+	}
+}
+
+// Отметка лучшего значения в строке сравнения
+func mark(best bool) string {
+	if best {
+		return "★"
+	}
+	return ""
+}
+
+var tags = regexp.MustCompile(`<[^>]*>`)
+
+// Текст так, как его покажет Telegram: без тегов и с раскрытыми сущностями
+func Plain(s string) string {
+	return html.UnescapeString(tags.ReplaceAllString(s, ""))
+}
+
+// Длина текста так, как её считает Telegram: без тегов, с раскрытыми сущностями, в символах UTF-16
+func Length(s string) int {
+	return len(utf16.Encode([]rune(Plain(s))))
+}
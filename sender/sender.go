@@ -0,0 +1,437 @@
+package sender
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Ограничения Telegram на отправку. Переменные, чтобы тесты могли отключить паузы.
+var (
+	// Не больше одного сообщения в секунду в один чат
+	ChatInterval = time.Second
+	// Не больше 30 запросов в секунду на всего бота
+	GlobalInterval = time.Second / 30
+	// Повторы после 429 и сетевых ошибок
+	MaxRetries = 3
+	// Пауза перед повтором после сетевой ошибки, удваивается с каждой попыткой
+	RetryDelay = time.Second
+	// Единица retry_after из ответа 429
+	RetryAfterUnit = time.Second
+	// Файл журнала недоставленных сообщений (JSON по строке на запись), пусто - только в памяти
+	DeadLetterPath = ""
+)
+
+// Сколько последних недоставленных сообщений хранится в памяти
+const maxDeadLetters = 100
+
+// Сообщение, которое не удалось доставить
+type DeadLetter struct {
+	At       time.Time `json:"at"`
+	ChatID   int64     `json:"chatId"`
+	Method   string    `json:"method"`
+	Text     string    `json:"text,omitempty"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+}
+
+// Задание на отправку
+type job struct {
+	chattable tgbotapi.Chattable
+	// Отправляет ли задание новое сообщение (на них действует ограничение чата)
+	isSend bool
+	do     func() error
+	done   chan error
+	// Ошибки, которые вызывающий обработает сам, без записи в журнал
+	handled func(error) bool
+	// Вызывается после выполнения, до следующего задания чата
+	after func(error)
+}
+
+// Очередь чата. Задания одного чата выполняются по порядку одним обработчиком.
+type chatQueue struct {
+	jobs []*job
+	// Раньше этого времени следующее сообщение в чат не отправляется
+	nextSend time.Time
+	running  bool
+}
+
+var (
+	mu     sync.Mutex
+	queues = make(map[int64]*chatQueue)
+
+	globalMu   sync.Mutex
+	globalNext time.Time
+
+	deadMu      sync.Mutex
+	deadLetters []DeadLetter
+
+	// Число заданий, которые ещё не выполнены
+	pendingMu   sync.Mutex
+	pendingCond = sync.NewCond(&pendingMu)
+	pending     int
+)
+
+// Отправка сообщения через очередь. Вызов ждёт доставки и возвращает отправленное сообщение.
+func Send(bot *tgbotapi.BotAPI, c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return SendHandled(bot, c, nil)
+}
+
+// Отправка, отказ которой вызывающий может исправить сам (например, загрузить файл иначе).
+// Ошибки, для которых handled возвращает true, не попадают в журнал недоставленных.
+func SendHandled(bot *tgbotapi.BotAPI, c tgbotapi.Chattable, handled func(error) bool) (tgbotapi.Message, error) {
+	var message tgbotapi.Message
+	err := <-enqueue(c, handled, func() error {
+		var err error
+		message, err = bot.Send(c)
+		return err
+	})
+	return message, err
+}
+
+// Отправка альбома через очередь. Возвращает сообщения альбома, handled - как в SendHandled.
+func SendMediaGroup(bot *tgbotapi.BotAPI, c tgbotapi.MediaGroupConfig, handled func(error) bool) ([]tgbotapi.Message, error) {
+	var messages []tgbotapi.Message
+	err := <-enqueue(c, handled, func() error {
+		var err error
+		messages, err = bot.SendMediaGroup(c)
+		return err
+	})
+	return messages, err
+}
+
+// Запрос, для которого не нужен ответ-сообщение (удаление, редактирование кнопок, ответ на нажатие)
+func Request(bot *tgbotapi.BotAPI, c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	var response *tgbotapi.APIResponse
+	err := <-enqueue(c, nil, func() error {
+		var err error
+		response, err = bot.Request(c)
+		return err
+	})
+	return response, err
+}
+
+// Отправка без ожидания доставки для вызовов, которым не нужен ответ.
+// Порядок в чате сохраняется, недоставленное попадает в журнал.
+func Post(bot *tgbotapi.BotAPI, c tgbotapi.Chattable) {
+	enqueue(c, nil, func() error {
+		_, err := bot.Request(c)
+		return err
+	})
+}
+
+// Отправка без ожидания, then получает отправленное сообщение или ошибку.
+// then выполняется обработчиком очереди чата и не должен ждать отправок в тот же чат.
+func PostThen(bot *tgbotapi.BotAPI, c tgbotapi.Chattable, then func(tgbotapi.Message, error)) {
+	var message tgbotapi.Message
+	chatID, isSend := chatOf(c)
+	push(chatID, &job{chattable: c, isSend: isSend, do: func() error {
+		var err error
+		message, err = bot.Send(c)
+		return err
+	}, after: func(err error) {
+		then(message, err)
+	}})
+}
+
+// Запрос без нового сообщения (удаление, редактирование), который собирается, когда до него
+// дойдёт очередь чата: например, удаление сообщения, ID которого известен только после отправки.
+// Если build вернул nil, запрос не нужен.
+func PostDeferred(bot *tgbotapi.BotAPI, chatID int64, build func() tgbotapi.Chattable) {
+	j := &job{}
+	built := false
+	j.do = func() error {
+		if !built {
+			j.chattable, built = build(), true
+		}
+		if j.chattable == nil {
+			return nil
+		}
+		_, err := bot.Request(j.chattable)
+		return err
+	}
+	push(chatID, j)
+}
+
+// Ожидание выполнения всех поставленных заданий
+func Flush() {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	for pending > 0 {
+		pendingCond.Wait()
+	}
+}
+
+func track(delta int) {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	pending += delta
+	if pending == 0 {
+		pendingCond.Broadcast()
+	}
+}
+
+// Постановка задания в очередь чата. Запросы без чата выполняются отдельно,
+// им нужен только общий лимит. Результат придёт в возвращённый канал.
+func enqueue(c tgbotapi.Chattable, handled func(error) bool, do func() error) <-chan error {
+	chatID, isSend := chatOf(c)
+	return push(chatID, &job{chattable: c, isSend: isSend, do: do, handled: handled})
+}
+
+// Постановка готового задания в очередь чата chatID
+func push(chatID int64, j *job) <-chan error {
+	j.done = make(chan error, 1)
+	track(1)
+	if chatID == 0 {
+		go func() {
+			defer track(-1)
+			finish(j, deliver(chatID, j))
+		}()
+		return j.done
+	}
+
+	mu.Lock()
+	queue, ok := queues[chatID]
+	if !ok {
+		queue = &chatQueue{}
+		queues[chatID] = queue
+	}
+	queue.jobs = append(queue.jobs, j)
+	if !queue.running {
+		queue.running = true
+		go work(chatID, queue)
+	}
+	mu.Unlock()
+
+	return j.done
+}
+
+// Обработчик очереди чата. Когда очередь пуста, он дожидается конца паузы
+// после последней отправки и удаляет очередь, чтобы не копить молчащие чаты.
+func work(chatID int64, queue *chatQueue) {
+	for {
+		mu.Lock()
+		if len(queue.jobs) == 0 {
+			if wait := time.Until(queue.nextSend); wait > 0 {
+				mu.Unlock()
+				time.Sleep(wait)
+				continue
+			}
+			queue.running = false
+			delete(queues, chatID)
+			mu.Unlock()
+			return
+		}
+		j := queue.jobs[0]
+		queue.jobs = queue.jobs[1:]
+		mu.Unlock()
+
+		if j.isSend {
+			if wait := time.Until(queue.nextSend); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		err := deliver(chatID, j)
+		if j.isSend {
+			queue.nextSend = time.Now().Add(ChatInterval)
+		}
+		finish(j, err)
+		track(-1)
+	}
+}
+
+// Результат задания: сначала обработчик after, затем ожидающий вызов
+func finish(j *job, err error) {
+	if j.after != nil {
+		j.after(err)
+	}
+	j.done <- err
+}
+
+// Выполнение задания с повторами. Ошибки, которые не исправятся повтором,
+// и исчерпанные повторы попадают в журнал недоставленных.
+func deliver(chatID int64, j *job) error {
+	delay := RetryDelay
+	for attempt := 1; ; attempt++ {
+		waitGlobal()
+		err := j.do()
+		if err == nil {
+			return nil
+		}
+
+		var tgErr *tgbotapi.Error
+		isAPIError := errors.As(err, &tgErr)
+		switch {
+		case attempt > MaxRetries:
+		case isAPIError && tgErr.Code == 429:
+			// Telegram сообщает, сколько подождать, без подсказки ждём как после сетевой ошибки
+			wait := time.Duration(tgErr.RetryAfter) * RetryAfterUnit
+			if tgErr.RetryAfter == 0 {
+				wait = delay
+				delay *= 2
+			}
+			log.Printf("Telegram ограничил отправку в чат [%d], повтор через %v", chatID, wait)
+			time.Sleep(wait)
+			continue
+		case !isAPIError || tgErr.Code >= 500:
+			// Сетевая ошибка, неразборчивый ответ или сбой на стороне Telegram - вероятно, временные
+			log.Printf("Ошибка при отправке в чат [%d], повтор через %v: %v", chatID, delay, err)
+			time.Sleep(delay)
+			delay *= 2
+			continue
+		}
+
+		if j.handled == nil || !j.handled(err) {
+			recordDeadLetter(chatID, j.chattable, err, attempt)
+		}
+		return err
+	}
+}
+
+// Ожидание своей очереди по общему лимиту бота
+func waitGlobal() {
+	globalMu.Lock()
+	now := time.Now()
+	at := globalNext
+	if at.Before(now) {
+		at = now
+	}
+	globalNext = at.Add(GlobalInterval)
+	globalMu.Unlock()
+
+	time.Sleep(time.Until(at))
+}
+
+// Чат запроса и то, отправляет ли он новое сообщение
+func chatOf(c tgbotapi.Chattable) (int64, bool) {
+	switch v := c.(type) {
+	case tgbotapi.MessageConfig:
+		return v.ChatID, true
+	case tgbotapi.PhotoConfig:
+		return v.ChatID, true
+	case tgbotapi.AnimationConfig:
+		return v.ChatID, true
+	case tgbotapi.DocumentConfig:
+		return v.ChatID, true
+	case tgbotapi.MediaGroupConfig:
+		return v.ChatID, true
+	case tgbotapi.EditMessageTextConfig:
+		return v.ChatID, false
+	case tgbotapi.EditMessageCaptionConfig:
+		return v.ChatID, false
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		return v.ChatID, false
+	case tgbotapi.EditMessageMediaConfig:
+		return v.ChatID, false
+	case tgbotapi.DeleteMessageConfig:
+		return v.ChatID, false
+	}
+	return 0, false
+}
+
+// Текст сообщения для журнала
+func textOf(c tgbotapi.Chattable) string {
+	switch v := c.(type) {
+	case tgbotapi.MessageConfig:
+		return v.Text
+	case tgbotapi.PhotoConfig:
+		return v.Caption
+	case tgbotapi.EditMessageTextConfig:
+		return v.Text
+	}
+	return ""
+}
+
+func recordDeadLetter(chatID int64, c tgbotapi.Chattable, err error, attempts int) {
+	letter := DeadLetter{
+		At:       time.Now(),
+		ChatID:   chatID,
+		Method:   fmt.Sprintf("%T", c),
+		Text:     textOf(c),
+		Error:    err.Error(),
+		Attempts: attempts,
+	}
+	log.Printf("Сообщение в чат [%d] не доставлено после %d попыток: %v", chatID, attempts, err)
+
+	deadMu.Lock()
+	defer deadMu.Unlock()
+
+	deadLetters = append(deadLetters, letter)
+	if len(deadLetters) > maxDeadLetters {
+		deadLetters = deadLetters[len(deadLetters)-maxDeadLetters:]
+	}
+	if DeadLetterPath == "" {
+		return
+	}
+
+	file, err := os.OpenFile(DeadLetterPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		log.Println("Ошибка при открытии журнала недоставленных сообщений:", err)
+		return
+	}
+	defer file.Close()
+	if err := json.NewEncoder(file).Encode(letter); err != nil {
+		log.Println("Ошибка при записи в журнал недоставленных сообщений:", err)
+	}
+}
+
+// Удаление недоставленных сообщений из памяти и журнала: остаются только те, для которых keep вернул true.
+// Возвращает число удалённых записей.
+func PurgeDeadLetters(keep func(letter DeadLetter) bool) (int, error) {
+	deadMu.Lock()
+	defer deadMu.Unlock()
+
+	var kept []DeadLetter
+	for _, letter := range deadLetters {
+		if keep(letter) {
+			kept = append(kept, letter)
+		}
+	}
+	deadLetters = kept
+	if DeadLetterPath == "" {
+		return 0, nil
+	}
+
+	content, err := os.ReadFile(DeadLetterPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при чтении журнала недоставленных сообщений: %v", err)
+	}
+	var buf bytes.Buffer
+	removed := 0
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(line, &letter); err == nil && !keep(letter) {
+			removed++
+			continue
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	if err := os.WriteFile(DeadLetterPath, buf.Bytes(), 0o644); err != nil {
+		return 0, fmt.Errorf("ошибка при записи журнала недоставленных сообщений: %v", err)
+	}
+	return removed, nil
+}
+
+// Последние недоставленные сообщения
+func DeadLetters() []DeadLetter {
+	deadMu.Lock()
+	defer deadMu.Unlock()
+	return append([]DeadLetter(nil), deadLetters...)
+}
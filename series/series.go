@@ -0,0 +1,347 @@
+package series
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/watchlist"
+)
+
+// Префикс callback-данных браузера сезонов:
+// sr:s:<ID> - сезоны, sr:e:<ID>:<сезон>:<страница> - серии сезона,
+// sr:w:<ID>:<сезон>:<серия> - отметка «посмотрели до этой серии»
+const CallbackPrefix = "sr:"
+
+// Серий на одной странице сезона
+const PageSize = 20
+
+// Сезоны сериала перезапрашиваются не чаще раза в час
+const cacheTTL = time.Hour
+
+// Сколько запросов к API за сутки можно потратить, прежде чем браузер сезонов перестанет
+// запрашивать сериалы не из кэша: кнопки не проходят через лимит сообщений
+var APIReserveThreshold = 150
+
+type cachedSeasons struct {
+	seasons []api.Season
+	at      time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[uint32]cachedSeasons)
+)
+
+func init() {
+	movies.AddCardRow(func(chatID int64, movie *api.Cinema) []tgbotapi.InlineKeyboardButton {
+		if !movies.IsSeries(movie) {
+			return nil
+		}
+		return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📺 Сезоны и серии", seasonsData(movie.ID)))
+	})
+}
+
+func seasonsData(movieID uint32) string {
+	return fmt.Sprintf("%ss:%d", CallbackPrefix, movieID)
+}
+
+func episodesData(movieID uint32, season, page int) string {
+	return fmt.Sprintf("%se:%d:%d:%d", CallbackPrefix, movieID, season, page)
+}
+
+// Сезоны сериала из кэша, если они ещё не устарели
+func cachedSeasonsOf(movieID uint32) ([]api.Season, bool) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	cached, ok := cache[movieID]
+	if !ok || time.Since(cached.at) >= cacheTTL {
+		return nil, false
+	}
+	return cached.seasons, true
+}
+
+// Сезоны сериала без нулевого (спецвыпуски), с сериями
+func Seasons(movieID uint32) ([]api.Season, error) {
+	if seasons, ok := cachedSeasonsOf(movieID); ok {
+		return seasons, nil
+	}
+
+	all, err := api.RequestSeasons(api.BaseURL+"/season", movieID)
+	if err != nil {
+		return nil, err
+	}
+	var seasons []api.Season
+	for _, season := range all {
+		if season.Number <= 0 {
+			continue
+		}
+		// Без списка серий API иногда отдаёт только их число
+		if len(season.Episodes) == 0 {
+			for i := 1; i <= season.EpisodesCount; i++ {
+				season.Episodes = append(season.Episodes, api.Episode{Number: i})
+			}
+		}
+		if len(season.Episodes) == 0 {
+			continue
+		}
+		seasons = append(seasons, season)
+	}
+
+	cacheMu.Lock()
+	cache[movieID] = cachedSeasons{seasons: seasons, at: time.Now()}
+	cacheMu.Unlock()
+	return seasons, nil
+}
+
+// Серия после серии episode сезона season. ok = false, если это последняя известная серия.
+func Next(seasons []api.Season, season, episode int) (nextSeason int, next api.Episode, ok bool) {
+	for _, s := range seasons {
+		for _, e := range s.Episodes {
+			if s.Number > season || (s.Number == season && e.Number > episode) {
+				return s.Number, e, true
+			}
+		}
+	}
+	return 0, api.Episode{}, false
+}
+
+// Обработка кнопок браузера сезонов
+func HandleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	parts := strings.Split(strings.TrimPrefix(callback.Data, CallbackPrefix), ":")
+	if len(parts) < 2 {
+		return
+	}
+	movieID, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return
+	}
+	var numbers []int
+	for _, part := range parts[2:] {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return
+		}
+		numbers = append(numbers, number)
+	}
+	chatID := callback.Message.Chat.ID
+
+	_, movieCached := movies.CachedMovie(uint32(movieID))
+	_, seasonsCached := cachedSeasonsOf(uint32(movieID))
+	if (!movieCached || !seasonsCached) && api.RequestsToday() >= APIReserveThreshold {
+		sendMessage(bot, chatID, "Лимит запросов к Кинопоиску на сегодня почти исчерпан, сезоны можно будет посмотреть завтра.")
+		return
+	}
+
+	movie, err := movies.GetMovie(uint32(movieID))
+	if err != nil {
+		sendMessage(bot, chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+		return
+	}
+	seasons, err := Seasons(movie.ID)
+	if err != nil {
+		log.Println("Ошибка при получении сезонов сериала:", err)
+		sendMessage(bot, chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+		return
+	}
+	if len(seasons) == 0 {
+		sendMessage(bot, chatID, "Сведений о сезонах этого сериала пока нет.")
+		return
+	}
+
+	var progress watchlist.Entry
+	if entry := watchlist.Load(chatID).Find(movie.ID); entry != nil {
+		progress = *entry
+	}
+
+	var text string
+	var keyboard tgbotapi.InlineKeyboardMarkup
+	switch {
+	case parts[0] == "s" && len(numbers) == 0:
+		text, keyboard = formatSeasons(movie, seasons, progress)
+	case parts[0] == "e" && len(numbers) == 2:
+		season := find(seasons, numbers[0])
+		if season == nil {
+			return
+		}
+		text, keyboard = formatEpisodes(movie, seasons, *season, numbers[1], progress)
+	case parts[0] == "w" && len(numbers) == 2:
+		season := find(seasons, numbers[0])
+		if season == nil {
+			return
+		}
+		entry, err := watchlist.SetProgress(chatID, movie.ID, numbers[0], numbers[1])
+		if err != nil {
+			log.Println(err)
+			sendMessage(bot, chatID, "Не удалось сохранить прогресс, попробуйте позже.")
+			return
+		}
+		if entry == nil {
+			sendMessage(bot, chatID, fmt.Sprintf("Прогресс хранится в списке просмотра: сначала добавьте «%s» "+
+				"кнопкой «➕ В список» на карточке сериала.", movie.Name))
+			return
+		}
+		page := 0
+		for i, episode := range season.Episodes {
+			if episode.Number == numbers[1] {
+				page = i / PageSize
+			}
+		}
+		text, keyboard = formatEpisodes(movie, seasons, *season, page, *entry)
+	default:
+		return
+	}
+	show(bot, callback.Message, text, keyboard)
+}
+
+func find(seasons []api.Season, number int) *api.Season {
+	for i := range seasons {
+		if seasons[i].Number == number {
+			return &seasons[i]
+		}
+	}
+	return nil
+}
+
+// Серия просмотрена: не позже отмеченной в прогрессе
+func watched(progress watchlist.Entry, season, episode int) bool {
+	return season < progress.Season || (season == progress.Season && episode <= progress.Episode)
+}
+
+// Строка о прогрессе: до какой серии досмотрели и какая следующая
+func progressLine(seasons []api.Season, progress watchlist.Entry) string {
+	if progress.Progress() == "" {
+		return "Нажмите номер серии, чтобы отметить, до какой серии вы досмотрели."
+	}
+	line := "Просмотрено до " + progress.Progress()
+	if season, next, ok := Next(seasons, progress.Season, progress.Episode); ok {
+		line += fmt.Sprintf(", дальше S%02dE%02d %s", season, next.Number, episodeName(next))
+	} else {
+		line += ", это последняя вышедшая серия"
+	}
+	return line + "."
+}
+
+func formatSeasons(movie *api.Cinema, seasons []api.Season, progress watchlist.Entry) (string, tgbotapi.InlineKeyboardMarkup) {
+	episodes := 0
+	for _, season := range seasons {
+		episodes += len(season.Episodes)
+	}
+	text := fmt.Sprintf("📺 «%s»: сезонов %d, серий %d\n%s\n\nВыберите сезон:",
+		movie.Name, len(seasons), episodes, progressLine(seasons, progress))
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, season := range seasons {
+		label := fmt.Sprintf("Сезон %d (%d)", season.Number, len(season.Episodes))
+		if last := season.Episodes[len(season.Episodes)-1]; watched(progress, season.Number, last.Number) {
+			label = "✅ " + label
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, episodesData(movie.ID, season.Number, 0)))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return text, tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func formatEpisodes(movie *api.Cinema, seasons []api.Season, season api.Season, page int, progress watchlist.Entry) (string, tgbotapi.InlineKeyboardMarkup) {
+	pages := (len(season.Episodes) + PageSize - 1) / PageSize
+	if page >= pages {
+		page = 0
+	}
+	start := page * PageSize
+	end := start + PageSize
+	if end > len(season.Episodes) {
+		end = len(season.Episodes)
+	}
+	nextSeason, next, hasNext := Next(seasons, progress.Season, progress.Episode)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📺 «%s», сезон %d - серии %d-%d из %d\n%s\n\n",
+		movie.Name, season.Number, start+1, end, len(season.Episodes), progressLine(seasons, progress)))
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, episode := range season.Episodes[start:end] {
+		mark, label := "▫️", strconv.Itoa(episode.Number)
+		switch {
+		case watched(progress, season.Number, episode.Number):
+			mark, label = "✅", "✓"+label
+		case hasNext && nextSeason == season.Number && next.Number == episode.Number:
+			mark = "▶️"
+		}
+		sb.WriteString(fmt.Sprintf("%s %d. %s", mark, episode.Number, episodeName(episode)))
+		if date := formatDate(episode.AirDate); date != "" {
+			sb.WriteString(" (" + date + ")")
+		}
+		sb.WriteString("\n")
+
+		data := fmt.Sprintf("%sw:%d:%d:%d", CallbackPrefix, movie.ID, season.Number, episode.Number)
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, data))
+		if len(row) == 5 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	nav := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("◀️ Сезоны", seasonsData(movie.ID)))
+	if page > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("⬅️", episodesData(movie.ID, season.Number, page-1)))
+	}
+	if page < pages-1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("➡️", episodesData(movie.ID, season.Number, page+1)))
+	}
+	rows = append(rows, nav)
+	return strings.TrimRight(sb.String(), "\n"), tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func episodeName(episode api.Episode) string {
+	switch {
+	case episode.Name != "":
+		return "«" + episode.Name + "»"
+	case episode.EnName != "":
+		return "«" + episode.EnName + "»"
+	default:
+		return fmt.Sprintf("серия %d", episode.Number)
+	}
+}
+
+func formatDate(value string) string {
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return ""
+	}
+	return date.Format("02.01.2006")
+}
+
+// Браузер открывается новым сообщением из карточки, дальше меняется на месте
+func show(bot *tgbotapi.BotAPI, message *tgbotapi.Message, text string, keyboard tgbotapi.InlineKeyboardMarkup) {
+	if message.Photo != nil || movies.IsCard(message) {
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		msg.ReplyMarkup = keyboard
+		sender.Post(bot, msg)
+		return
+	}
+	edit := tgbotapi.NewEditMessageTextAndMarkup(message.Chat.ID, message.MessageID, text, keyboard)
+	sender.Post(bot, edit)
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Данные хранятся по разделам: раздел -> ключ -> JSON-значение.
+// Пока не вызван Open, хранилище работает только в памяти.
+var (
+	mu   sync.Mutex
+	path string
+	data = make(map[string]map[string]json.RawMessage)
+	// Когда файл записывался последний раз
+	lastFlush time.Time
+)
+
+// Как часто записываются на диск изменения, сделанные через PutLazy и DeleteLazy
+var LazyFlushInterval = time.Minute
+
+// Открытие файла хранилища. Пустой путь - хранилище только в памяти.
+func Open(filePath string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	path = filePath
+	data = make(map[string]map[string]json.RawMessage)
+	if path == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении хранилища: %v", err)
+	}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return fmt.Errorf("ошибка при разборе хранилища: %v", err)
+	}
+	return nil
+}
+
+// Чтение значения в v. Возвращает false, если ключа нет.
+func Get(bucket, key string, v interface{}) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	raw, ok := data[bucket][key]
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return false
+	}
+	return true
+}
+
+// Сохранение значения с записью на диск
+func Put(bucket, key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("ошибка при сериализации значения: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if data[bucket] == nil {
+		data[bucket] = make(map[string]json.RawMessage)
+	}
+	data[bucket][key] = raw
+	return flush()
+}
+
+// Удаление значения с записью на диск
+func Delete(bucket, key string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := data[bucket][key]; !ok {
+		return nil
+	}
+	delete(data[bucket], key)
+	return flush()
+}
+
+// Сохранение значения, которое не страшно потерять (кэш). На диск оно попадает вместе
+// со следующей записью хранилища, но сама запись делается не чаще раза в LazyFlushInterval.
+func PutLazy(bucket, key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("ошибка при сериализации значения: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if data[bucket] == nil {
+		data[bucket] = make(map[string]json.RawMessage)
+	}
+	data[bucket][key] = raw
+	return lazyFlush()
+}
+
+// Удаление значения с отложенной записью, как в PutLazy
+func DeleteLazy(bucket, key string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := data[bucket][key]; !ok {
+		return nil
+	}
+	delete(data[bucket], key)
+	return lazyFlush()
+}
+
+func lazyFlush() error {
+	if time.Since(lastFlush) < LazyFlushInterval {
+		return nil
+	}
+	return flush()
+}
+
+// Отсортированный список ключей раздела
+func Keys(bucket string) []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	keys := make([]string, 0, len(data[bucket]))
+	for key := range data[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Запись всего хранилища во временный файл и его атомарная подмена
+func flush() error {
+	if path == "" {
+		return nil
+	}
+
+	content, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("ошибка при сериализации хранилища: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("ошибка при создании временного файла: %v", err)
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("ошибка при записи хранилища: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("ошибка при записи хранилища: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("ошибка при сохранении хранилища: %v", err)
+	}
+	lastFlush = time.Now()
+	return nil
+}
@@ -0,0 +1,209 @@
+package streaming
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+)
+
+// Раздел хранилища с подписками на онлайн-кинотеатры, ключ - ID пользователя
+const bucket = "streaming"
+
+// Префикс callback-данных: sv:t:<номер сервиса> - отметка в /services,
+// sv:w:<ID фильма> - «где посмотреть» на карточке из результатов поиска
+const CallbackPrefix = "sv:"
+
+// Кнопок онлайн-кинотеатров под карточкой
+const MaxButtons = 3
+
+// Онлайн-кинотеатры, которые можно отметить в /services. Названия как в API Кинопоиска.
+var Services = []string{"Кинопоиск HD", "Okko", "Иви", "Wink", "Start", "Premier", "KION", "Amediateka", "more.tv"}
+
+var mu sync.Mutex
+
+func init() {
+	movies.AddCardRow(cardRow)
+}
+
+// Онлайн-кинотеатры, на которые подписан пользователь. В личке ID чата совпадает
+// с ID пользователя, у групп своих подписок нет.
+func Preferred(userID int64) []string {
+	var preferred []string
+	storage.Get(bucket, strconv.FormatInt(userID, 10), &preferred)
+	return preferred
+}
+
+// Удаление подписок пользователя
+func Forget(userID int64) error {
+	return storage.Delete(bucket, strconv.FormatInt(userID, 10))
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Онлайн-кинотеатры фильма: сначала те, на которые подписан пользователь, дальше в порядке API
+func Sorted(watchability api.Watchability, preferred []string) []api.WatchItem {
+	var first, rest []api.WatchItem
+	for _, item := range watchability.Items {
+		if item.Name == "" || item.URL == "" {
+			continue
+		}
+		if contains(preferred, item.Name) {
+			first = append(first, item)
+		} else {
+			rest = append(rest, item)
+		}
+	}
+	return append(first, rest...)
+}
+
+// Онлайн-кинотеатры из подписок пользователя, где есть фильм
+func Available(watchability api.Watchability, preferred []string) []string {
+	var names []string
+	for _, item := range watchability.Items {
+		if contains(preferred, item.Name) {
+			names = append(names, item.Name)
+		}
+	}
+	return names
+}
+
+// Ряд ссылок «где посмотреть», подписки пользователя отмечены ⭐. В результатах поиска
+// онлайн-кинотеатров нет, для них - кнопка, которая запрашивает подробную информацию.
+func cardRow(chatID int64, movie *api.Cinema) []tgbotapi.InlineKeyboardButton {
+	watchability := movie.Watchability
+	if len(watchability.Items) == 0 {
+		if !movies.HasDetails(movie.ID) {
+			return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🍿 Где посмотреть",
+				fmt.Sprintf("%sw:%d", CallbackPrefix, movie.ID)))
+		}
+		// Подробная информация уже в кэше, запроса к API не будет
+		if details, err := movies.GetDetails(movie.ID); err == nil {
+			watchability = details.Watchability
+		}
+	}
+
+	preferred := Preferred(chatID)
+	var row []tgbotapi.InlineKeyboardButton
+	for _, item := range Sorted(watchability, preferred) {
+		if len(row) == MaxButtons {
+			break
+		}
+		label := "▶️ " + item.Name
+		if contains(preferred, item.Name) {
+			label = "⭐ " + item.Name
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonURL(label, item.URL))
+	}
+	return row
+}
+
+// Обработка команды /services: выбор онлайн-кинотеатров, на которые подписан пользователь
+func HandleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	if !message.Chat.IsPrivate() {
+		sendMessage(bot, message.Chat.ID, "Свои онлайн-кинотеатры можно отметить в личке с ботом: /services")
+		return
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, "🍿 Отметьте онлайн-кинотеатры, на которые вы подписаны. "+
+		"Они будут первыми в ссылках под карточкой, а о фильмах из списка, которые там появятся, бот напомнит.")
+	msg.ReplyMarkup = keyboard(Preferred(message.From.ID))
+	sender.Post(bot, msg)
+}
+
+func keyboard(preferred []string) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for i, name := range Services {
+		label := "⬜ " + name
+		if contains(preferred, name) {
+			label = "✅ " + name
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("%st:%d", CallbackPrefix, i)))
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// Обработка кнопок /services и «где посмотреть»
+func HandleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	if strings.HasPrefix(callback.Data, CallbackPrefix+"w:") {
+		showLinks(bot, callback)
+		return
+	}
+	toggle(bot, callback)
+}
+
+// Ссылки на онлайн-кинотеатры вместо кнопки «где посмотреть» под карточкой
+func showLinks(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	movieID, err := strconv.ParseUint(strings.TrimPrefix(callback.Data, CallbackPrefix+"w:"), 10, 32)
+	if err != nil {
+		return
+	}
+	chatID := callback.Message.Chat.ID
+	movie, err := movies.GetDetails(uint32(movieID))
+	if err != nil {
+		sendMessage(bot, chatID, fmt.Sprintf("Произошла ошибка: %s", err))
+		return
+	}
+	if len(Sorted(movie.Watchability, nil)) == 0 {
+		sendMessage(bot, chatID, fmt.Sprintf("«%s» пока нет в онлайн-кинотеатрах.", movie.Name))
+	}
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, callback.Message.MessageID, *movies.CardKeyboard(chatID, movie))
+	sender.Post(bot, edit)
+}
+
+// Отметка онлайн-кинотеатра в /services или снятие отметки
+func toggle(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	index, err := strconv.Atoi(strings.TrimPrefix(callback.Data, CallbackPrefix+"t:"))
+	if err != nil || index < 0 || index >= len(Services) {
+		return
+	}
+	userID := callback.From.ID
+	name := Services[index]
+
+	mu.Lock()
+	var preferred []string
+	for _, n := range Preferred(userID) {
+		if !strings.EqualFold(n, name) {
+			preferred = append(preferred, n)
+		}
+	}
+	if !contains(Preferred(userID), name) {
+		preferred = append(preferred, name)
+	}
+	err = storage.Put(bucket, strconv.FormatInt(userID, 10), preferred)
+	mu.Unlock()
+	if err != nil {
+		log.Println("Ошибка при сохранении онлайн-кинотеатров:", err)
+		sendMessage(bot, callback.Message.Chat.ID, "Не удалось сохранить выбор, попробуйте позже.")
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageReplyMarkup(callback.Message.Chat.ID, callback.Message.MessageID, keyboard(preferred))
+	sender.Post(bot, edit)
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}
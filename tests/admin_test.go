@@ -0,0 +1,107 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/luzhnov-aleksei/kinobot/admin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmin_ParseIDs(t *testing.T) {
+	ids, err := admin.ParseIDs(" 101, 202 ,,303")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{101, 202, 303}, ids)
+
+	_, err = admin.ParseIDs("101,abc")
+	assert.Error(t, err)
+}
+
+func TestE2E_AdminCommands(t *testing.T) {
+	c := newConversation(t)
+	owner := testUser{ID: 1601, ChatID: 1601, FirstName: "Алексей", UserName: "owner"}
+	anna := testUser{ID: 1602, ChatID: 1602, FirstName: "Анна"}
+	boris := testUser{ID: 1603, ChatID: 1603, FirstName: "Борис"}
+	admin.SetAdmins([]int64{owner.ID})
+	t.Cleanup(func() { admin.SetAdmins(nil) })
+
+	c.say(anna, "/start")
+	c.say(boris, "интерстеллар")
+
+	// Обычный пользователь видит свою статистику просмотров
+	calls := c.say(anna, "/stats")
+	assert.Contains(t, calls[0].Params.Get("text"), "📊 Статистики пока нет")
+
+	calls = c.say(owner, "/botstats")
+	text := calls[0].Params.Get("text")
+	assert.Contains(t, text, "📈 Статистика бота")
+	assert.Contains(t, text, "Пользователей: 3 (писали в личку: 3)")
+	assert.Contains(t, text, "Активны сегодня: 3, за 7 дней: 3")
+	assert.Contains(t, text, "Поисков сегодня: 1, за 7 дней: 1")
+	assert.Contains(t, text, "Запросов к API сегодня:")
+
+	// У администратора /stats - тоже личная статистика
+	calls = c.say(owner, "/stats")
+	assert.Contains(t, calls[0].Params.Get("text"), "📊 Статистики пока нет")
+
+	// Команды администратора недоступны остальным
+	calls = c.say(anna, "/ban 1603")
+	assert.NotContains(t, calls[len(calls)-1].Params.Get("text"), "заблокирован")
+
+	// Блокировка
+	calls = c.say(owner, "/ban 1603 спам")
+	assert.Equal(t, "🚫 Пользователь 1603 заблокирован.", calls[0].Params.Get("text"))
+	assert.Empty(t, c.say(boris, "/start"))
+	calls = c.say(owner, "/ban 1601")
+	assert.Equal(t, "Нельзя заблокировать администратора.", calls[0].Params.Get("text"))
+	calls = c.say(owner, "/unban 1603")
+	assert.Equal(t, "✅ Пользователь 1603 разблокирован.", calls[0].Params.Get("text"))
+	assert.NotEmpty(t, c.say(boris, "/start"))
+
+	// Лимит сообщений
+	calls = c.say(owner, "/quota 1602")
+	assert.Equal(t, "Пользователь 1602: использовано 3 из 20 сообщений за сутки.", calls[0].Params.Get("text"))
+	calls = c.say(owner, "/quota 1602 40")
+	assert.Equal(t, "Пользователь 1602: использовано 3 из 40 сообщений за сутки.", calls[0].Params.Get("text"))
+	calls = c.say(owner, "/quota 1602 reset")
+	assert.Equal(t, "Пользователь 1602: использовано 0 из 40 сообщений за сутки.", calls[0].Params.Get("text"))
+
+	c.press(boris, 0, "258687")
+	calls = c.say(owner, "/cache")
+	assert.Equal(t, "🧹 Кэш очищен, удалено фильмов: 1.", calls[0].Params.Get("text"))
+
+	// Рассылка: предпросмотр, подтверждение и доставка всем, кто писал в личку
+	calls = c.say(owner, "/broadcast Вышло обновление!")
+	preview := calls[0]
+	assert.Equal(t, "📣 Предпросмотр рассылки (получателей: 3):\n\nВышло обновление!", preview.Params.Get("text"))
+	assert.Contains(t, preview.Params.Get("reply_markup"), `"callback_data":"ad:broadcast:send"`)
+
+	assert.Len(t, c.press(anna, preview.MessageID, "ad:broadcast:send"), 1)
+	calls = c.press(owner, preview.MessageID, "ad:broadcast:send")
+	edit, ok := findCall(calls, "editMessageText")
+	assert.True(t, ok)
+	assert.Contains(t, edit.Params.Get("text"), "📣 Рассылка запущена.")
+
+	received := make(map[string]bool)
+	report := ""
+	deadline := time.Now().Add(2 * time.Second)
+	for report == "" && time.Now().Before(deadline) {
+		for _, call := range append(calls, c.tg.takeCalls()...) {
+			switch call.Params.Get("text") {
+			case "Вышло обновление!":
+				received[call.Params.Get("chat_id")] = true
+			case "📣 Рассылка завершена: доставлено 3 из 3.":
+				report = call.Params.Get("text")
+			}
+		}
+		calls = nil
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NotEmpty(t, report)
+	assert.Equal(t, map[string]bool{"1601": true, "1602": true, "1603": true}, received)
+
+	// Повторное нажатие не запускает рассылку снова
+	calls = c.press(owner, preview.MessageID, "ad:broadcast:send")
+	edit, _ = findCall(calls, "editMessageText")
+	assert.Contains(t, edit.Params.Get("text"), "уже отправлена или отменена")
+}
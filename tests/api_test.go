@@ -3,6 +3,8 @@ package api
 import (
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/luzhnov-aleksei/kinobot/api"
@@ -37,6 +39,7 @@ func mockSuccessfulResponse() *httptest.Server {
 }
 
 func TestRequestMovies_Success(t *testing.T) {
+	t.Setenv("API_KEY", "test-key")
 	server := mockSuccessfulResponse()
 	defer server.Close()
 
@@ -49,6 +52,7 @@ func TestRequestMovies_Success(t *testing.T) {
 }
 
 func TestRequestMovies_Error(t *testing.T) {
+	t.Setenv("API_KEY", "test-key")
 	server := httptest.NewServer(http.NotFoundHandler())
 	defer server.Close()
 
@@ -56,3 +60,88 @@ func TestRequestMovies_Error(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, movies)
 }
+
+const searchURL = "https://api.kinopoisk.dev/v1.4/movie/search"
+
+func TestRequestMovies_Fixture(t *testing.T) {
+	useFixture(t, "search")
+
+	movies, err := api.RequestMovies(searchURL, "интерстеллар")
+	assert.NoError(t, err)
+	assert.Len(t, movies, 3)
+
+	// фильмы отсортированы по рейтингу КП
+	assert.Equal(t, uint32(258687), movies[0].ID)
+	assert.Equal(t, "Интерстеллар", movies[0].Name)
+	assert.Equal(t, uint16(169), movies[0].MovieLength)
+	assert.Len(t, movies[0].Countries, 3)
+	assert.Equal(t, uint32(1046206), movies[1].ID)
+	assert.Nil(t, movies[1].Poster)
+	assert.Equal(t, uint32(1316601), movies[2].ID)
+	assert.Equal(t, "", movies[2].Poster.URL)
+}
+
+func TestRequestMovie_Fixture(t *testing.T) {
+	useFixture(t, "details")
+
+	movie, err := api.RequestMovie("https://api.kinopoisk.dev/v1.4/movie", 258687)
+	assert.NoError(t, err)
+	assert.Equal(t, "Интерстеллар", movie.Name)
+	assert.Equal(t, uint16(2014), movie.Year)
+	assert.Equal(t, uint16(16), movie.AgeRating)
+	assert.InDelta(t, 8.7, movie.Rating.Imdb, 0.001)
+}
+
+func TestRequestMovies_EmptyFixture(t *testing.T) {
+	useFixture(t, "search_empty")
+
+	movies, err := api.RequestMovies(searchURL, "zxqwvbnm")
+	assert.NoError(t, err)
+	assert.Empty(t, movies)
+}
+
+func TestRequestMovies_ErrorFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		message string
+	}{
+		{"search_401", "API вернул ошибку: 401 - В запросе не указан токен!"},
+		{"search_403_quota", "API вернул ошибку: 403 - Вы израсходовали ваш суточный лимит"},
+		{"search_429", "API вернул ошибку: 429 - ThrottlerException: Too Many Requests"},
+		{"search_malformed", "ошибка при разборе JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			useFixture(t, tt.fixture)
+
+			movies, err := api.RequestMovies(searchURL, "матрица")
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+			assert.Nil(t, movies)
+		})
+	}
+}
+
+func TestFixtureScrubbing(t *testing.T) {
+	const key = "SECRET-KEY-123"
+	t.Setenv("API_KEY", key)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"docs":[],"echo":"` + r.Header.Get("X-API-KEY") + `"}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "recorded.json")
+	prev := api.Client
+	api.Client = &http.Client{Transport: &recordTransport{t: t, path: path, next: http.DefaultTransport}}
+	defer func() { api.Client = prev }()
+
+	_, err := api.RequestMovies(server.URL+"/v1.4/movie/search", "test")
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), key)
+	assert.Contains(t, string(data), scrubbedKey)
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/handlers"
+	"github.com/luzhnov-aleksei/kinobot/movienight"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_Start(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 501, ChatID: 501, FirstName: "Аня", UserName: "anya"}
+
+	calls := c.say(user, "/start")
+	assert.Len(t, calls, 1)
+	assert.Equal(t, "sendMessage", calls[0].Method)
+	assert.Equal(t, "501", calls[0].Params.Get("chat_id"))
+	assert.Contains(t, calls[0].Params.Get("text"), "Привет, Аня")
+}
+
+func TestE2E_SearchDoesNotWaitForPacing(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 503, ChatID: 503, FirstName: "Вася"}
+	prev := sender.ChatInterval
+	sender.ChatInterval = 300 * time.Millisecond
+	t.Cleanup(func() { sender.ChatInterval = prev })
+
+	// GIF и список отправляются в фоне, обработка обновления не ждёт паузы между сообщениями
+	c.tg.pushUpdate(tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 1, Text: "интерстеллар",
+		From: &tgbotapi.User{ID: user.ID, FirstName: user.FirstName},
+		Chat: &tgbotapi.Chat{ID: user.ChatID, Type: "private"},
+	}})
+	updates, err := c.bot.GetUpdates(tgbotapi.UpdateConfig{Offset: c.offset})
+	if !assert.NoError(t, err) || !assert.Len(t, updates, 1) {
+		return
+	}
+	c.offset = updates[0].UpdateID + 1
+	start := time.Now()
+	handlers.HandleUpdate(c.bot, updates[0])
+	assert.Less(t, time.Since(start), sender.ChatInterval)
+
+	var methods []string
+	for _, call := range c.tg.takeCalls() {
+		methods = append(methods, call.Method)
+	}
+	assert.Equal(t, []string{"sendAnimation", "sendMessage", "deleteMessage"}, methods)
+}
+
+func TestE2E_SearchPickHitLimit(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 502, ChatID: 502, FirstName: "Боря", UserName: "borya"}
+
+	// Поиск: GIF-заглушка, список фильмов и удаление GIF
+	calls := c.say(user, "интерстеллар")
+	methods := make([]string, 0, len(calls))
+	for _, call := range calls {
+		methods = append(methods, call.Method)
+	}
+	assert.Equal(t, []string{"sendAnimation", "sendMessage", "deleteMessage"}, methods)
+	list := calls[1]
+	assert.Equal(t, "Выберите фильм:", list.Params.Get("text"))
+	assert.Contains(t, list.Params.Get("reply_markup"), `"callback_data":"258687"`)
+
+	// Выбор фильма из списка
+	calls = c.press(user, 0, "258687")
+	_, answered := findCall(calls, "answerCallbackQuery")
+	assert.True(t, answered)
+	card, ok := findCall(calls, "sendPhoto")
+	assert.True(t, ok)
+	assert.Contains(t, card.Params.Get("caption"), "Интерстеллар")
+
+	// Новый поиск удаляет прошлый запрос и список
+	calls = c.say(user, "zxqwvbnm")
+	deleted := 0
+	for _, call := range calls {
+		if call.Method == "deleteMessage" {
+			deleted++
+		}
+	}
+	assert.Equal(t, 3, deleted)
+	notFound, _ := findCall(calls, "sendMessage")
+	assert.Equal(t, "Фильм не найден, попробуйте другой запрос", notFound.Params.Get("text"))
+
+	// Лимит: всего 20 сообщений в сутки
+	for i := 3; i <= 20; i++ {
+		calls = c.say(user, "/help")
+		assert.Len(t, calls, 1, "сообщение %s", strconv.Itoa(i))
+	}
+	calls = c.say(user, "интерстеллар")
+	assert.Len(t, calls, 1)
+	assert.Equal(t, "sendMessage", calls[0].Method)
+	assert.Contains(t, calls[0].Params.Get("text"), "Вы превысили лимит сообщений")
+}
+
+func TestE2E_GroupTriggers(t *testing.T) {
+	c := newConversation(t)
+	const chatID = -1001
+	alice := testUser{ID: 601, ChatID: chatID, FirstName: "Алиса", UserName: "alice"}
+	bob := testUser{ID: 602, ChatID: chatID, FirstName: "Боб", UserName: "bob"}
+
+	// Обычная переписка и чужие команды игнорируются
+	assert.Empty(t, c.say(alice, "кто идёт в кино?"))
+	assert.Empty(t, c.say(alice, "/film@other_bot интерстеллар"))
+
+	// Поиск по команде /film не удаляет сообщения участников
+	calls := c.say(alice, "/film интерстеллар")
+	list, ok := findCall(calls, "sendMessage")
+	assert.True(t, ok)
+	assert.Equal(t, "Выберите фильм:", list.Params.Get("text"))
+
+	// Список фильмов общий: выбрать фильм может любой участник
+	calls = c.press(bob, 0, "258687")
+	_, ok = findCall(calls, "sendPhoto")
+	assert.True(t, ok)
+
+	// Упоминание и ответ на сообщение бота тоже запускают поиск,
+	// а удаляется только прошлый список бота и GIF
+	calls = c.say(bob, "@"+fakeBotName+" интерстеллар")
+	deleted := 0
+	for _, call := range calls {
+		if call.Method == "deleteMessage" {
+			deleted++
+		}
+	}
+	assert.Equal(t, 2, deleted)
+
+	botMessage := &tgbotapi.Message{MessageID: 1, From: &tgbotapi.User{ID: 1000, IsBot: true}}
+	calls = c.reply(alice, "интерстеллар", botMessage)
+	_, ok = findCall(calls, "sendAnimation")
+	assert.True(t, ok)
+}
+
+func TestE2E_GroupSettingsAndSharedQuota(t *testing.T) {
+	c := newConversation(t)
+	const chatID = -1002
+	admin := testUser{ID: 701, ChatID: chatID, FirstName: "Админ", UserName: "admin"}
+	member := testUser{ID: 702, ChatID: chatID, FirstName: "Участник", UserName: "member"}
+	c.tg.setMember(chatID, admin.ID, "administrator")
+
+	calls := c.say(member, "/settings limit 2")
+	assert.Len(t, calls, 2)
+	assert.Equal(t, "getChatMember", calls[0].Method)
+	assert.Contains(t, calls[1].Params.Get("text"), "только администраторы")
+
+	calls = c.say(admin, "/settings limit 2")
+	assert.Contains(t, calls[len(calls)-1].Params.Get("text"), "Лимит запросов в день: 2")
+
+	// Лимит общий на чат, а не на пользователя
+	assert.NotEmpty(t, c.say(admin, "/help"))
+	assert.NotEmpty(t, c.say(member, "/help"))
+	calls = c.say(member, "/film интерстеллар")
+	assert.Len(t, calls, 1)
+	assert.Contains(t, calls[0].Params.Get("text"), "Чат исчерпал общий лимит")
+
+	// Настройки доступны и после исчерпания лимита
+	calls = c.say(member, "/settings")
+	assert.Contains(t, calls[0].Params.Get("text"), "Настройки чата")
+}
+
+func TestE2E_MovieNight(t *testing.T) {
+	c := newConversation(t)
+	const chatID = -1003
+	host := testUser{ID: 801, ChatID: chatID, FirstName: "Вика", UserName: "vika"}
+	guest := testUser{ID: 802, ChatID: chatID, FirstName: "Гоша", UserName: "gosha"}
+
+	calls := c.say(host, "/movienight")
+	assert.Contains(t, calls[0].Params.Get("text"), "Киновечер начался")
+
+	// Кандидаты собираются из выбора фильмов в поиске
+	c.say(host, "/film интерстеллар")
+	calls = c.press(host, 0, "1046206")
+	added, _ := findCall(calls, "sendMessage")
+	assert.Contains(t, added.Params.Get("text"), "добавлен в кандидаты киновечера (1)")
+	calls = c.press(guest, 0, "258687")
+	added, _ = findCall(calls, "sendMessage")
+	assert.Contains(t, added.Params.Get("text"), "(2)")
+
+	// Запускать голосование может только инициатор или админ
+	calls = c.say(guest, "/movienight vote 30")
+	assert.Contains(t, calls[len(calls)-1].Params.Get("text"), "Управлять киновечером может только")
+
+	calls = c.say(host, "/movienight vote 30")
+	vote, _ := findCall(calls, "sendMessage")
+	assert.Contains(t, vote.Params.Get("text"), "Голосование за фильм")
+	assert.Contains(t, vote.Params.Get("reply_markup"), `"callback_data":"mn:1"`)
+
+	// Голоса за документалку: 2 против 0
+	calls = c.press(host, vote.MessageID, "mn:0")
+	edit, ok := findCall(calls, "editMessageText")
+	assert.True(t, ok)
+	assert.Contains(t, edit.Params.Get("text"), "Голосов: 1")
+	c.press(guest, vote.MessageID, "mn:0")
+
+	// Дедлайн ещё не наступил
+	movienight.CloseExpired(c.bot, time.Now())
+	assert.Empty(t, c.tg.takeCalls())
+
+	movienight.CloseExpired(c.bot, time.Now().Add(31*time.Minute))
+	calls = c.tg.takeCalls()
+	winner, _ := findCall(calls, "sendMessage")
+	assert.Contains(t, winner.Params.Get("text"), "Смотрим: Интерстеллар: Наука (голосов: 2)")
+	card, ok := findCall(calls, "sendPhoto")
+	assert.True(t, ok)
+	assert.Contains(t, card.Params.Get("caption"), "Интерстеллар: Наука")
+
+	calls = c.say(host, "/movienight vote")
+	assert.Contains(t, calls[0].Params.Get("text"), "Киновечер ещё не начат")
+
+	// В личке киновечер не начинается
+	loner := testUser{ID: 803, ChatID: 803, FirstName: "Лёша"}
+	calls = c.say(loner, "/movienight")
+	assert.Contains(t, calls[0].Params.Get("text"), "Киновечер проводится в группе")
+	calls = c.say(loner, "/movienight vote")
+	assert.Contains(t, calls[0].Params.Get("text"), "Киновечер проводится в группе")
+}
+
+func TestE2E_MovieNightTieBreak(t *testing.T) {
+	c := newConversation(t)
+	const chatID = -1004
+	host := testUser{ID: 901, ChatID: chatID, FirstName: "Даша", UserName: "dasha"}
+	guest := testUser{ID: 902, ChatID: chatID, FirstName: "Петя", UserName: "petya"}
+
+	startVoting := func() int {
+		c.say(host, "/movienight")
+		c.say(host, "/film интерстеллар")
+		c.press(host, 0, "1046206")
+		c.press(host, 0, "258687")
+		vote, _ := findCall(c.say(host, "/movienight vote 5"), "sendMessage")
+		return vote.MessageID
+	}
+
+	// Поровну голосов - побеждает фильм с более высоким рейтингом КП
+	voteID := startVoting()
+	c.press(host, voteID, "mn:0")
+	c.press(guest, voteID, "mn:1")
+	calls := c.say(host, "/movienight stop")
+	winner, _ := findCall(calls, "sendMessage")
+	assert.Contains(t, winner.Params.Get("text"), "Смотрим: Интерстеллар (голосов: 1)")
+	assert.Contains(t, winner.Params.Get("text"), "При равенстве голосов")
+
+	// Без голосов победителя нет
+	startVoting()
+	calls = c.say(host, "/movienight stop")
+	result, _ := findCall(calls, "sendMessage")
+	assert.Contains(t, result.Params.Get("text"), "никто не проголосовал")
+	_, ok := findCall(calls, "sendPhoto")
+	assert.False(t, ok)
+}
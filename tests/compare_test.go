@@ -0,0 +1,92 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/compare"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare_ParseQueries(t *testing.T) {
+	assert.Equal(t, []string{"Интерстеллар", "Начало"}, compare.ParseQueries(" Интерстеллар ;Начало; "))
+	assert.Equal(t, []string{"Матрица", "Тёмный рыцарь", "Престиж"}, compare.ParseQueries("Матрица VS Тёмный рыцарь\nПрестиж"))
+	assert.Equal(t, []string{"Vsadnik"}, compare.ParseQueries("Vsadnik"))
+	assert.Empty(t, compare.ParseQueries("  "))
+}
+
+func TestE2E_Compare(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 2501, ChatID: 2501, FirstName: "Вера"}
+	c.kinopoisk.setMovie("1046206", `{"id":1046206,"name":"Интерстеллар: Наука","year":2015,"typeNumber":1,"movieLength":51,
+		"ageRating":12,"rating":{"kp":7.482,"imdb":7.9},"votes":{"kp":1433,"imdb":1655},
+		"genres":[{"name":"документальный"}],"countries":[{"name":"США"}]}`)
+
+	// Без запросов и без результатов поиска - подсказка
+	calls := c.say(user, "/compare")
+	assert.Contains(t, texts(calls)[0], "/compare Интерстеллар; Начало")
+
+	// Ошибки в запросах
+	calls = c.say(user, "/compare интерстеллар; абвгд")
+	assert.Equal(t, "По запросу «абвгд» ничего не найдено.", texts(calls)[0])
+	calls = c.say(user, "/compare интерстеллар; interstellar")
+	assert.Contains(t, texts(calls)[0], "нашёлся один и тот же фильм")
+
+	// Выбор из текущего списка отметками
+	c.say(user, "интерстеллар")
+	calls = c.say(user, "/compare")
+	picker, _ := findCall(calls, "sendMessage")
+	assert.Contains(t, picker.Params.Get("text"), "Отметьте 2-3 фильма")
+	markup := picker.Params.Get("reply_markup")
+
+	calls = c.press(user, picker.MessageID, buttonData(t, markup, "⬜ Интерстеллар (2014)"))
+	edit, ok := findCall(calls, "editMessageReplyMarkup")
+	if assert.True(t, ok) {
+		assert.Contains(t, edit.Params.Get("reply_markup"), "✅ Интерстеллар (2014)")
+	}
+	calls = c.press(user, picker.MessageID, "cm:go")
+	assert.Equal(t, "Отметьте хотя бы 2 фильма.", texts(calls)[0])
+
+	c.press(user, picker.MessageID, "cm:t:1046206")
+	calls = c.press(user, picker.MessageID, "cm:go")
+	table, ok := findCall(calls, "sendMessage")
+	if assert.True(t, ok) {
+		text := table.Params.Get("text")
+		assert.Equal(t, "HTML", table.Params.Get("parse_mode"))
+		assert.Contains(t, text, "<pre>")
+		// Фильмы в порядке списка, лучший рейтинг отмечен
+		assert.Less(t, strings.Index(text, ">Интерстеллар<"), strings.Index(text, ">Интерстеллар: Наука<"))
+		assert.Contains(t, text, "КП      8.7★         7.5\n")
+		assert.Contains(t, text, " голоса 1,1М★        1К\n")
+		assert.Contains(t, table.Params.Get("reply_markup"), `"callback_data":"cm:card:258687"`)
+	}
+
+	// Кнопка под таблицей открывает карточку, а результаты поиска остаются прежними
+	calls = c.press(user, table.MessageID, "cm:card:258687")
+	_, ok = findCall(calls, "sendPhoto")
+	assert.True(t, ok)
+	calls = c.say(user, "/compare")
+	picker, _ = findCall(calls, "sendMessage")
+	assert.Contains(t, picker.Params.Get("reply_markup"), "Межзвёздный")
+
+	// При малом остатке лимита API сравнение по запросам не начинается
+	threshold := compare.APIReserveThreshold
+	compare.APIReserveThreshold = 0
+	calls = c.say(user, "/compare интерстеллар; interstellar")
+	assert.Contains(t, texts(calls)[0], "Лимит запросов к Кинопоиску на сегодня почти исчерпан")
+
+	// Карточка из кэша открывается и тогда, а за остальными бот к API не обращается
+	before := api.RequestsToday()
+	calls = c.press(user, table.MessageID, "cm:card:258687")
+	_, ok = findCall(calls, "sendPhoto")
+	assert.True(t, ok)
+	calls = c.press(user, table.MessageID, "cm:card:999001")
+	assert.Contains(t, texts(calls)[0], "Лимит запросов к Кинопоиску на сегодня почти исчерпан")
+	assert.Equal(t, before, api.RequestsToday())
+	compare.APIReserveThreshold = threshold
+
+	// Больше трёх фильмов в таблицу не помещается
+	calls = c.say(user, "/compare интерстеллар; a; b; c")
+	assert.Equal(t, "Сравнить можно не больше 3 фильмов за раз.", texts(calls)[0])
+}
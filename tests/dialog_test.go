@@ -0,0 +1,159 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/dialog"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// Двухшаговый диалог: название фильма, затем год
+func init() {
+	dialog.Register(&dialog.Dialog{
+		Name:    "test-survey",
+		Start:   "title",
+		Timeout: time.Hour,
+		Transitions: map[string][]string{
+			"title": {"year"},
+		},
+		States: map[string]dialog.Step{
+			"title": func(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *dialog.Session) string {
+				session.Data["title"] = message.Text
+				sender.Send(bot, tgbotapi.NewMessage(message.Chat.ID, "Год?"))
+				return "year"
+			},
+			"year": func(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *dialog.Session) string {
+				if _, err := strconv.Atoi(message.Text); err != nil {
+					sender.Send(bot, tgbotapi.NewMessage(message.Chat.ID, "Нужен год числом"))
+					return session.State
+				}
+				text := fmt.Sprintf("%s (%s)", session.Data["title"], message.Text)
+				sender.Send(bot, tgbotapi.NewMessage(message.Chat.ID, text))
+				return dialog.End
+			},
+		},
+	})
+}
+
+// Диалог, шаг которого пытается перейти в состояние без разрешённого перехода
+func init() {
+	dialog.Register(&dialog.Dialog{
+		Name:  "test-jump",
+		Start: "first",
+		States: map[string]dialog.Step{
+			"first": func(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *dialog.Session) string {
+				return "second"
+			},
+			"second": func(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *dialog.Session) string {
+				return dialog.End
+			},
+		},
+	})
+}
+
+func TestE2E_DialogSteps(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 1801, ChatID: 1801, FirstName: "Олег"}
+
+	assert.NoError(t, dialog.Begin(user.ChatID, user.ID, "test-survey", nil))
+	assert.Error(t, dialog.Begin(user.ChatID, user.ID, "unknown", nil))
+
+	// Сообщения уходят в диалог, а не в поиск и не в лимит
+	calls := c.say(user, "Интерстеллар")
+	assert.Equal(t, []string{"Год?"}, texts(calls))
+	session, ok := dialog.Active(user.ChatID, user.ID)
+	assert.True(t, ok)
+	assert.Equal(t, "year", session.State)
+	assert.Equal(t, "Интерстеллар", session.Data["title"])
+
+	calls = c.say(user, "недавно")
+	assert.Equal(t, []string{"Нужен год числом"}, texts(calls))
+	calls = c.say(user, "2014")
+	assert.Equal(t, []string{"Интерстеллар (2014)"}, texts(calls))
+	_, ok = dialog.Active(user.ChatID, user.ID)
+	assert.False(t, ok)
+
+	// /cancel завершает диалог
+	assert.NoError(t, dialog.Begin(user.ChatID, user.ID, "test-survey", nil))
+	calls = c.say(user, "/cancel")
+	assert.Equal(t, []string{"Действие отменено."}, texts(calls))
+	_, ok = dialog.Active(user.ChatID, user.ID)
+	assert.False(t, ok)
+
+	// Без диалога /cancel не ищется как фильм
+	calls = c.say(user, "/cancel")
+	assert.Equal(t, []string{"Нечего отменять."}, texts(calls))
+
+	// Другая команда сбрасывает диалог и обрабатывается как обычно
+	assert.NoError(t, dialog.Begin(user.ChatID, user.ID, "test-survey", nil))
+	calls = c.say(user, "/help")
+	assert.Contains(t, calls[0].Params.Get("text"), "кинобот-помощник")
+	_, ok = dialog.Active(user.ChatID, user.ID)
+	assert.False(t, ok)
+
+	// Диалоги разных пользователей в одном чате независимы
+	group := testUser{ID: 1802, ChatID: -1800, FirstName: "Ира"}
+	assert.NoError(t, dialog.Begin(group.ChatID, group.ID, "test-survey", nil))
+	_, ok = dialog.Active(group.ChatID, user.ID)
+	assert.False(t, ok)
+}
+
+func TestE2E_DialogTimeout(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 1803, ChatID: 1803, FirstName: "Олег"}
+
+	prev := dialog.DefaultTimeout
+	dialog.DefaultTimeout = time.Millisecond
+	t.Cleanup(func() { dialog.DefaultTimeout = prev })
+
+	// После таймаута сообщение снова обрабатывается как поиск фильма
+	c.say(user, "/feedback")
+	time.Sleep(5 * time.Millisecond)
+	calls := c.say(user, "интерстеллар")
+	_, ok := findCall(calls, "sendMessage")
+	assert.True(t, ok)
+	assert.NotContains(t, calls[len(calls)-1].Params.Get("text"), "Обращение")
+	_, ok = dialog.Active(user.ChatID, user.ID)
+	assert.False(t, ok)
+
+	// /cancel после таймаута тоже не уходит в поиск
+	c.say(user, "/feedback")
+	time.Sleep(5 * time.Millisecond)
+	calls = c.say(user, "/cancel")
+	assert.Equal(t, []string{"Нечего отменять."}, texts(calls))
+}
+
+func TestDialog_TransitionsAndPurge(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 1804, ChatID: 1804, FirstName: "Олег"}
+
+	// Переход, которого нет в таблице, завершает диалог
+	assert.NoError(t, dialog.Begin(user.ChatID, user.ID, "test-jump", nil))
+	c.say(user, "дальше")
+	_, ok := dialog.Active(user.ChatID, user.ID)
+	assert.False(t, ok)
+
+	// Переходы в необъявленные состояния отклоняются при регистрации
+	assert.Panics(t, func() {
+		dialog.Register(&dialog.Dialog{
+			Name:        "test-broken",
+			Start:       "first",
+			States:      map[string]dialog.Step{"first": nil},
+			Transitions: map[string][]string{"first": {"missing"}},
+		})
+	})
+
+	// Брошенные диалоги удаляются из хранилища
+	assert.NoError(t, dialog.Begin(user.ChatID, user.ID, "test-survey", nil))
+	other := testUser{ID: 1805, ChatID: 1805}
+	assert.NoError(t, dialog.Begin(other.ChatID, other.ID, "feedback", nil))
+	assert.Equal(t, 0, dialog.PurgeExpired(time.Now()))
+	assert.Equal(t, 2, dialog.PurgeExpired(time.Now().Add(2*time.Hour)))
+	assert.Empty(t, storage.Keys("dialogs"))
+}
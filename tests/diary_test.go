@@ -0,0 +1,108 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/diary"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_RateAndDiary(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 1301, ChatID: 1301, FirstName: "Вера"}
+
+	calls := c.say(user, "/diary")
+	assert.Contains(t, calls[0].Params.Get("text"), "Дневник пуст")
+
+	c.say(user, "интерстеллар")
+	calls = c.press(user, 0, "258687")
+	card, _ := findCall(calls, "sendPhoto")
+	// На карточке одна кнопка оценки, оценки 1-10 появляются по нажатию
+	assert.NotContains(t, card.Params.Get("reply_markup"), "dy:rate:")
+	calls = c.press(user, card.MessageID, buttonData(t, card.Params.Get("reply_markup"), "⭐ Оценить"))
+	scores, ok := findCall(calls, "editMessageReplyMarkup")
+	if assert.True(t, ok) {
+		assert.Equal(t, "dy:rate:258687:10", buttonData(t, scores.Params.Get("reply_markup"), "10"))
+		assert.Equal(t, "dy:back:258687:0", buttonData(t, scores.Params.Get("reply_markup"), "◀️ Назад"))
+	}
+	calls = c.press(user, card.MessageID, "dy:back:258687:0")
+	back, _ := findCall(calls, "editMessageReplyMarkup")
+	assert.Contains(t, back.Params.Get("reply_markup"), "⭐ Оценить")
+
+	calls = c.press(user, card.MessageID, "dy:rate:258687:9")
+	confirm, _ := findCall(calls, "sendMessage")
+	assert.Contains(t, confirm.Params.Get("text"), "оценка 9/10 для «Интерстеллар»")
+	// После оценки на карточке снова обычные кнопки
+	restored, _ := findCall(calls, "editMessageReplyMarkup")
+	assert.Contains(t, restored.Params.Get("reply_markup"), "⭐ Оценить")
+
+	// Переоценка не создаёт вторую запись
+	calls = c.press(user, card.MessageID, "dy:rate:258687:10")
+	confirm, _ = findCall(calls, "sendMessage")
+	assert.Len(t, diary.Load(user.ID).Entries, 1)
+	assert.Equal(t, 10, diary.Load(user.ID).Entries[0].Score)
+
+	// Ответ на подтверждение сохраняет заметку, а не запускает поиск
+	calls = c.reply(user, "Пересмотреть в IMAX", &tgbotapi.Message{MessageID: confirm.MessageID})
+	assert.Len(t, calls, 1)
+	assert.Contains(t, calls[0].Params.Get("text"), "Заметка к «Интерстеллар» сохранена")
+
+	calls = c.say(user, "/diary")
+	text := calls[0].Params.Get("text")
+	assert.Contains(t, text, "Интерстеллар (2014) ⭐ 10/10")
+	assert.Contains(t, text, "📝 Пересмотреть в IMAX")
+
+	calls = c.say(user, "/stats")
+	assert.Contains(t, calls[0].Params.Get("text"), "Просмотрено: 1")
+
+	// Ответ после истечения времени на заметку - обычный поиск
+	diary.NoteTimeout = -time.Second
+	defer func() { diary.NoteTimeout = time.Hour }()
+	calls = c.press(user, card.MessageID, "dy:rate:258687:8")
+	confirm, _ = findCall(calls, "sendMessage")
+	calls = c.reply(user, "интерстеллар", &tgbotapi.Message{MessageID: confirm.MessageID})
+	assert.NotContains(t, texts(calls)[0], "Заметка")
+}
+
+func TestComputeStats(t *testing.T) {
+	movie := func(name string, year uint16, typeNumber int, kp, imdb float32, genres []string, countries []string) api.Cinema {
+		m := api.Cinema{Name: name, Year: year, TypeNumber: typeNumber}
+		m.Rating.Kp, m.Rating.Imdb = kp, imdb
+		for _, genre := range genres {
+			m.Genres = append(m.Genres, struct {
+				Name string `json:"name"`
+			}{Name: genre})
+		}
+		for _, country := range countries {
+			m.Countries = append(m.Countries, struct {
+				Name string `json:"name"`
+			}{Name: country})
+		}
+		return m
+	}
+
+	entries := []diary.Entry{
+		{Movie: movie("A", 2014, 1, 8.5, 8.7, []string{"драма", "фантастика"}, []string{"США"}), Score: 10},
+		{Movie: movie("B", 2019, 2, 7.5, 0, []string{"драма"}, []string{"Россия"}), Score: 6},
+		{Movie: movie("C", 1999, 1, 0, 8.1, []string{"фантастика", "боевик"}, []string{"США"}), Score: 8},
+	}
+
+	stats := diary.ComputeStats(entries)
+	assert.Equal(t, 3, stats.Total)
+	assert.InDelta(t, 8.0, stats.AvgScore, 0.001)
+	// Нулевые рейтинги не учитываются в среднем
+	assert.InDelta(t, 8.0, stats.AvgKp, 0.001)
+	assert.InDelta(t, 8.4, stats.AvgImdb, 0.001)
+	assert.Equal(t, []diary.Count{{Name: "драма", Count: 2}, {Name: "фантастика", Count: 2}, {Name: "боевик", Count: 1}}, stats.Genres)
+	assert.Equal(t, []diary.Count{{Name: "США", Count: 2}, {Name: "Россия", Count: 1}}, stats.Countries)
+	assert.Equal(t, []diary.Count{{Name: "2010-е", Count: 2}, {Name: "1990-е", Count: 1}}, stats.Decades)
+	assert.Equal(t, []diary.Count{{Name: "Фильм", Count: 2}, {Name: "Сериал", Count: 1}}, stats.Types)
+
+	text := diary.FormatStats(stats)
+	assert.Contains(t, text, "Средняя личная оценка: 8.0")
+	assert.Contains(t, text, "По жанрам: драма - 2, фантастика - 2, боевик - 1")
+	assert.Contains(t, diary.FormatStats(diary.ComputeStats(nil)), "Статистики пока нет")
+}
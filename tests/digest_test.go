@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/digest"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+	"github.com/luzhnov-aleksei/kinobot/watchlist"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestSchedule_Next(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		after time.Time
+		want  time.Time
+	}{
+		{
+			name:  "каждый день по Москве, время уже прошло",
+			args:  []string{"daily", "09:00"},
+			after: time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 10, 20, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "раз в неделю по смещению",
+			args:  []string{"weekly", "пт", "19:30", "UTC+5"},
+			after: time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 10, 23, 14, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "переход на зимнее время не сдвигает местное время",
+			args:  []string{"daily", "09:00", "Europe/Berlin"},
+			after: time.Date(2026, 10, 24, 8, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 10, 25, 8, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := digest.ParseSchedule(tt.args)
+			assert.NoError(t, err)
+			assert.True(t, tt.want.Equal(schedule.Next(tt.after)), "получено %v", schedule.Next(tt.after))
+		})
+	}
+
+	for _, args := range [][]string{{}, {"monthly", "09:00"}, {"daily", "25:00"}, {"daily", "09:00", "Mars/Olympus"}, {"weekly", "пн", "09:00", "UTC", "лишнее"},
+		{"daily", "09:00", "UTC+-5"}, {"daily", "09:00", "UTC+15"}, {"daily", "09:00", "UTC+5:-30"}, {"daily", "09:00", "+5:7"}} {
+		_, err := digest.ParseSchedule(args)
+		assert.Error(t, err, "%v", args)
+	}
+}
+
+func TestE2E_DigestSubscriptionAndCatchUp(t *testing.T) {
+	c := newConversation(t)
+	alice := testUser{ID: 1401, ChatID: 1401, FirstName: "Алиса"}
+
+	calls := c.say(alice, "/digest")
+	assert.Contains(t, calls[0].Params.Get("text"), "/digest daily 09:00")
+
+	calls = c.say(alice, "/digest weekly пн 09:00 UTC+3")
+	assert.Contains(t, calls[0].Params.Get("text"), "раз в неделю, в понедельник в 09:00 (UTC+3)")
+	subscription, ok := digest.Load(alice.ChatID)
+	assert.True(t, ok)
+	assert.Equal(t, time.Monday, subscription.NextRun.Weekday())
+
+	// Давно добавленный фильм и любимый жанр для подборки новинок
+	var solaris api.Cinema
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":900021,"name":"Солярис","year":1972,"genres":[{"name":"фантастика"}]}`), &solaris))
+	list := watchlist.List{ChatID: alice.ChatID, Entries: []watchlist.Entry{
+		{Movie: solaris, AddedAt: subscription.NextRun.AddDate(0, 0, -45)},
+	}}
+	assert.NoError(t, storage.Put("watchlist", strconv.FormatInt(alice.ChatID, 10), list))
+
+	digest.SendDue(c.bot, subscription.NextRun.Add(-time.Minute))
+	assert.Empty(t, c.tg.takeCalls())
+
+	// Бот был выключен три недели: приходит один дайджест, следующий - по расписанию
+	now := subscription.NextRun.AddDate(0, 0, 21).Add(time.Hour)
+	digest.SendDue(c.bot, now)
+	calls = c.tg.takeCalls()
+	if assert.Len(t, calls, 1) {
+		text := calls[0].Params.Get("text")
+		assert.Contains(t, text, "📬 Дайджест на неделю")
+		assert.Contains(t, text, "🎬 Премьеры:\n• Интерстеллар: Наука (2015)")
+		assert.Contains(t, text, "⭐ Новинки в жанре «фантастика»")
+		assert.Contains(t, text, "• Солярис (1972) - в списке 66 дн.")
+		assert.Contains(t, calls[0].Params.Get("reply_markup"), `"callback_data":"dg:card:258687"`)
+	}
+
+	subscription, _ = digest.Load(alice.ChatID)
+	assert.True(t, subscription.NextRun.After(now))
+	assert.Equal(t, time.Monday, subscription.NextRun.Weekday())
+	digest.SendDue(c.bot, now)
+	assert.Empty(t, c.tg.takeCalls())
+
+	// Кнопка открывает карточку фильма
+	calls = c.press(alice, 0, "dg:card:258687")
+	card, ok := findCall(calls, "sendPhoto")
+	assert.True(t, ok)
+	assert.Contains(t, card.Params.Get("caption"), "Интерстеллар")
+
+	calls = c.say(alice, "/digest off")
+	assert.Contains(t, calls[0].Params.Get("text"), "Вы отписались")
+	_, ok = digest.Load(alice.ChatID)
+	assert.False(t, ok)
+}
+
+func TestE2E_DigestSkipsSectionsWhenAPIBudgetIsLow(t *testing.T) {
+	c := newConversation(t)
+	alice := testUser{ID: 1402, ChatID: 1402, FirstName: "Алиса"}
+	threshold := digest.APIReserveThreshold
+	digest.APIReserveThreshold = 0
+	defer func() { digest.APIReserveThreshold = threshold }()
+
+	c.say(alice, "/digest daily 09:00 UTC")
+	subscription, ok := digest.Load(alice.ChatID)
+	assert.True(t, ok)
+	var solaris api.Cinema
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":900021,"name":"Солярис","year":1972}`), &solaris))
+	list := watchlist.List{ChatID: alice.ChatID, Entries: []watchlist.Entry{
+		{Movie: solaris, AddedAt: subscription.NextRun.AddDate(0, 0, -40)},
+	}}
+	assert.NoError(t, storage.Put("watchlist", strconv.FormatInt(alice.ChatID, 10), list))
+
+	// Подборки из API пропускаются, напоминания из списка приходят
+	digest.SendDue(c.bot, subscription.NextRun)
+	calls := c.tg.takeCalls()
+	if assert.Len(t, calls, 1) {
+		text := calls[0].Params.Get("text")
+		assert.NotContains(t, text, "🎬 Премьеры")
+		assert.Contains(t, text, "• Солярис (1972) - в списке 40 дн.")
+	}
+}
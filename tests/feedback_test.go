@@ -0,0 +1,122 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/luzhnov-aleksei/kinobot/admin"
+	"github.com/luzhnov-aleksei/kinobot/feedback"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_FeedbackTicket(t *testing.T) {
+	c := newConversation(t)
+	const adminChatID = -2701
+	user := testUser{ID: 1701, ChatID: 1701, FirstName: "Ира", UserName: "ira"}
+	maintainer := testUser{ID: 1702, ChatID: adminChatID, FirstName: "Алексей"}
+
+	prevChat := feedback.ChatID
+	feedback.ChatID = adminChatID
+	admin.SetAdmins([]int64{maintainer.ID})
+	t.Cleanup(func() {
+		feedback.ChatID = prevChat
+		admin.SetAdmins(nil)
+	})
+
+	c.say(user, "интерстеллар")
+
+	calls := c.say(user, "/feedback")
+	assert.Contains(t, calls[0].Params.Get("text"), "Опишите проблему или идею")
+
+	// Обращение пересылается в чат администраторов с контекстом
+	calls = c.say(user, "Не открывается карточка фильма")
+	forwarded, ok := findCall(calls, "sendMessage")
+	assert.True(t, ok)
+	assert.Equal(t, "-2701", forwarded.Params.Get("chat_id"))
+	text := forwarded.Params.Get("text")
+	assert.Contains(t, text, "🆘 Обращение #1")
+	assert.Contains(t, text, "От: Ира @ira (ID 1701)")
+	assert.Contains(t, text, "Последний запрос: «интерстеллар»")
+	assert.Contains(t, text, "Не открывается карточка фильма")
+	assert.Contains(t, forwarded.Params.Get("reply_markup"), `"callback_data":"fb:close:1"`)
+	assert.Equal(t, "Спасибо! Обращение #1 передано разработчикам, ответ придёт сюда.", calls[len(calls)-1].Params.Get("text"))
+
+	ticket, ok := feedback.Load(1)
+	assert.True(t, ok)
+	assert.Equal(t, feedback.StatusOpen, ticket.Status)
+
+	// Ответ администратора на пересланное сообщение уходит пользователю
+	c.tg.mu.Lock()
+	forwardedMsg := c.tg.messages[forwarded.MessageID]
+	c.tg.mu.Unlock()
+	calls = c.reply(maintainer, "Спасибо, уже исправляем", &forwardedMsg)
+	if assert.Len(t, calls, 2) {
+		assert.Equal(t, "1701", calls[0].Params.Get("chat_id"))
+		assert.Equal(t, "💬 Ответ на обращение #1:\n\nСпасибо, уже исправляем", calls[0].Params.Get("text"))
+		assert.Equal(t, "Ответ на обращение #1 отправлен.", calls[1].Params.Get("text"))
+	}
+	ticket, _ = feedback.Load(1)
+	assert.Equal(t, feedback.StatusAnswered, ticket.Status)
+	assert.Len(t, ticket.Replies, 1)
+
+	// Скриншот пересылается фото с подписью
+	c.say(user, "/feedback")
+	calls = c.photo(user, "screenshot-file", "Вот так выглядит ошибка")
+	photo, ok := findCall(calls, "sendPhoto")
+	assert.True(t, ok)
+	assert.Equal(t, "screenshot-file", photo.Params.Get("photo"))
+	assert.Contains(t, photo.Params.Get("caption"), "🆘 Обращение #2")
+
+	// Отмена не создаёт обращение
+	c.say(user, "/feedback")
+	calls = c.say(user, "/cancel")
+	assert.Equal(t, "Обращение отменено.", calls[0].Params.Get("text"))
+
+	calls = c.say(inPrivate(maintainer), "/tickets")
+	assert.Contains(t, calls[0].Params.Get("text"), "#1 Ира")
+	assert.Contains(t, calls[0].Params.Get("text"), "есть ответ")
+	assert.Contains(t, calls[0].Params.Get("text"), "#2 Ира")
+
+	calls = c.press(maintainer, forwarded.MessageID, "fb:close:1")
+	_, ok = findCall(calls, "editMessageReplyMarkup")
+	assert.True(t, ok)
+	ticket, _ = feedback.Load(1)
+	assert.Equal(t, feedback.StatusClosed, ticket.Status)
+	calls = c.say(inPrivate(maintainer), "/tickets")
+	assert.NotContains(t, calls[0].Params.Get("text"), "#1 Ира")
+}
+
+// Тот же пользователь в личке с ботом
+func inPrivate(user testUser) testUser {
+	user.ChatID = user.ID
+	return user
+}
+
+func TestE2E_FeedbackLongAndUndelivered(t *testing.T) {
+	c := newConversation(t)
+	const adminChatID = -2702
+	user := testUser{ID: 1711, ChatID: 1711, FirstName: "Оля"}
+	prevChat := feedback.ChatID
+	feedback.ChatID = adminChatID
+	t.Cleanup(func() { feedback.ChatID = prevChat })
+
+	// Длинное обращение обрезается до лимита сообщения Telegram
+	calls := c.say(user, "/feedback "+strings.Repeat("очень длинно ", 400))
+	forwarded, ok := findCall(calls, "sendMessage")
+	if assert.True(t, ok) {
+		assert.Equal(t, "-2702", forwarded.Params.Get("chat_id"))
+		assert.LessOrEqual(t, utf8.RuneCountInString(forwarded.Params.Get("text")), 4096)
+	}
+	assert.Contains(t, calls[len(calls)-1].Params.Get("text"), "передано разработчикам")
+
+	// Не доставленное в чат администраторов обращение сохраняется, пользователь знает об этом
+	c.tg.failNext(chatNotFound)
+	calls = c.say(user, "/feedback Бот не отвечает")
+	assert.Contains(t, calls[len(calls)-1].Params.Get("text"), "сохранено, но пока не доставлено")
+	var saved bool
+	for _, ticket := range feedback.Tickets() {
+		saved = saved || (ticket.UserID == user.ID && ticket.Text == "Бот не отвечает")
+	}
+	assert.True(t, saved)
+}
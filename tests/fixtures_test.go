@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+)
+
+// Перезапись фикстур реальными ответами API:
+// API_KEY=... go test ./tests -run Fixture -record
+var record = flag.Bool("record", false, "записать ответы Kinopoisk API в фикстуры")
+
+// Каталог с записанными ответами API
+const fixturesDir = "testdata/kinopoisk"
+
+// Значение, которым заменяется API-ключ в фикстурах
+const scrubbedKey = "<API_KEY>"
+
+// Записанная пара запрос-ответ
+type fixture struct {
+	Request struct {
+		Method string      `json:"method"`
+		URL    string      `json:"url"`
+		Header http.Header `json:"header,omitempty"`
+	} `json:"request"`
+	Response struct {
+		Status int         `json:"status"`
+		Header http.Header `json:"header,omitempty"`
+		Body   string      `json:"body"`
+	} `json:"response"`
+}
+
+// Воспроизведение фикстуры вместо обращения к сети
+type replayTransport struct {
+	t       *testing.T
+	fixture fixture
+}
+
+func (rt *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != rt.fixture.Request.Method || req.URL.String() != rt.fixture.Request.URL {
+		rt.t.Errorf("запрос %s %s не совпадает с фикстурой %s %s",
+			req.Method, req.URL, rt.fixture.Request.Method, rt.fixture.Request.URL)
+	}
+	if req.Header.Get("X-API-KEY") == "" {
+		rt.t.Error("в запросе отсутствует заголовок X-API-KEY")
+	}
+
+	return &http.Response{
+		StatusCode: rt.fixture.Response.Status,
+		Status:     http.StatusText(rt.fixture.Response.Status),
+		Header:     rt.fixture.Response.Header.Clone(),
+		Body:       io.NopCloser(strings.NewReader(rt.fixture.Response.Body)),
+		Request:    req,
+	}, nil
+}
+
+// Запись реального ответа API в фикстуру с удалением ключа
+type recordTransport struct {
+	t    *testing.T
+	path string
+	next http.RoundTripper
+}
+
+func (rt *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := rt.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var f fixture
+	f.Request.Method = req.Method
+	f.Request.URL = req.URL.String()
+	f.Request.Header = req.Header.Clone()
+	f.Response.Status = res.StatusCode
+	f.Response.Header = http.Header{"Content-Type": res.Header.Values("Content-Type")}
+	f.Response.Body = string(body)
+	scrubFixture(&f, os.Getenv("API_KEY"))
+
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(rt.path, data.Bytes(), 0o644); err != nil {
+		return nil, err
+	}
+	rt.t.Logf("фикстура записана: %s", rt.path)
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+// Удаление API-ключа из всех полей фикстуры
+func scrubFixture(f *fixture, apiKey string) {
+	if f.Request.Header.Get("X-API-KEY") != "" {
+		f.Request.Header.Set("X-API-KEY", scrubbedKey)
+	}
+	if apiKey == "" {
+		return
+	}
+	f.Request.URL = strings.ReplaceAll(f.Request.URL, apiKey, scrubbedKey)
+	f.Response.Body = strings.ReplaceAll(f.Response.Body, apiKey, scrubbedKey)
+}
+
+func loadFixture(t *testing.T, name string) fixture {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(fixturesDir, name+".json"))
+	if err != nil {
+		t.Fatalf("не удалось прочитать фикстуру %s: %v", name, err)
+	}
+
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatalf("не удалось разобрать фикстуру %s: %v", name, err)
+	}
+	return f
+}
+
+// Подмена HTTP-клиента API фикстурой name на время теста.
+// С флагом -record запрос уходит в реальный API, а ответ записывается в фикстуру.
+func useFixture(t *testing.T, name string) {
+	t.Helper()
+
+	var transport http.RoundTripper
+	if *record {
+		if os.Getenv("API_KEY") == "" {
+			t.Fatal("для записи фикстур нужна переменная окружения API_KEY")
+		}
+		transport = &recordTransport{
+			t:    t,
+			path: filepath.Join(fixturesDir, name+".json"),
+			next: http.DefaultTransport,
+		}
+	} else {
+		t.Setenv("API_KEY", "test-key")
+		transport = &replayTransport{t: t, fixture: loadFixture(t, name)}
+	}
+
+	prev := api.Client
+	api.Client = &http.Client{Transport: transport}
+	t.Cleanup(func() { api.Client = prev })
+}
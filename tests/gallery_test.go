@@ -0,0 +1,116 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/gallery"
+	"github.com/luzhnov-aleksei/kinobot/poster"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoster_Placeholder(t *testing.T) {
+	data, err := poster.Placeholder("Интерстеллар: Наука", 2015)
+	assert.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if assert.NoError(t, err) {
+		assert.Equal(t, image.Rect(0, 0, poster.Width, poster.Height), img.Bounds())
+		// Название нарисовано светлым по тёмному фону
+		bright := 0
+		for y := 0; y < poster.Height; y++ {
+			for x := 0; x < poster.Width; x++ {
+				if r, g, b, _ := img.At(x, y).RGBA(); r > 0xe000 && g > 0xe000 && b > 0xe000 {
+					bright++
+				}
+			}
+		}
+		assert.Greater(t, bright, 1000)
+	}
+
+	// Заглушка детерминирована, длинное название тоже помещается
+	again, _ := poster.Placeholder("Интерстеллар: Наука", 2015)
+	assert.Equal(t, data, again)
+	_, err = poster.Placeholder(strings.Repeat("Очень длинное название фильма ", 20), 0)
+	assert.NoError(t, err)
+	_, err = poster.Placeholder("", 0)
+	assert.NoError(t, err)
+}
+
+func TestE2E_PlaceholderPoster(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 2101, ChatID: 2101, FirstName: "Вера"}
+
+	c.say(user, "интерстеллар")
+	calls := c.press(user, 0, "1046206")
+	card, ok := findCall(calls, "sendPhoto")
+	if assert.True(t, ok) {
+		assert.Equal(t, "poster.png", card.FileName)
+		assert.True(t, bytes.HasPrefix(card.File, []byte("\x89PNG")))
+		assert.Contains(t, card.Params.Get("caption"), "Интерстеллар: Наука")
+	}
+}
+
+func TestE2E_Gallery(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 2102, ChatID: 2102, FirstName: "Гоша"}
+
+	c.say(user, "интерстеллар")
+	calls := c.press(user, 0, "258687")
+	card, _ := findCall(calls, "sendPhoto")
+	assert.Contains(t, card.Params.Get("reply_markup"), `"callback_data":"gl:258687:1"`)
+
+	// Первая страница - альбом из 10 изображений и кнопка «Ещё»
+	calls = c.press(user, card.MessageID, "gl:258687:1")
+	album, ok := findCall(calls, "sendMediaGroup")
+	if assert.True(t, ok) {
+		var media []struct {
+			Type    string `json:"type"`
+			Media   string `json:"media"`
+			Caption string `json:"caption"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(album.Params.Get("media")), &media))
+		if assert.Len(t, media, 10) {
+			assert.Equal(t, "photo", media[0].Type)
+			assert.Equal(t, "https://images.example/258687/1.jpg", media[0].Media)
+			assert.Equal(t, "🖼 Галерея «Интерстеллар»: страница 1 из 2", media[0].Caption)
+			assert.Empty(t, media[1].Caption)
+		}
+	}
+	more, ok := findCall(calls, "sendMessage")
+	assert.True(t, ok)
+	assert.Contains(t, more.Params.Get("reply_markup"), `"callback_data":"gl:258687:2"`)
+
+	// Вторая страница: два оставшихся изображения, кнопка у прежнего сообщения убирается
+	calls = c.press(user, more.MessageID, "gl:258687:2")
+	edit, ok := findCall(calls, "editMessageText")
+	if assert.True(t, ok) {
+		assert.Equal(t, "🖼 Галерея «Интерстеллар»: страница 1 из 2", edit.Params.Get("text"))
+		assert.Empty(t, edit.Params.Get("reply_markup"))
+	}
+	album, _ = findCall(calls, "sendMediaGroup")
+	assert.Equal(t, 2, strings.Count(album.Params.Get("media"), `"type":"photo"`))
+	_, ok = findCall(calls, "sendMessage")
+	assert.False(t, ok)
+
+	// У фильма без изображений - сообщение вместо альбома
+	calls = c.press(user, card.MessageID, "gl:1046206:1")
+	assert.Equal(t, []string{"У этого фильма пока нет кадров и постеров."}, texts(calls))
+
+	// Загруженные страницы берутся из кэша, новые при исчерпанном лимите не запрашиваются
+	prev := gallery.APIReserveThreshold
+	gallery.APIReserveThreshold = api.RequestsToday()
+	t.Cleanup(func() { gallery.APIReserveThreshold = prev })
+	before := api.RequestsToday()
+	calls = c.press(user, card.MessageID, "gl:258687:1")
+	_, ok = findCall(calls, "sendMediaGroup")
+	assert.True(t, ok)
+	calls = c.press(user, card.MessageID, "gl:447301:1")
+	assert.Contains(t, texts(calls)[0], "Лимит запросов к Кинопоиску на сегодня почти исчерпан")
+	assert.Equal(t, before, api.RequestsToday())
+}
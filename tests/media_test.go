@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/media"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/stretchr/testify/assert"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, h/2, color.White)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("не удалось закодировать PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestMedia_Prepare(t *testing.T) {
+	// Подходящий PNG отправляется как есть
+	small := encodePNG(t, 100, 150)
+	data, name, err := media.Prepare(small)
+	assert.NoError(t, err)
+	assert.Equal(t, "image.png", name)
+	assert.Equal(t, small, data)
+
+	// Большое изображение уменьшается до MaxSide по длинной стороне
+	data, name, err = media.Prepare(encodePNG(t, 4000, 3000))
+	assert.NoError(t, err)
+	assert.Equal(t, "image.jpg", name)
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "jpeg", format)
+		assert.Equal(t, 2560, config.Width)
+		assert.Equal(t, 1920, config.Height)
+	}
+
+	// GIF перекодируется в JPEG
+	var buf bytes.Buffer
+	assert.NoError(t, gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 40, 60), []color.Color{color.Black, color.White}), nil))
+	_, name, err = media.Prepare(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, "image.jpg", name)
+
+	// Изображение с числом пикселей больше MaxPixels не декодируется
+	prevPixels := media.MaxPixels
+	media.MaxPixels = 10_000
+	_, _, err = media.Prepare(encodePNG(t, 200, 200))
+	media.MaxPixels = prevPixels
+	assert.ErrorContains(t, err, "слишком большое")
+
+	// Слишком вытянутое изображение и не изображение
+	_, _, err = media.Prepare(encodePNG(t, 2100, 100))
+	assert.Error(t, err)
+	_, _, err = media.Prepare([]byte("<html>Доступ запрещён</html>"))
+	assert.Error(t, err)
+}
+
+func TestE2E_PosterFileIDCache(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 2201, ChatID: 2201, FirstName: "Зоя"}
+	deadLetters := len(sender.DeadLetters())
+
+	// Первый раз постер уходит по URL, дальше - по file_id из ответа Telegram
+	c.say(user, "интерстеллар")
+	calls := c.press(user, 0, "258687")
+	card, _ := findCall(calls, "sendPhoto")
+	assert.Equal(t, "https://image.openmoviedb.com/kinopoisk-images/1600647/430042eb-ee69-4818-aed0-a312400a26bf/orig", card.Params.Get("photo"))
+	fileID := "photo-" + strconv.Itoa(card.MessageID)
+
+	calls = c.press(user, 0, "258687")
+	card, _ = findCall(calls, "sendPhoto")
+	assert.Equal(t, fileID, card.Params.Get("photo"))
+
+	// Устаревший file_id забывается, постер отправляется по URL без записи в журнал
+	movie, err := movies.GetMovie(258687)
+	assert.NoError(t, err)
+	c.tg.failNext(fakeFailure{status: http.StatusBadRequest,
+		body: `{"ok":false,"error_code":400,"description":"Bad Request: wrong file identifier/HTTP URL specified"}`})
+	movies.SendMovieCard(c.bot, user.ChatID, movie)
+	card, _ = findCall(c.tg.takeCalls(), "sendPhoto")
+	assert.Contains(t, card.Params.Get("photo"), "https://image.openmoviedb.com/")
+	assert.Len(t, sender.DeadLetters(), deadLetters)
+}
+
+func TestMedia_FileIDCacheIsBounded(t *testing.T) {
+	c := newConversation(t)
+	prev := media.MaxCachedFiles
+	media.MaxCachedFiles = 3
+	t.Cleanup(func() { media.MaxCachedFiles = prev })
+
+	// Переполненный кэш сокращается, самые старые file_id удаляются
+	for i := 1; i <= 4; i++ {
+		url := "https://images.example/poster/" + strconv.Itoa(i) + ".jpg"
+		_, err := media.SendPhoto(c.bot, tgbotapi.NewPhoto(2203, nil), url)
+		assert.NoError(t, err)
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, 2, media.CacheSize())
+	assert.IsType(t, tgbotapi.FileURL(""), media.File("https://images.example/poster/1.jpg"))
+	assert.IsType(t, tgbotapi.FileID(""), media.File("https://images.example/poster/4.jpg"))
+}
+
+func TestE2E_PosterReupload(t *testing.T) {
+	c := newConversation(t)
+	const chatID = 2202
+
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(encodePNG(t, 3000, 4500))
+	}))
+	t.Cleanup(cdn.Close)
+
+	movie := api.Cinema{ID: 2202, Name: "Медленный CDN", Year: 2024}
+	movie.Poster = &struct {
+		URL string `json:"url,omitempty"`
+	}{URL: cdn.URL + "/poster.png"}
+
+	// Telegram не смог скачать постер: бот скачивает его сам, уменьшает и загружает файлом
+	c.tg.failNext(fakeFailure{status: http.StatusBadRequest,
+		body: `{"ok":false,"error_code":400,"description":"Bad Request: failed to get HTTP URL content"}`})
+	movies.SendMovieCard(c.bot, chatID, &movie)
+	card, ok := findCall(c.tg.takeCalls(), "sendPhoto")
+	if assert.True(t, ok) {
+		assert.Equal(t, "image.jpg", card.FileName)
+		config, _, err := image.DecodeConfig(bytes.NewReader(card.File))
+		if assert.NoError(t, err) {
+			assert.Equal(t, 1706, config.Width)
+			assert.Equal(t, 2560, config.Height)
+		}
+	}
+
+	// Загруженный файл переиспользуется по file_id
+	movies.SendMovieCard(c.bot, chatID, &movie)
+	next, _ := findCall(c.tg.takeCalls(), "sendPhoto")
+	assert.Equal(t, "photo-"+strconv.Itoa(card.MessageID), next.Params.Get("photo"))
+	assert.Empty(t, next.FileName)
+}
@@ -14,7 +14,9 @@ func TestTypeFilm(t *testing.T) {
 	assert.Equal(t, "Мультфильм", movies.TypeFilm(3))
 	assert.Equal(t, "Аниме", movies.TypeFilm(4))
 	assert.Equal(t, "Мультсериал", movies.TypeFilm(5))
+	assert.Equal(t, "ТВ-шоу", movies.TypeFilm(6))
 	assert.Equal(t, "", movies.TypeFilm(0))
+	assert.Equal(t, "", movies.TypeFilm(42))
 }
 
 func TestFormatMovieInfo(t *testing.T) {
@@ -76,5 +78,6 @@ func TestFormatMovieInfo_EmptyMovie(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, formattedInfo)
 	assert.Contains(t, formattedInfo, "Фильм не найден")
-	assert.Equal(t, "https://habrastorage.org/webt/bh/ex/-z/bhex-zst09dlgq-y2rjespcpp0c.png", imageURL)
+	// Без постера и фона карточка отправляется с заглушкой
+	assert.Empty(t, imageURL)
 }
@@ -2,7 +2,9 @@ package api
 
 import (
 	"testing"
+	"time"
 
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/luzhnov-aleksei/kinobot/api"
 	"github.com/luzhnov-aleksei/kinobot/movies"
 	"github.com/stretchr/testify/assert"
@@ -25,3 +27,28 @@ func TestUserMovieSelections(t *testing.T) {
 	assert.Equal(t, "Фильм 1", savedMovies[0].Name)
 	assert.Equal(t, "Фильм 2", savedMovies[1].Name)
 }
+
+func TestE2E_SelectionsAreBounded(t *testing.T) {
+	c := newConversation(t)
+	prev := movies.MaxSelections
+	movies.MaxSelections = 2
+	t.Cleanup(func() { movies.MaxSelections = prev })
+
+	// Результаты поиска хранятся для двух пользователей, самые старые удаляются
+	users := []testUser{
+		{ID: 3101, ChatID: 3101, FirstName: "Аня"},
+		{ID: 3102, ChatID: 3102, FirstName: "Боря"},
+		{ID: 3103, ChatID: 3103, FirstName: "Вова"},
+	}
+	for _, user := range users {
+		c.say(user, "интерстеллар")
+		time.Sleep(time.Millisecond)
+	}
+	selection := func(user testUser) []api.Cinema {
+		chat := &tgbotapi.Chat{ID: user.ChatID, Type: "private"}
+		return movies.Selection(chat, user.ID)
+	}
+	assert.Empty(t, selection(users[0]))
+	assert.NotEmpty(t, selection(users[1]))
+	assert.NotEmpty(t, selection(users[2]))
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/notify"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+	"github.com/luzhnov-aleksei/kinobot/watchlist"
+	"github.com/stretchr/testify/assert"
+)
+
+// Список чата с заданными непросмотренными фильмами
+func putWatchlist(t *testing.T, chatID int64, movies ...api.Cinema) {
+	t.Helper()
+
+	list := watchlist.List{ChatID: chatID}
+	for _, movie := range movies {
+		list.Entries = append(list.Entries, watchlist.Entry{Movie: movie, AddedByName: "Алиса"})
+	}
+	if err := storage.Put("watchlist", strconv.FormatInt(chatID, 10), list); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNotify_PremiereDigitalAndMute(t *testing.T) {
+	c := newConversation(t)
+	alice := testUser{ID: 1301, ChatID: 1301, FirstName: "Алиса"}
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	putWatchlist(t, alice.ChatID,
+		api.Cinema{ID: 900001, Name: "Дюна: Часть третья", Year: 2026},
+		// Давно вышедший фильм не отслеживается
+		api.Cinema{ID: 258687, Name: "Интерстеллар", Year: 2014, Status: "completed"},
+	)
+	c.kinopoisk.setMovie("900001", `{"id":900001,"name":"Дюна: Часть третья","year":2026,"status":"post-production",
+		"premiere":{"world":"2026-12-18T00:00:00.000Z"}}`)
+
+	// Первая проверка только запоминает состояние
+	notify.Check(c.bot, now)
+	assert.Equal(t, [][]string{{"900001"}}, c.kinopoisk.takeBatches())
+	assert.Empty(t, c.tg.takeCalls())
+
+	// Фильм проверяется не чаще раза в сутки
+	notify.Check(c.bot, now.Add(time.Hour))
+	assert.Empty(t, c.kinopoisk.takeBatches())
+
+	// Появился цифровой релиз
+	c.kinopoisk.setMovie("900001", `{"id":900001,"name":"Дюна: Часть третья","year":2026,"status":"post-production",
+		"premiere":{"world":"2026-12-18T00:00:00.000Z","digital":"2027-02-01T00:00:00.000Z"}}`)
+	notify.Check(c.bot, now.Add(25*time.Hour))
+	calls := c.tg.takeCalls()
+	if assert.Len(t, calls, 1) {
+		assert.Equal(t, "📺 «Дюна: Часть третья» вышел в цифровом релизе (01.02.2027).", calls[0].Params.Get("text"))
+		assert.Contains(t, calls[0].Params.Get("reply_markup"), `"callback_data":"nt:mute:900001"`)
+		assert.Contains(t, calls[0].Params.Get("reply_markup"), `"callback_data":"wl:card:900001"`)
+	}
+
+	// Наступившая премьера не требует запроса к API
+	notify.Check(c.bot, time.Date(2026, 12, 18, 9, 0, 0, 0, time.UTC))
+	calls = c.tg.takeCalls()
+	if assert.Len(t, calls, 1) {
+		assert.Equal(t, "🎬 Премьера! «Дюна: Часть третья» уже вышел (18.12.2026).", calls[0].Params.Get("text"))
+	}
+	c.kinopoisk.takeBatches()
+
+	// После отключения уведомлений фильм больше не проверяется
+	calls = c.press(alice, calls[0].MessageID, "nt:mute:900001")
+	edit, ok := findCall(calls, "editMessageReplyMarkup")
+	assert.True(t, ok)
+	assert.Contains(t, edit.Params.Get("reply_markup"), `"callback_data":"nt:unmute:900001"`)
+	assert.Equal(t, []uint32{900001}, notify.Muted(alice.ChatID))
+
+	notify.Check(c.bot, time.Date(2027, 1, 10, 9, 0, 0, 0, time.UTC))
+	assert.Empty(t, c.kinopoisk.takeBatches())
+	assert.Empty(t, c.tg.takeCalls())
+}
+
+func TestNotify_NewSeasonAndBatchBudget(t *testing.T) {
+	c := newConversation(t)
+	const chatID = -2301
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	prevBatch := notify.BatchSize
+	notify.BatchSize = 1
+	t.Cleanup(func() { notify.BatchSize = prevBatch })
+
+	putWatchlist(t, chatID,
+		api.Cinema{ID: 900011, Name: "Очень странные дела", Year: 2016, IsSeries: true},
+		api.Cinema{ID: 900012, Name: "Разделение", Year: 2022, IsSeries: true},
+	)
+	c.kinopoisk.setMovie("900011", `{"id":900011,"name":"Очень странные дела","isSeries":true,
+		"seasonsInfo":[{"number":1,"episodesCount":8},{"number":2,"episodesCount":9}]}`)
+	c.kinopoisk.setMovie("900012", `{"id":900012,"name":"Разделение","isSeries":true,
+		"seasonsInfo":[{"number":1,"episodesCount":9}]}`)
+
+	// За один тик проверяется одна пачка, первыми - дольше всего не проверявшиеся
+	notify.Check(c.bot, now)
+	notify.Check(c.bot, now.Add(time.Hour))
+	assert.Equal(t, [][]string{{"900011"}, {"900012"}}, c.kinopoisk.takeBatches())
+
+	c.kinopoisk.setMovie("900012", `{"id":900012,"name":"Разделение","isSeries":true,
+		"seasonsInfo":[{"number":1,"episodesCount":9},{"number":2,"episodesCount":10}]}`)
+	notify.Check(c.bot, now.Add(25*time.Hour))
+	notify.Check(c.bot, now.Add(26*time.Hour))
+	assert.Equal(t, [][]string{{"900011"}, {"900012"}}, c.kinopoisk.takeBatches())
+
+	calls := c.tg.takeCalls()
+	if assert.Len(t, calls, 1) {
+		assert.Equal(t, "🆕 У сериала «Разделение» анонсирован 2-й сезон.", calls[0].Params.Get("text"))
+		assert.Equal(t, strconv.Itoa(chatID), calls[0].Params.Get("chat_id"))
+	}
+}
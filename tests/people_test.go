@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/people"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestPersons_Fixture(t *testing.T) {
+	useFixture(t, "person_search")
+
+	persons, err := api.RequestPersons("https://api.kinopoisk.dev/v1.4/person/search", "кристофер нолан")
+	assert.NoError(t, err)
+	if assert.Len(t, persons, 2) {
+		assert.Equal(t, 41477, persons[0].ID)
+		assert.Equal(t, "Christopher Nolan", persons[0].EnName)
+		assert.Equal(t, "Режиссер", persons[0].Profession[0].Value)
+		assert.Empty(t, persons[1].Photo)
+	}
+}
+
+func TestPeople_LooksLikeName(t *testing.T) {
+	for _, query := range []string{"Кристофер Нолан", "Christopher Nolan", "Жан-Поль Бельмондо", "Джон Рональд Толкин"} {
+		assert.True(t, people.LooksLikeName(query), query)
+	}
+	for _, query := range []string{"интерстеллар", "Матрица", "Начало конца", "Мстители 2 Эра", "Один Два Три Четыре"} {
+		assert.False(t, people.LooksLikeName(query), query)
+	}
+}
+
+func TestPeople_Filmography(t *testing.T) {
+	var person api.Person
+	assert.NoError(t, json.Unmarshal([]byte(loadFixture(t, "person").Response.Body), &person))
+
+	films := people.Filmography(&person)
+	// Повторы ролей и фильмы без названия пропускаются, лучшие по рейтингу первыми
+	assert.Len(t, films, 11)
+	assert.Equal(t, "Начало", films[0].Name)
+	assert.Equal(t, "Интерстеллар", films[1].Name)
+	assert.Equal(t, "Following", films[len(films)-3].AlternativeName)
+}
+
+func TestE2E_Person(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 2801, ChatID: 2801, FirstName: "Гоша"}
+	prev := movies.BotUserName
+	movies.BotUserName = "kinobot_test"
+	t.Cleanup(func() { movies.BotUserName = prev })
+
+	calls := c.say(user, "/person")
+	assert.Contains(t, texts(calls)[0], "/person Кристофер Нолан")
+	calls = c.say(user, "/person абвгд")
+	assert.Equal(t, "По запросу «абвгд» никого не найдено.", texts(calls)[0])
+
+	// Несколько найденных - выбор кнопками
+	calls = c.say(user, "/person кристофер нолан")
+	list, _ := findCall(calls, "sendMessage")
+	assert.Equal(t, "pr:p:41477", buttonData(t, list.Params.Get("reply_markup"), "Кристофер Нолан (Режиссер, 1970)"))
+
+	// Карточка с фотографией и фильмографией по страницам
+	card, ok := findCall(c.press(user, list.MessageID, "pr:p:41477"), "sendPhoto")
+	if assert.True(t, ok) {
+		caption := card.Params.Get("caption")
+		assert.Contains(t, caption, "👤 <b>Кристофер Нолан</b> / Christopher Nolan\nРежиссер, Сценарист, Продюсер\n")
+		assert.Contains(t, caption, "Дата рождения: 30.07.1970, Лондон, Англия, Великобритания\nВозраст: 56\nФильмография: 11\n")
+		markup := card.Params.Get("reply_markup")
+		assert.Equal(t, "pr:m:447301", buttonData(t, markup, "🎬 Начало · КП 8.7"))
+		assert.Equal(t, "pr:f:41477:1", buttonData(t, markup, "2/2 ➡️"))
+	}
+	calls = c.press(user, card.MessageID, "pr:f:41477:1")
+	edit, ok := findCall(calls, "editMessageReplyMarkup")
+	if assert.True(t, ok) {
+		markup := edit.Params.Get("reply_markup")
+		assert.Equal(t, "pr:f:41477:0", buttonData(t, markup, "⬅️ 1/2"))
+		assert.Equal(t, "pr:m:1336963", buttonData(t, markup, "🎬 Following · КП 7.2"))
+	}
+
+	// Фильм из фильмографии открывает обычную карточку
+	movie, ok := findCall(c.press(user, card.MessageID, "pr:m:258687"), "sendPhoto")
+	if assert.True(t, ok) {
+		assert.Contains(t, movie.Params.Get("caption"), "Интерстеллар (2014)")
+	}
+
+	// Имя в обычном поиске находит человека
+	calls = c.say(user, "Кристофер Нолан")
+	found, _ := findCall(calls, "sendMessage")
+	assert.Equal(t, "Выберите фильм или человека:", found.Params.Get("text"))
+	assert.Equal(t, "pr:p:41477", buttonData(t, found.Params.Get("reply_markup"), "👤 Кристофер Нолан"))
+
+	// Имена в подробной карточке ведут в карточку человека
+	calls = c.press(user, movie.MessageID, "ly:detailed:258687")
+	detailed, _ := findCall(calls, "sendMessage")
+	text := detailed.Params.Get("text")
+	assert.Contains(t, text, `Режиссёр: <a href="https://t.me/kinobot_test?start=person_41477">Кристофер Нолан</a>`)
+	assert.Contains(t, text, `В ролях: <a href="https://t.me/kinobot_test?start=person_21495">Мэттью Макконахи</a>, `)
+
+	calls = c.say(user, "/start person_41477")
+	_, ok = findCall(calls, "sendPhoto")
+	assert.True(t, ok)
+}
+
+func TestE2E_PersonSearchSkippedWhenAPIBudgetIsLow(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 2802, ChatID: 2802, FirstName: "Гоша"}
+	prev := people.APIReserveThreshold
+	t.Cleanup(func() { people.APIReserveThreshold = prev })
+	searches := func() int {
+		c.kinopoisk.mu.Lock()
+		defer c.kinopoisk.mu.Unlock()
+		return c.kinopoisk.personSearches
+	}
+
+	// Лимит на исходе, но фильмы нашлись - людей не ищем
+	people.APIReserveThreshold = api.RequestsToday()
+	found, ok := findCall(c.say(user, "Интерстеллар Нолан"), "sendMessage")
+	if assert.True(t, ok) {
+		assert.Equal(t, "Выберите фильм:", found.Params.Get("text"))
+	}
+	assert.Equal(t, 0, searches())
+
+	// Фильмов нет - человека всё равно ищем
+	calls := c.say(user, "Кристофер Нолан")
+	assert.Equal(t, 1, searches())
+	found, _ = findCall(calls, "sendMessage")
+	assert.Equal(t, "pr:p:41477", buttonData(t, found.Params.Get("reply_markup"), "👤 Кристофер Нолан"))
+
+	// Лимит не исчерпан - ищем и фильмы, и людей
+	people.APIReserveThreshold = api.RequestsToday() + 100
+	c.say(user, "Интерстеллар Нолан")
+	assert.Equal(t, 2, searches())
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luzhnov-aleksei/kinobot/admin"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/diary"
+	"github.com/luzhnov-aleksei/kinobot/digest"
+	"github.com/luzhnov-aleksei/kinobot/feedback"
+	"github.com/luzhnov-aleksei/kinobot/history"
+	"github.com/luzhnov-aleksei/kinobot/limiter"
+	"github.com/luzhnov-aleksei/kinobot/privacy"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/watchlist"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_MyDataAndForget(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 2001, ChatID: 2001, FirstName: "Марк", UserName: "mark"}
+	const groupID = -2000
+
+	c.say(user, "интерстеллар")
+	calls := c.press(user, 0, "258687")
+	card, _ := findCall(calls, "sendPhoto")
+	c.press(user, card.MessageID, "dy:rate:258687:9")
+	c.press(user, card.MessageID, "wl:add:258687")
+	c.say(user, "/feedback Не приходит дайджест")
+	c.say(user, "/digest daily 09:00")
+	science := api.Cinema{ID: 1046206, Name: "Интерстеллар: Наука", Year: 2015}
+	_, err := watchlist.Import(groupID, []watchlist.Entry{{Movie: science, AddedBy: user.ID, AddedByName: "Марк", Votes: []int64{user.ID, 2002}}})
+	assert.NoError(t, err)
+
+	calls = c.say(user, "/mydata")
+	text := calls[0].Params.Get("text")
+	assert.Contains(t, text, "👤 Профиль: Марк @mark, ID 2001.")
+	assert.Contains(t, text, "🔎 История: открыто фильмов - 1, последний запрос «интерстеллар»")
+	assert.Contains(t, text, "📔 Дневник: оценок - 1, заметок - 0.")
+	assert.Contains(t, text, "📝 Личный список: фильмов - 1.")
+	assert.Contains(t, text, "👥 Списки групп: добавлено вами - 1, голосов - 1.")
+	assert.Contains(t, text, "🆘 Обращения к разработчикам: 1.")
+	assert.Contains(t, text, "📰 Дайджест: каждый день в 09:00")
+	assert.Contains(t, text, "Сроки хранения: история поиска - 90 дн., журнал недоставленных сообщений - 30 дн.")
+
+	// В группе данные не показываются
+	member := testUser{ID: user.ID, ChatID: groupID, FirstName: "Марк"}
+	calls = c.say(member, "/mydata")
+	assert.Contains(t, calls[0].Params.Get("text"), "напишите /mydata или /forget боту в личные сообщения")
+
+	// Отмена ничего не удаляет
+	calls = c.say(user, "/forget")
+	confirm := calls[0]
+	assert.Contains(t, confirm.Params.Get("reply_markup"), `"callback_data":"pv:forget"`)
+	calls = c.press(user, confirm.MessageID, "pv:cancel")
+	edit, _ := findCall(calls, "editMessageText")
+	assert.Equal(t, "Удаление данных отменено.", edit.Params.Get("text"))
+	assert.Len(t, diary.Load(user.ID).Entries, 1)
+
+	// Счётчик сообщений сбрасывается, а лимит, заданный администратором, остаётся
+	limiter.SetLimit(user.ID, 30)
+	used, _ := limiter.Usage(user.ID)
+	assert.NotZero(t, used)
+
+	calls = c.say(user, "/forget")
+	calls = c.press(user, calls[0].MessageID, "pv:forget")
+	edit, _ = findCall(calls, "editMessageText")
+	assert.Contains(t, edit.Params.Get("text"), "🗑 Все данные о вас удалены.")
+
+	_, ok := admin.LoadUser(user.ID)
+	assert.False(t, ok)
+	usedAfter, limit := limiter.Usage(user.ID)
+	assert.Zero(t, usedAfter)
+	assert.Equal(t, 30, limit)
+	assert.Empty(t, history.Load(user.ID).Selections)
+	assert.Empty(t, history.Load(user.ID).LastQuery)
+	assert.Empty(t, diary.Load(user.ID).Entries)
+	assert.Empty(t, watchlist.Load(user.ChatID).Entries)
+	assert.Empty(t, feedback.Tickets())
+	_, ok = digest.Load(user.ChatID)
+	assert.False(t, ok)
+
+	// В списке группы фильм остаётся без имени и голоса пользователя
+	group := watchlist.Load(groupID).Entries
+	if assert.Len(t, group, 1) {
+		assert.Zero(t, group[0].AddedBy)
+		assert.Empty(t, group[0].AddedByName)
+		assert.Equal(t, []int64{2002}, group[0].Votes)
+	}
+}
+
+func TestPrivacy_Retention(t *testing.T) {
+	newConversation(t)
+	now := time.Now()
+
+	history.RecordQuery(2003, "дюна")
+	history.RecordSelection(2003, &api.Cinema{ID: 258687, Name: "Интерстеллар"})
+
+	path := filepath.Join(t.TempDir(), "dead_letters.jsonl")
+	old := now.Add(-40 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	fresh := now.Add(-time.Hour).UTC().Format(time.RFC3339)
+	content := `{"at":"` + old + `","chatId":1,"method":"tgbotapi.MessageConfig","error":"chat not found","attempts":1}` + "\n" +
+		`{"at":"` + fresh + `","chatId":2,"method":"tgbotapi.MessageConfig","error":"chat not found","attempts":1}` + "\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	prevPath := sender.DeadLetterPath
+	sender.DeadLetterPath = path
+	t.Cleanup(func() { sender.DeadLetterPath = prevPath })
+
+	// Запись журнала старше 30 дней удаляется, свежая история остаётся
+	privacy.Purge(now)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), `"chatId":1,`)
+	assert.Contains(t, string(data), `"chatId":2,`)
+	assert.Equal(t, "дюна", history.Load(2003).LastQuery)
+	assert.Len(t, history.Load(2003).Selections, 1)
+
+	// Через 90 дней история поиска удаляется целиком
+	privacy.Purge(now.Add(91 * 24 * time.Hour))
+	assert.Empty(t, history.Load(2003).LastQuery)
+	assert.Empty(t, history.Load(2003).Selections)
+}
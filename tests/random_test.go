@@ -0,0 +1,167 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/random"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandom_Parse(t *testing.T) {
+	c := random.Parse("Комедия 2000-2010 кп7,5 сериал до120")
+	assert.Equal(t, []string{"комедия"}, c.Genres)
+	assert.Equal(t, 2000, c.YearFrom)
+	assert.Equal(t, 2010, c.YearTo)
+	assert.InDelta(t, 7.5, c.MinRating, 0.001)
+	assert.Equal(t, []api.MovieType{api.TypeTVSeries}, c.Types)
+	assert.Equal(t, 120, c.MaxLength)
+	assert.False(t, c.FromList)
+	assert.Equal(t, "комедия, 2000-2010, КП от 7.5, сериал, до 120 мин", c.String())
+
+	c = random.Parse("список 2015 7+ тип:аниме,мульт 90мин")
+	assert.True(t, c.FromList)
+	assert.Empty(t, c.Genres)
+	assert.Equal(t, 2015, c.YearFrom)
+	assert.Equal(t, 2015, c.YearTo)
+	assert.InDelta(t, 7, c.MinRating, 0.001)
+	assert.Equal(t, []api.MovieType{api.TypeAnime, api.TypeCartoon}, c.Types)
+	assert.Equal(t, 90, c.MaxLength)
+
+	assert.True(t, random.Parse("").Empty())
+	assert.True(t, random.FromList(""))
+	assert.True(t, random.FromList("список драма"))
+	assert.False(t, random.FromList("драма"))
+}
+
+func TestRandom_Params(t *testing.T) {
+	params := random.Params(random.Parse("драма фантастика 2010- кп8 фильм до150"), 2)
+	assert.Equal(t, "2", params.Get("page"))
+	assert.Equal(t, []string{"драма", "фантастика"}, params["genres.name"])
+	assert.Regexp(t, `^2010-\d{4}$`, params.Get("year"))
+	assert.Equal(t, "8.0-10", params.Get("rating.kp"))
+	assert.Equal(t, []string{"movie"}, params["type"])
+	assert.Equal(t, "1-150", params.Get("movieLength"))
+	assert.Equal(t, "votes.kp", params.Get("sortField"))
+
+	// Длина сериалов - в seriesLength, при смеси с фильмами API длину не фильтрует
+	params = random.Params(random.Parse("сериал до60"), 1)
+	assert.Equal(t, "1-60", params.Get("seriesLength"))
+	assert.Empty(t, params.Get("movieLength"))
+	params = random.Params(random.Parse("сериал фильм до60"), 1)
+	assert.Empty(t, params.Get("seriesLength"))
+	assert.Empty(t, params.Get("movieLength"))
+
+	// Без условий - только сортировка и страница
+	params = random.Params(random.Constraints{}, 1)
+	assert.Empty(t, params.Get("genres.name"))
+	assert.Empty(t, params.Get("year"))
+	assert.Empty(t, params.Get("rating.kp"))
+}
+
+func TestRandom_Matches(t *testing.T) {
+	var movie api.Cinema
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":258687,"name":"Интерстеллар","year":2014,"typeNumber":1,
+		"movieLength":169,"rating":{"kp":8.655},"genres":[{"name":"фантастика"},{"name":"драма"}]}`), &movie))
+
+	assert.True(t, random.Matches(random.Parse("Драма 2010-2015 кп8 фильм"), &movie))
+	assert.False(t, random.Matches(random.Parse("комедия"), &movie))
+	assert.False(t, random.Matches(random.Parse("до120"), &movie))
+	assert.False(t, random.Matches(random.Parse("кп9"), &movie))
+	assert.False(t, random.Matches(random.Parse("сериал"), &movie))
+	assert.False(t, random.Matches(random.Parse("-2010"), &movie))
+}
+
+func TestE2E_RandomReroll(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 2901, ChatID: 2901, FirstName: "Вика"}
+
+	// Подбор по условиям: вариант приходит текстом с кнопками
+	calls := c.say(user, "/random фантастика кп7")
+	pick, ok := findCall(calls, "sendMessage")
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Contains(t, pick.Params.Get("text"), "🎲 Случайный выбор\nУсловия: фантастика, КП от 7.0")
+	assert.Contains(t, pick.Params.Get("text"), "Вариант 1.")
+	seen := map[string]bool{buttonData(t, pick.Params.Get("reply_markup"), "🎬 Карточка"): true}
+
+	// «Другой» меняет фильм в том же сообщении и не повторяет показанные
+	for i := 0; i < 2; i++ {
+		calls = c.press(user, pick.MessageID, buttonData(t, pick.Params.Get("reply_markup"), "🎲 Другой"))
+		edit, ok := findCall(calls, "editMessageText")
+		if !assert.True(t, ok) {
+			return
+		}
+		card := buttonData(t, edit.Params.Get("reply_markup"), "🎬 Карточка")
+		assert.False(t, seen[card], "фильм %s показан повторно", card)
+		seen[card] = true
+	}
+	assert.Len(t, seen, 3)
+
+	// В выдаче всего три фильма: следующая страница не добавляет новых
+	calls = c.press(user, pick.MessageID, "rn:r")
+	_, edited := findCall(calls, "editMessageText")
+	assert.False(t, edited)
+	assert.Contains(t, texts(calls)[0], "Других вариантов с такими условиями нет")
+
+	// Карточка показанного фильма
+	calls = c.press(user, pick.MessageID, "rn:c:258687")
+	card, ok := findCall(calls, "sendPhoto")
+	if assert.True(t, ok) {
+		assert.Contains(t, card.Params.Get("caption"), "Интерстеллар")
+	}
+
+	// Подбор из другого сообщения неизвестен
+	calls = c.press(user, pick.MessageID+100, "rn:r")
+	assert.Contains(t, texts(calls)[0], "подбор устарел")
+}
+
+func TestE2E_RandomFromList(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 2902, ChatID: 2902, FirstName: "Гоша"}
+
+	var films []api.Cinema
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"id":258687,"name":"Интерстеллар","year":2014,"typeNumber":1,"movieLength":169,"rating":{"kp":8.6},"genres":[{"name":"фантастика"}]},
+		{"id":1046206,"name":"Интерстеллар: Наука","year":2015,"typeNumber":1,"movieLength":51,"rating":{"kp":7.5},"genres":[{"name":"документальный"}]}]`), &films))
+	putWatchlist(t, user.ChatID, films...)
+
+	// Условия применяются к списку, API не нужен
+	calls := c.say(user, "/random список до60")
+	pick, _ := findCall(calls, "sendMessage")
+	text := pick.Params.Get("text")
+	assert.Contains(t, text, "Случайный выбор из списка (добавил(а) Алиса)\nУсловия: до 60 мин")
+	assert.Equal(t, "rn:c:1046206", buttonData(t, pick.Params.Get("reply_markup"), "🎬 Карточка"))
+
+	// Единственный подходящий фильм уже показан
+	calls = c.press(user, pick.MessageID, "rn:r")
+	assert.Contains(t, texts(calls)[0], "Других вариантов")
+
+	calls = c.say(user, "/random список комедия")
+	assert.Contains(t, texts(calls)[0], "В списке нет непросмотренных фильмов с условиями: комедия")
+}
+
+func TestE2E_RandomRerollStopsWhenAPIBudgetIsLow(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 2903, ChatID: 2903, FirstName: "Вика"}
+	prev := random.APIReserveThreshold
+	t.Cleanup(func() { random.APIReserveThreshold = prev })
+
+	pick, ok := findCall(c.say(user, "/random фантастика кп7"), "sendMessage")
+	if !assert.True(t, ok) {
+		return
+	}
+
+	// Загруженные варианты показываются, а за новой страницей бот не идёт
+	random.APIReserveThreshold = api.RequestsToday()
+	for i := 0; i < 2; i++ {
+		_, edited := findCall(c.press(user, pick.MessageID, "rn:r"), "editMessageText")
+		assert.True(t, edited)
+	}
+	before := api.RequestsToday()
+	calls := c.press(user, pick.MessageID, "rn:r")
+	assert.Contains(t, texts(calls)[0], "Лимит запросов к Кинопоиску на сегодня почти исчерпан")
+	assert.Equal(t, before, api.RequestsToday())
+}
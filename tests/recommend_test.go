@@ -0,0 +1,138 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/recommend"
+	"github.com/stretchr/testify/assert"
+)
+
+// Фильм для тестов рекомендаций
+func testMovie(id uint32, name string, year uint16, typeNumber int, kp float32, genres []string, countries []string) api.Cinema {
+	movie := api.Cinema{ID: id, Name: name, Year: year, TypeNumber: typeNumber}
+	movie.Rating.Kp = kp
+	for _, genre := range genres {
+		movie.Genres = append(movie.Genres, struct {
+			Name string `json:"name"`
+		}{Name: genre})
+	}
+	for _, country := range countries {
+		movie.Countries = append(movie.Countries, struct {
+			Name string `json:"name"`
+		}{Name: country})
+	}
+	return movie
+}
+
+func TestScoreWeight(t *testing.T) {
+	assert.InDelta(t, -1.0, recommend.ScoreWeight(1), 0.001)
+	assert.InDelta(t, 1.0, recommend.ScoreWeight(10), 0.001)
+	assert.Greater(t, recommend.ScoreWeight(6), 0.0)
+	assert.Less(t, recommend.ScoreWeight(5), 0.0)
+}
+
+func TestBuildProfile(t *testing.T) {
+	liked := testMovie(1, "Интерстеллар", 2014, 1, 8.6, []string{"фантастика", "драма"}, []string{"США"})
+	disliked := testMovie(2, "Плохая комедия", 2008, 1, 5.1, []string{"комедия"}, []string{"Россия"})
+	wanted := testMovie(3, "Дюна", 2021, 1, 7.8, []string{"фантастика"}, []string{"США"})
+
+	profile := recommend.BuildProfile([]recommend.Signal{
+		{Movie: liked, Weight: recommend.ScoreWeight(10)},
+		{Movie: disliked, Weight: recommend.ScoreWeight(2)},
+		{Movie: wanted, Weight: recommend.WatchlistWeight, Kind: recommend.SignalWatchlist},
+	})
+
+	assert.InDelta(t, 1.0, profile.Genres["фантастика"], 0.001)
+	assert.Less(t, profile.Genres["комедия"], 0.0)
+	assert.Equal(t, []string{"фантастика", "драма"}, profile.TopGenres(2))
+	assert.True(t, profile.Seen[2])
+	// Понравившиеся - только оценённые, фильм из списка пользователь ещё не видел
+	assert.Len(t, profile.Liked, 1)
+	assert.Equal(t, uint32(1), profile.Liked[0].Movie.ID)
+	assert.Len(t, profile.Interests, 1)
+	sources := profile.Sources(5)
+	assert.Equal(t, []uint32{1, 3}, []uint32{sources[0].Movie.ID, sources[1].Movie.ID})
+	assert.Greater(t, profile.MinRating, 6.0)
+	assert.Less(t, profile.MinRating, 7.8)
+}
+
+func TestRank(t *testing.T) {
+	liked := testMovie(1, "Интерстеллар", 2014, 1, 8.6, []string{"фантастика", "драма"}, []string{"США"})
+	profile := recommend.BuildProfile([]recommend.Signal{
+		{Movie: liked, Weight: recommend.ScoreWeight(10)},
+		{Movie: testMovie(2, "Плохая комедия", 2008, 1, 5.1, []string{"комедия"}, []string{"Россия"}), Weight: -0.8},
+	})
+
+	similar := testMovie(10, "Начало", 2010, 1, 8.7, nil, nil)
+	sciFi := testMovie(11, "Прибытие", 2016, 1, 7.6, []string{"фантастика", "драма"}, []string{"США"})
+	comedy := testMovie(12, "Ещё комедия", 2010, 1, 7.0, []string{"комедия"}, []string{"Россия"})
+	weak := testMovie(13, "Слабая фантастика", 2015, 1, 4.2, []string{"фантастика"}, []string{"США"})
+
+	candidates := []recommend.Candidate{
+		{Movie: comedy},
+		{Movie: weak},
+		{Movie: sciFi},
+		{Movie: similar, Source: &liked},
+		// Уже виденный фильм и повтор исключаются
+		{Movie: liked},
+		{Movie: sciFi},
+	}
+
+	result := recommend.Rank(profile, candidates, 10)
+	assert.Len(t, result, 4)
+	ids := []uint32{result[0].Movie.ID, result[1].Movie.ID, result[2].Movie.ID, result[3].Movie.ID}
+	assert.Equal(t, []uint32{11, 10, 13, 12}, ids)
+	assert.Equal(t, "вы любите жанр «фантастика»", result[0].Reason)
+	assert.Equal(t, "потому что вам понравился «Интерстеллар»", result[1].Reason)
+
+	// Порядок кандидатов не влияет на результат
+	reversed := make([]recommend.Candidate, 0, len(candidates))
+	for i := len(candidates) - 1; i >= 0; i-- {
+		reversed = append(reversed, candidates[i])
+	}
+	assert.Equal(t, result, recommend.Rank(profile, reversed, 10))
+	assert.Len(t, recommend.Rank(profile, candidates, 2), 2)
+
+	// Похожие на фильмы из списка и поиска не выдаются за понравившиеся
+	wanted := testMovie(3, "Дюна", 2021, 1, 7.8, []string{"фантастика"}, []string{"США"})
+	result = recommend.Rank(profile, []recommend.Candidate{
+		{Movie: similar, Source: &wanted, SourceKind: recommend.SignalWatchlist},
+		{Movie: comedy, Source: &wanted, SourceKind: recommend.SignalSelection},
+	}, 10)
+	assert.Equal(t, "похож на «Дюна» из вашего списка", result[0].Reason)
+	assert.Equal(t, "похож на «Дюна», который вы недавно открывали", result[1].Reason)
+}
+
+func TestE2E_Recommend(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 1401, ChatID: 1401, FirstName: "Лев"}
+
+	calls := c.say(user, "/recommend")
+	assert.Contains(t, calls[0].Params.Get("text"), "Пока не из чего составить рекомендации")
+
+	c.say(user, "интерстеллар")
+	c.press(user, 0, "258687")
+	c.press(user, 0, "dy:rate:258687:10")
+
+	calls = c.say(user, "/recommend")
+	msg, _ := findCall(calls, "sendMessage")
+	text := msg.Params.Get("text")
+	assert.Contains(t, text, "Начало (2010) - потому что вам понравился «Интерстеллар»")
+	assert.NotContains(t, text, "Интерстеллар (2014)")
+
+	// Рекомендации открываются как результаты поиска
+	calls = c.press(user, 0, "447301")
+	card, ok := findCall(calls, "sendPhoto")
+	assert.True(t, ok)
+	assert.Contains(t, card.Params.Get("caption"), "Начало")
+
+	// Лимит API почти исчерпан - новых запросов нет
+	prev := recommend.APIReserveThreshold
+	recommend.APIReserveThreshold = api.RequestsToday()
+	t.Cleanup(func() { recommend.APIReserveThreshold = prev })
+	before := api.RequestsToday()
+	calls = c.say(user, "/recommend")
+	assert.Contains(t, calls[0].Params.Get("text"), "Лимит запросов к Кинопоиску на сегодня почти исчерпан")
+	assert.Equal(t, before, api.RequestsToday())
+}
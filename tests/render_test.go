@@ -0,0 +1,248 @@
+package api
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/render"
+	"github.com/stretchr/testify/assert"
+)
+
+// Перезапись эталонов карточек: go test ./tests -run Render -update
+var update = flag.Bool("update", false, "перезаписать эталонные карточки в testdata/render")
+
+// Каталог с эталонными карточками
+const goldenDir = "testdata/render"
+
+// Сравнение карточки с эталоном name.golden: подпись, затем продолжение после разделителя
+func assertGolden(t *testing.T, name string, card render.Card) {
+	t.Helper()
+
+	got := card.Text
+	if card.Overflow != "" {
+		got += "\n--- продолжение ---\n" + card.Overflow
+	}
+	path := filepath.Join(goldenDir, name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("не удалось записать эталон %s: %v", name, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("не удалось прочитать эталон %s: %v", name, err)
+	}
+	assert.Equal(t, string(want), got)
+}
+
+// Символ & вне сущности ломает разбор HTML в Telegram
+var bareAmpersand = regexp.MustCompile(`&(?:[^alqg#]|$)`)
+
+func TestRender_Golden(t *testing.T) {
+	var details api.Cinema
+	assert.NoError(t, json.Unmarshal([]byte(loadFixture(t, "details").Response.Body), &details))
+
+	escaped := render.CardData{
+		Type:        "Мультфильм",
+		Name:        `Том & Джерри: <Кот> "против" мыши`,
+		Year:        1940,
+		AgeRating:   0,
+		Genres:      []string{"комедия", "<b>семейный</b>"},
+		Countries:   []string{"США"},
+		Description: "Кот <Том> & мышь Джерри. Ссылка <a href=\"x\">не ссылка</a>.",
+		Length:      7,
+		Kp:          8.6,
+		Imdb:        7.9,
+		URL:         "https://www.kinopoisk.ru/film/77039/",
+	}
+
+	long := escaped
+	long.Name = "Очень длинное описание"
+	long.Description = strings.Repeat("Слово & <слово> ", 150)
+
+	tests := []struct {
+		name string
+		data render.CardData
+	}{
+		{"details", movies.CardData(&details)},
+		{"escaped", escaped},
+		{"long", long},
+		{"not_found", render.CardData{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card, err := render.RenderCard(render.DefaultLocale, tt.data)
+			assert.NoError(t, err)
+			assertGolden(t, tt.name, card)
+
+			assert.LessOrEqual(t, render.Length(card.Text), render.CaptionLimit)
+			assert.LessOrEqual(t, render.Length(card.Overflow), render.MessageLimit)
+			assert.NotRegexp(t, bareAmpersand, card.Text+card.Overflow)
+		})
+	}
+
+	// Длинное описание не теряется: начало в подписи, остаток в продолжении
+	card, _ := render.RenderCard(render.DefaultLocale, long)
+	assert.NotEmpty(t, card.Overflow)
+	assert.Contains(t, card.Text, "… <i>(продолжение ниже)</i>")
+	assert.Equal(t, strings.Count(long.Description, "&"),
+		strings.Count(card.Text, "&amp;")+strings.Count(card.Overflow, "&amp;"))
+
+	// Язык без своего шаблона получает карточку по умолчанию
+	fallback, err := render.RenderCard("xx", escaped)
+	assert.NoError(t, err)
+	ru, _ := render.RenderCard(render.DefaultLocale, escaped)
+	assert.Equal(t, ru, fallback)
+
+	// Длина считается как в Telegram: без тегов, сущности - один символ, эмодзи - два
+	assert.Equal(t, 6, render.Length("<b>a&amp;b</b> 😀"))
+}
+
+func TestE2E_CardOverflow(t *testing.T) {
+	c := newConversation(t)
+	const chatID = 2301
+
+	movie := api.Cinema{ID: 2301, Name: "Бесконечная история", Year: 1984, Description: strings.Repeat("Очень длинное описание. ", 80)}
+	movies.SendMovieCard(c.bot, chatID, &movie)
+	calls := c.tg.takeCalls()
+	if assert.Len(t, calls, 2) {
+		assert.Equal(t, "sendPhoto", calls[0].Method)
+		assert.Contains(t, calls[0].Params.Get("caption"), "(продолжение ниже)")
+		assert.Equal(t, "sendMessage", calls[1].Method)
+		assert.Equal(t, "HTML", calls[1].Params.Get("parse_mode"))
+		assert.True(t, strings.HasPrefix(calls[1].Params.Get("text"), "<b>Бесконечная история</b>, продолжение описания:\n…"))
+	}
+}
+
+func TestRender_Layouts(t *testing.T) {
+	var details api.Cinema
+	assert.NoError(t, json.Unmarshal([]byte(loadFixture(t, "details").Response.Body), &details))
+	data := movies.CardData(&details)
+
+	escaped := render.CardData{
+		Type:      "Мультфильм",
+		Name:      `Том & Джерри: <Кот>`,
+		Year:      1940,
+		Genres:    []string{"комедия", "семейный", "короткометражка"},
+		Countries: []string{"США"},
+		Length:    7,
+		Kp:        8.6,
+		Imdb:      7.9,
+		URL:       "https://www.kinopoisk.ru/film/77039/?a=1&b=2",
+		KpVotes:   14500,
+		ImdbVotes: 950,
+	}
+	series := escaped
+	series.Name = "Сериал без описания"
+	series.Type = "Сериал"
+	series.Status = "идут съёмки"
+	series.Seasons, series.Episodes = 2, 16
+	series.PremiereWorld = "01.02.2024"
+
+	tests := []struct {
+		name   string
+		layout string
+		items  []render.CardData
+	}{
+		{"detailed", render.LayoutDetailed, []render.CardData{data}},
+		{"detailed_series", render.LayoutDetailed, []render.CardData{series}},
+		{"compact", render.LayoutCompact, []render.CardData{data, escaped}},
+		{"compare", render.LayoutCompare, []render.CardData{data, escaped}},
+		{"compare_three", render.LayoutCompare, []render.CardData{data, escaped, series}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card, err := render.Render(tt.layout, render.DefaultLocale, tt.items...)
+			assert.NoError(t, err)
+			assertGolden(t, tt.name, card)
+
+			assert.LessOrEqual(t, render.Length(card.Text), render.MessageLimit)
+			assert.NotRegexp(t, bareAmpersand, card.Text+card.Overflow)
+		})
+	}
+
+	// Подробная карточка не обрезает описание, которое помещается в сообщение
+	long := escaped
+	long.Description = strings.Repeat("Слово ", 500)
+	card, err := render.Render(render.LayoutDetailed, render.DefaultLocale, long)
+	assert.NoError(t, err)
+	assert.Empty(t, card.Overflow)
+	assert.Contains(t, card.Text, strings.TrimSpace(long.Description))
+
+	// Строки таблицы сравнения одной ширины, без пробелов в конце
+	card, _ = render.Render(render.LayoutCompare, render.DefaultLocale, data, escaped, series)
+	table := render.Plain(card.Text[strings.Index(card.Text, "<pre>"):strings.Index(card.Text, "</pre>")])
+	for _, line := range strings.Split(table, "\n") {
+		assert.LessOrEqual(t, len([]rune(line)), 34)
+		assert.Equal(t, strings.TrimRight(line, " "), line)
+	}
+
+	layout, ok := render.GetLayout(render.LayoutPhoto)
+	assert.True(t, ok)
+	assert.True(t, layout.Photo())
+
+	_, err = render.Render(render.LayoutCompare, render.DefaultLocale, data)
+	assert.Error(t, err)
+	_, err = render.Render(render.LayoutDetailed, render.DefaultLocale, data, escaped)
+	assert.Error(t, err)
+	_, err = render.Render("poster", render.DefaultLocale, data)
+	assert.Error(t, err)
+}
+
+func TestE2E_CardLayouts(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 2401, ChatID: 2401, FirstName: "Лена"}
+
+	c.say(user, "интерстеллар")
+	card, _ := findCall(c.press(user, 0, "258687"), "sendPhoto")
+	markup := card.Params.Get("reply_markup")
+	assert.Equal(t, "ly:detailed:258687", buttonData(t, markup, "📄 Подробно"))
+
+	// С постера на текст - новая карточка вместо прежней
+	calls := c.press(user, card.MessageID, buttonData(t, markup, "📄 Подробно"))
+	detailed, ok := findCall(calls, "sendMessage")
+	if assert.True(t, ok) {
+		assert.Equal(t, "HTML", detailed.Params.Get("parse_mode"))
+		assert.Contains(t, detailed.Params.Get("text"), "<b>Интерстеллар</b> / Interstellar")
+		assert.Contains(t, detailed.Params.Get("text"), "Страница на Кинопоиске")
+		// Даты премьер есть только в подробной информации, в результатах поиска их нет
+		assert.Contains(t, detailed.Params.Get("text"), "Премьера в мире: 26.10.2014")
+		assert.Contains(t, detailed.Params.Get("reply_markup"), `"callback_data":"wl:add:258687"`)
+	}
+	deleted, ok := findCall(calls, "deleteMessage")
+	if assert.True(t, ok) {
+		assert.Equal(t, strconv.Itoa(card.MessageID), deleted.Params.Get("message_id"))
+	}
+
+	// Между текстовыми видами карточка меняется на месте
+	calls = c.press(user, detailed.MessageID, "ly:compact:258687")
+	edit, ok := findCall(calls, "editMessageText")
+	if assert.True(t, ok) {
+		assert.Equal(t, strconv.Itoa(detailed.MessageID), edit.Params.Get("message_id"))
+		assert.True(t, strings.HasPrefix(edit.Params.Get("text"), "<b>Интерстеллар</b> (2014) · "))
+	}
+	assert.Len(t, c.press(user, detailed.MessageID, "ly:compact:258687"), 1, "повторное нажатие ничего не меняет")
+
+	// Кнопки списка под текстовой карточкой обновляют кнопки, а не превращают её в список
+	calls = c.press(user, detailed.MessageID, "wl:add:258687")
+	_, ok = findCall(calls, "editMessageReplyMarkup")
+	assert.True(t, ok)
+	_, ok = findCall(calls, "editMessageText")
+	assert.False(t, ok)
+
+	// Обратно к постеру
+	calls = c.press(user, detailed.MessageID, "ly:photo:258687")
+	_, ok = findCall(calls, "sendPhoto")
+	assert.True(t, ok)
+	_, ok = findCall(calls, "deleteMessage")
+	assert.True(t, ok)
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	tooManyRequests = fakeFailure{http.StatusTooManyRequests,
+		`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`}
+	badGateway   = fakeFailure{http.StatusBadGateway, "<html>502 Bad Gateway</html>"}
+	chatNotFound = fakeFailure{http.StatusBadRequest, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`}
+	floodNoHint  = fakeFailure{http.StatusTooManyRequests, `{"ok":false,"error_code":429,"description":"Too Many Requests"}`}
+	serverError  = fakeFailure{http.StatusInternalServerError, `{"ok":false,"error_code":500,"description":"Internal Server Error"}`}
+)
+
+func TestSender_RetriesFloodAndNetworkErrors(t *testing.T) {
+	withoutPacing(t)
+	tg := newFakeTelegram(t)
+	bot := tg.newBot()
+
+	tg.failNext(tooManyRequests, badGateway)
+	message, err := sender.Send(bot, tgbotapi.NewMessage(1501, "привет"))
+	assert.NoError(t, err)
+	assert.NotZero(t, message.MessageID)
+	assert.Equal(t, 2, tg.failed)
+
+	calls := tg.takeCalls()
+	if assert.Len(t, calls, 1) {
+		assert.Equal(t, "привет", calls[0].Params.Get("text"))
+	}
+}
+
+func TestSender_RetriesServerErrorsAndFloodWithoutHint(t *testing.T) {
+	withoutPacing(t)
+	sender.RetryDelay = 10 * time.Millisecond
+	tg := newFakeTelegram(t)
+	bot := tg.newBot()
+
+	// Ответ 5xx с разобранной ошибкой повторяется, 429 без retry_after ждёт паузу повтора
+	tg.failNext(serverError, floodNoHint)
+	start := time.Now()
+	_, err := sender.Send(bot, tgbotapi.NewMessage(1505, "повтор"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, tg.failed)
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+	assert.Len(t, tg.takeCalls(), 1)
+}
+
+func TestSender_PostDoesNotWait(t *testing.T) {
+	withoutPacing(t)
+	sender.ChatInterval = 50 * time.Millisecond
+	tg := newFakeTelegram(t)
+	bot := tg.newBot()
+
+	start := time.Now()
+	for _, text := range []string{"раз", "два", "три"} {
+		sender.Post(bot, tgbotapi.NewMessage(1506, text))
+	}
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	calls := tg.takeCalls()
+	if assert.Len(t, calls, 3) {
+		for i, text := range []string{"раз", "два", "три"} {
+			assert.Equal(t, text, calls[i].Params.Get("text"))
+		}
+	}
+}
+
+func TestSender_PostThenAndDeferred(t *testing.T) {
+	withoutPacing(t)
+	tg := newFakeTelegram(t)
+	bot := tg.newBot()
+
+	// Отложенный запрос собирается, когда очередь чата доходит до него: ID уже известен
+	sentID := make(chan int, 1)
+	sender.PostThen(bot, tgbotapi.NewMessage(1507, "временное"), func(sent tgbotapi.Message, err error) {
+		assert.NoError(t, err)
+		sentID <- sent.MessageID
+	})
+	sender.Post(bot, tgbotapi.NewMessage(1507, "ответ"))
+	sender.PostDeferred(bot, 1507, func() tgbotapi.Chattable {
+		return tgbotapi.NewDeleteMessage(1507, <-sentID)
+	})
+	sender.PostDeferred(bot, 1507, func() tgbotapi.Chattable { return nil })
+
+	calls := tg.takeCalls()
+	if assert.Len(t, calls, 3) {
+		assert.Equal(t, "sendMessage", calls[1].Method)
+		assert.Equal(t, "deleteMessage", calls[2].Method)
+		assert.Equal(t, strconv.Itoa(calls[0].MessageID), calls[2].Params.Get("message_id"))
+	}
+}
+
+func TestSender_DeadLetters(t *testing.T) {
+	withoutPacing(t)
+	tg := newFakeTelegram(t)
+	bot := tg.newBot()
+
+	prevPath, prevRetries := sender.DeadLetterPath, sender.MaxRetries
+	sender.DeadLetterPath = filepath.Join(t.TempDir(), "dead_letters.jsonl")
+	sender.MaxRetries = 2
+	t.Cleanup(func() { sender.DeadLetterPath, sender.MaxRetries = prevPath, prevRetries })
+
+	// Ошибка запроса не исправится повтором
+	tg.failNext(chatNotFound)
+	_, err := sender.Send(bot, tgbotapi.NewMessage(1502, "никому"))
+	assert.EqualError(t, err, "Bad Request: chat not found")
+	assert.Equal(t, 1, tg.failed)
+
+	// Сетевые ошибки повторяются, пока не кончатся попытки
+	tg.failNext(badGateway, badGateway, badGateway)
+	_, err = sender.Request(bot, tgbotapi.NewDeleteMessage(1502, 7))
+	assert.Error(t, err)
+	assert.Equal(t, 4, tg.failed)
+	assert.Empty(t, tg.takeCalls())
+
+	letters := sender.DeadLetters()
+	if assert.GreaterOrEqual(t, len(letters), 2) {
+		last := letters[len(letters)-2:]
+		assert.Equal(t, "никому", last[0].Text)
+		assert.Equal(t, 1, last[0].Attempts)
+		assert.Equal(t, "tgbotapi.DeleteMessageConfig", last[1].Method)
+		assert.Equal(t, 3, last[1].Attempts)
+	}
+
+	file, err := os.Open(sender.DeadLetterPath)
+	if assert.NoError(t, err) {
+		defer file.Close()
+		var logged []sender.DeadLetter
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var letter sender.DeadLetter
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &letter))
+			logged = append(logged, letter)
+		}
+		assert.Len(t, logged, 2)
+	}
+}
+
+func TestSender_PerChatPacingAndOrder(t *testing.T) {
+	withoutPacing(t)
+	sender.ChatInterval = 40 * time.Millisecond
+	tg := newFakeTelegram(t)
+	bot := tg.newBot()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, text := range []string{"первое", "второе", "третье"} {
+			_, err := sender.Send(bot, tgbotapi.NewMessage(1503, text))
+			assert.NoError(t, err)
+		}
+	}()
+	// Сообщение в другой чат не ждёт очереди первого
+	time.Sleep(5 * time.Millisecond)
+	_, err := sender.Send(bot, tgbotapi.NewMessage(1504, "другой чат"))
+	assert.NoError(t, err)
+	wg.Wait()
+
+	var first []telegramCall
+	var other telegramCall
+	for _, call := range tg.takeCalls() {
+		if call.Params.Get("chat_id") == "1503" {
+			first = append(first, call)
+		} else {
+			other = call
+		}
+	}
+	if assert.Len(t, first, 3) {
+		for i, text := range []string{"первое", "второе", "третье"} {
+			assert.Equal(t, text, first[i].Params.Get("text"))
+		}
+		assert.GreaterOrEqual(t, first[1].At.Sub(first[0].At), 40*time.Millisecond)
+		assert.GreaterOrEqual(t, first[2].At.Sub(first[1].At), 40*time.Millisecond)
+		assert.True(t, other.At.Before(first[2].At))
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/series"
+	"github.com/luzhnov-aleksei/kinobot/watchlist"
+	"github.com/stretchr/testify/assert"
+)
+
+const seriesDoc = `{"id":900021,"name":"Тьма","year":2017,"typeNumber":2,"isSeries":true,"status":"filming",
+	"seriesLength":55,"releaseYears":[{"start":2017,"end":null}],
+	"seasonsInfo":[{"number":1,"episodesCount":3},{"number":2,"episodesCount":2}]}`
+
+const seasonsDocs = `[
+	{"movieId":900021,"number":2,"episodesCount":2,"episodes":[
+		{"number":2,"name":"Конец","airDate":"2019-06-21T00:00:00.000Z"},
+		{"number":1,"name":"Начало","airDate":"2019-06-21T00:00:00.000Z"}]},
+	{"movieId":900021,"number":0,"episodesCount":1,"episodes":[{"number":1,"name":"Спецвыпуск"}]},
+	{"movieId":900021,"number":1,"episodesCount":3,"episodes":[
+		{"number":1,"name":"Секреты","airDate":"2017-12-01T00:00:00.000Z"},
+		{"number":2,"enName":"Lies"},
+		{"number":3}]}]`
+
+func TestSeries_Next(t *testing.T) {
+	var seasons []api.Season
+	assert.NoError(t, json.Unmarshal([]byte(`[{"number":1,"episodes":[{"number":1},{"number":2}]},{"number":2,"episodes":[{"number":1}]}]`), &seasons))
+
+	season, next, ok := series.Next(seasons, 0, 0)
+	assert.True(t, ok)
+	assert.Equal(t, 1, season)
+	assert.Equal(t, 1, next.Number)
+
+	season, next, ok = series.Next(seasons, 1, 2)
+	assert.True(t, ok)
+	assert.Equal(t, 2, season)
+	assert.Equal(t, 1, next.Number)
+
+	_, _, ok = series.Next(seasons, 2, 1)
+	assert.False(t, ok)
+}
+
+func TestE2E_SeriesBrowser(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 2601, ChatID: 2601, FirstName: "Йонас"}
+	c.kinopoisk.setSeasons("900021", seasonsDocs)
+
+	var show api.Cinema
+	assert.NoError(t, json.Unmarshal([]byte(seriesDoc), &show))
+	assert.True(t, movies.IsSeries(&show))
+
+	// Карточка сериала: сезоны, серии и состояние вместо длительности
+	movies.SendMovieCard(c.bot, user.ChatID, &show)
+	card, _ := findCall(c.tg.takeCalls(), "sendPhoto")
+	caption := card.Params.Get("caption")
+	assert.Contains(t, caption, "Сезонов: 2, серий: 5\nСериал ещё выходит\nДлительность серии: 55 мин\n")
+	assert.NotContains(t, caption, "Длительность: ")
+	markup := card.Params.Get("reply_markup")
+	assert.Equal(t, "sr:s:900021", buttonData(t, markup, "📺 Сезоны и серии"))
+
+	// Сезоны открываются новым сообщением, спецвыпуски не показываются
+	calls := c.press(user, card.MessageID, "sr:s:900021")
+	seasons, ok := findCall(calls, "sendMessage")
+	if assert.True(t, ok) {
+		assert.True(t, strings.HasPrefix(seasons.Params.Get("text"), "📺 «Тьма»: сезонов 2, серий 5\n"))
+		assert.Equal(t, "sr:e:900021:2:0", buttonData(t, seasons.Params.Get("reply_markup"), "Сезон 2 (2)"))
+	}
+
+	// Серии сезона по порядку, браузер меняется на месте
+	calls = c.press(user, seasons.MessageID, "sr:e:900021:1:0")
+	edit, ok := findCall(calls, "editMessageText")
+	if assert.True(t, ok) {
+		text := edit.Params.Get("text")
+		assert.Contains(t, text, "▶️ 1. «Секреты» (01.12.2017)\n▫️ 2. «Lies»\n▫️ 3. серия 3")
+		assert.Equal(t, "sr:s:900021", buttonData(t, edit.Params.Get("reply_markup"), "◀️ Сезоны"))
+	}
+
+	// Прогресс хранится только у сериалов из списка
+	calls = c.press(user, seasons.MessageID, "sr:w:900021:1:3")
+	assert.Contains(t, texts(calls)[0], "сначала добавьте «Тьма»")
+	assert.Nil(t, watchlist.Load(user.ChatID).Find(900021))
+
+	// Отметка серии сериала из списка сохраняет прогресс
+	c.press(user, card.MessageID, "wl:add:900021")
+	calls = c.press(user, seasons.MessageID, "sr:w:900021:1:3")
+	edit, _ = findCall(calls, "editMessageText")
+	assert.Contains(t, edit.Params.Get("text"), "Просмотрено до S01E03, дальше S02E01 «Начало».")
+	assert.Contains(t, edit.Params.Get("text"), "✅ 3. серия 3")
+	assert.Contains(t, edit.Params.Get("reply_markup"), `"text":"✓3"`)
+
+	entry := watchlist.Load(user.ChatID).Find(900021)
+	if assert.NotNil(t, entry) {
+		assert.Equal(t, "S01E03", entry.Progress())
+	}
+	calls = c.say(user, "/list")
+	assert.Contains(t, texts(calls)[0], "📺 S01E03")
+
+	// Просмотренный сезон отмечен в списке сезонов
+	calls = c.press(user, seasons.MessageID, "sr:s:900021")
+	edit, _ = findCall(calls, "editMessageText")
+	buttonData(t, edit.Params.Get("reply_markup"), "✅ Сезон 1 (3)")
+
+	// Последняя серия
+	calls = c.press(user, seasons.MessageID, "sr:w:900021:2:2")
+	edit, _ = findCall(calls, "editMessageText")
+	assert.Contains(t, edit.Params.Get("text"), "Просмотрено до S02E02, это последняя вышедшая серия.")
+
+	// Сериал и сезоны из кэша открываются и при исчерпанном лимите, другие - нет
+	prev := series.APIReserveThreshold
+	series.APIReserveThreshold = api.RequestsToday()
+	t.Cleanup(func() { series.APIReserveThreshold = prev })
+	before := api.RequestsToday()
+	calls = c.press(user, seasons.MessageID, "sr:s:900021")
+	_, ok = findCall(calls, "editMessageText")
+	assert.True(t, ok)
+	calls = c.press(user, seasons.MessageID, "sr:s:900099")
+	assert.Contains(t, texts(calls)[0], "Лимит запросов к Кинопоиску на сегодня почти исчерпан")
+	assert.Equal(t, before, api.RequestsToday())
+}
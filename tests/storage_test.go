@@ -0,0 +1,55 @@
+package api
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/luzhnov-aleksei/kinobot/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage_PersistsBetweenOpens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kinobot.json")
+	assert.NoError(t, storage.Open(path))
+
+	type item struct {
+		Name string `json:"name"`
+	}
+	assert.NoError(t, storage.Put("items", "b", item{Name: "Б"}))
+	assert.NoError(t, storage.Put("items", "a", item{Name: "А"}))
+	assert.NoError(t, storage.Delete("items", "missing"))
+
+	// Повторное открытие читает данные с диска
+	assert.NoError(t, storage.Open(path))
+	assert.Equal(t, []string{"a", "b"}, storage.Keys("items"))
+
+	var got item
+	assert.True(t, storage.Get("items", "a", &got))
+	assert.Equal(t, "А", got.Name)
+
+	assert.NoError(t, storage.Delete("items", "a"))
+	assert.NoError(t, storage.Open(path))
+	assert.False(t, storage.Get("items", "a", &got))
+	assert.Equal(t, []string{"b"}, storage.Keys("items"))
+
+	assert.NoError(t, storage.Open(""))
+}
+
+func TestStorage_LazyWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kinobot.json")
+	assert.NoError(t, storage.Open(path))
+	t.Cleanup(func() { assert.NoError(t, storage.Open("")) })
+
+	// Отложенная запись сразу после обычной не трогает файл
+	assert.NoError(t, storage.Put("items", "a", 1))
+	assert.NoError(t, storage.PutLazy("cache", "x", 2))
+	assert.True(t, storage.Get("cache", "x", new(int)))
+	assert.NoError(t, storage.Open(path))
+	assert.False(t, storage.Get("cache", "x", new(int)))
+
+	// Следующая обычная запись сохраняет и отложенные изменения
+	assert.NoError(t, storage.PutLazy("cache", "x", 2))
+	assert.NoError(t, storage.Put("items", "b", 3))
+	assert.NoError(t, storage.Open(path))
+	assert.True(t, storage.Get("cache", "x", new(int)))
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/notify"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+	"github.com/luzhnov-aleksei/kinobot/streaming"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreaming_Sorted(t *testing.T) {
+	var watchability api.Watchability
+	assert.NoError(t, json.Unmarshal([]byte(`{"items":[
+		{"name":"Кинопоиск HD","url":"https://hd.kinopoisk.ru/film/1"},
+		{"name":"Иви","url":""},
+		{"name":"Okko","logo":{"url":"https://logo.example/okko.png"},"url":"https://okko.tv/movie/1"}]}`), &watchability))
+
+	var names []string
+	for _, item := range streaming.Sorted(watchability, []string{"okko"}) {
+		names = append(names, item.Name)
+	}
+	// Подписки первыми, кинотеатры без ссылки не показываются
+	assert.Equal(t, []string{"Okko", "Кинопоиск HD"}, names)
+	assert.Equal(t, []string{"Okko"}, streaming.Available(watchability, []string{"Okko", "Wink"}))
+	assert.Empty(t, streaming.Available(watchability, nil))
+}
+
+func TestE2E_WhereToWatch(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 2701, ChatID: 2701, FirstName: "Оля"}
+
+	movies.ClearCache()
+
+	// В результатах поиска онлайн-кинотеатров нет, их подгружает кнопка
+	c.say(user, "интерстеллар")
+	card, _ := findCall(c.press(user, 0, "258687"), "sendPhoto")
+	assert.Equal(t, "sv:w:258687", buttonData(t, card.Params.Get("reply_markup"), "🍿 Где посмотреть"))
+
+	calls := c.press(user, card.MessageID, "sv:w:258687")
+	links, ok := findCall(calls, "editMessageReplyMarkup")
+	if assert.True(t, ok) {
+		markup := links.Params.Get("reply_markup")
+		assert.Contains(t, markup, `"text":"▶️ Кинопоиск HD","url":"https://hd.kinopoisk.ru/film/4e45ac8e4ebc7ba2b3f9a8d5c1e7d1c1"`)
+		assert.Less(t, strings.Index(markup, "Кинопоиск HD"), strings.Index(markup, "Okko"))
+		assert.NotContains(t, markup, "Где посмотреть")
+		assert.Contains(t, markup, `"callback_data":"ly:detailed:258687"`)
+	}
+
+	// Отметка подписки в /services
+	calls = c.say(user, "/services")
+	picker, _ := findCall(calls, "sendMessage")
+	calls = c.press(user, picker.MessageID, buttonData(t, picker.Params.Get("reply_markup"), "⬜ Okko"))
+	edit, ok := findCall(calls, "editMessageReplyMarkup")
+	if assert.True(t, ok) {
+		assert.Contains(t, edit.Params.Get("reply_markup"), "✅ Okko")
+	}
+	assert.Equal(t, []string{"Okko"}, streaming.Preferred(user.ID))
+
+	// Подписка идёт первой, подробная информация уже в кэше
+	card, _ = findCall(c.press(user, 0, "258687"), "sendPhoto")
+	markup := card.Params.Get("reply_markup")
+	assert.Less(t, strings.Index(markup, "⭐ Okko"), strings.Index(markup, "▶️ Кинопоиск HD"))
+
+	// Фильм из списка появился в подписке
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	putWatchlist(t, user.ChatID, api.Cinema{ID: 900031, Name: "Бесславные ублюдки", Year: 2009, Status: "completed"})
+	c.kinopoisk.setMovie("900031", `{"id":900031,"name":"Бесславные ублюдки","year":2009,"status":"completed"}`)
+	notify.Check(c.bot, now)
+	assert.Equal(t, [][]string{{"900031"}}, c.kinopoisk.takeBatches())
+	assert.Empty(t, c.tg.takeCalls())
+
+	c.kinopoisk.setMovie("900031", `{"id":900031,"name":"Бесславные ублюдки","year":2009,"status":"completed",
+		"watchability":{"items":[{"name":"Wink","url":"https://wink.ru/movies/1"},{"name":"Okko","url":"https://okko.tv/movie/inglourious-basterds"}]}}`)
+	notify.Check(c.bot, now.Add(25*time.Hour))
+	assert.Equal(t, [][]string{{"900031"}}, c.kinopoisk.takeBatches())
+	calls = c.tg.takeCalls()
+	if assert.Len(t, calls, 1) {
+		assert.Equal(t, "🍿 «Бесславные ублюдки» теперь можно посмотреть в Okko.", calls[0].Params.Get("text"))
+		assert.Contains(t, calls[0].Params.Get("reply_markup"), `"url":"https://okko.tv/movie/inglourious-basterds"`)
+	}
+	calls = c.say(user, "/list")
+	assert.Contains(t, texts(calls)[0], "🍿 Okko")
+
+	// Фильм уже есть в подписке и больше не перепроверяется
+	notify.Check(c.bot, now.Add(50*time.Hour))
+	assert.Empty(t, c.kinopoisk.takeBatches())
+}
+
+func TestNotify_ServicesAfterDeployAndPriority(t *testing.T) {
+	c := newConversation(t)
+	const chatID = 2702
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, storage.Put("streaming", strconv.Itoa(chatID), []string{"Okko"}))
+
+	prevBatch := notify.BatchSize
+	notify.BatchSize = 1
+	t.Cleanup(func() { notify.BatchSize = prevBatch })
+
+	// Фильм проверялся до появления онлайн-кинотеатров: первая их проверка ничего не присылает
+	putWatchlist(t, chatID,
+		api.Cinema{ID: 900032, Name: "Джанго освобождённый", Year: 2012, Status: "completed"},
+		api.Cinema{ID: 900033, Name: "Разделение", Year: 2022, IsSeries: true},
+	)
+	assert.NoError(t, storage.Put("notify_state", "900032", notify.State{MovieID: 900032, Name: "Джанго освобождённый", CheckedAt: now.Add(-48 * time.Hour)}))
+	assert.NoError(t, storage.Put("notify_state", "900033", notify.State{MovieID: 900033, Name: "Разделение", CheckedAt: now.Add(-24 * time.Hour)}))
+	c.kinopoisk.setMovie("900032", `{"id":900032,"name":"Джанго освобождённый","year":2012,"status":"completed",
+		"watchability":{"items":[{"name":"Okko","url":"https://okko.tv/movie/django"}]}}`)
+	c.kinopoisk.setMovie("900033", `{"id":900033,"name":"Разделение","isSeries":true}`)
+
+	// Сериал ждут ради новых сезонов, он проверяется раньше фильма, который ждут только в кинотеатрах,
+	// хотя тот дольше не проверялся
+	notify.Check(c.bot, now)
+	notify.Check(c.bot, now.Add(time.Hour))
+	assert.Equal(t, [][]string{{"900033"}, {"900032"}}, c.kinopoisk.takeBatches())
+	assert.Empty(t, c.tg.takeCalls())
+}
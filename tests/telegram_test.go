@@ -0,0 +1,641 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/handlers"
+	"github.com/luzhnov-aleksei/kinobot/render"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+	"github.com/luzhnov-aleksei/kinobot/transfer"
+)
+
+// Имя тестового бота, которое возвращает getMe
+const fakeBotName = "kinobot_test_bot"
+
+// Вызов метода Bot API, принятый фейковым сервером
+type telegramCall struct {
+	Method string
+	Params url.Values
+	// ID сообщения, которое вернул сервер (для send*/edit*)
+	MessageID int
+	// Время получения вызова
+	At time.Time
+	// Загруженный файл (sendDocument): имя и содержимое
+	FileName string
+	File     []byte
+}
+
+// Ошибочный ответ, который сервер вернёт вместо следующего вызова
+type fakeFailure struct {
+	status int
+	body   string
+}
+
+// Локальная замена Telegram Bot API
+type fakeTelegram struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu            sync.Mutex
+	calls         []telegramCall
+	updates       []tgbotapi.Update
+	nextUpdateID  int
+	nextMessageID int
+	// Статусы участников для getChatMember, ключ "chatID:userID"
+	members map[string]string
+	// Отправленные ботом сообщения по ID
+	messages map[int]tgbotapi.Message
+	// Очередь ошибочных ответов и число вызовов, получивших ошибку
+	failures []fakeFailure
+	failed   int
+	// Файлы, которые пользователи прислали боту, ключ - file_id
+	files map[string][]byte
+}
+
+func newFakeTelegram(t *testing.T) *fakeTelegram {
+	t.Helper()
+
+	f := &fakeTelegram{t: t, nextUpdateID: 1, nextMessageID: 100, members: make(map[string]string), messages: make(map[int]tgbotapi.Message),
+		files: make(map[string][]byte)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// Клиент tgbotapi, который ходит в фейковый сервер
+func (f *fakeTelegram) newBot() *tgbotapi.BotAPI {
+	f.t.Helper()
+
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint("test-token", f.server.URL+"/bot%s/%s")
+	if err != nil {
+		f.t.Fatalf("не удалось создать бота: %v", err)
+	}
+	return bot
+}
+
+func (f *fakeTelegram) handle(w http.ResponseWriter, r *http.Request) {
+	// Скачивание файла: /file/bot<token>/<file_id>
+	if strings.HasPrefix(r.URL.Path, "/file/") {
+		f.mu.Lock()
+		data, ok := f.files[r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]]
+		f.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+		return
+	}
+
+	// Путь вида /bot<token>/<method>
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if err := r.ParseMultipartForm(32 << 20); err != nil && err != http.ErrNotMultipart {
+		f.t.Errorf("%s: не удалось разобрать параметры: %v", method, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.failures) > 0 && method != "getUpdates" && method != "getMe" {
+		failure := f.failures[0]
+		f.failures = f.failures[1:]
+		f.failed++
+		w.WriteHeader(failure.status)
+		_, _ = w.Write([]byte(failure.body))
+		return
+	}
+
+	var result interface{}
+	switch method {
+	case "getMe":
+		result = tgbotapi.User{ID: 1000, IsBot: true, FirstName: "Kinobot", UserName: fakeBotName}
+	case "getUpdates":
+		offset, _ := strconv.Atoi(r.FormValue("offset"))
+		pending := []tgbotapi.Update{}
+		for _, update := range f.updates {
+			if update.UpdateID >= offset {
+				pending = append(pending, update)
+			}
+		}
+		result = pending
+	case "sendMessage", "sendAnimation", "sendDocument", "editMessageText", "editMessageReplyMarkup":
+		result = f.newMessage(r.Form, false)
+	case "sendPhoto":
+		result = f.newMessage(r.Form, true)
+	case "getFile":
+		fileID := r.FormValue("file_id")
+		if _, ok := f.files[fileID]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: invalid file_id"}`))
+			return
+		}
+		result = tgbotapi.File{FileID: fileID, FilePath: "documents/" + fileID}
+	case "sendMediaGroup":
+		var media []json.RawMessage
+		if err := json.Unmarshal([]byte(r.FormValue("media")), &media); err != nil {
+			f.t.Errorf("sendMediaGroup: некорректный media: %v", err)
+		}
+		messages := make([]tgbotapi.Message, 0, len(media))
+		for range media {
+			message := f.newMessage(r.Form, true)
+			messages = append(messages, message)
+		}
+		result = messages
+	case "deleteMessage", "answerCallbackQuery":
+		result = true
+	case "getChatMember":
+		status := f.members[r.FormValue("chat_id")+":"+r.FormValue("user_id")]
+		if status == "" {
+			status = "member"
+		}
+		userID, _ := strconv.ParseInt(r.FormValue("user_id"), 10, 64)
+		result = tgbotapi.ChatMember{User: &tgbotapi.User{ID: userID}, Status: status}
+	default:
+		f.t.Errorf("фейковый Telegram не поддерживает метод %s", method)
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":404,"description":"Not Found"}`))
+		return
+	}
+
+	if method != "getUpdates" && method != "getMe" {
+		call := telegramCall{Method: method, Params: r.Form, At: time.Now()}
+		if message, ok := result.(tgbotapi.Message); ok {
+			call.MessageID = message.MessageID
+		}
+		if r.MultipartForm != nil {
+			for _, headers := range r.MultipartForm.File {
+				call.FileName = headers[0].Filename
+				if file, err := headers[0].Open(); err == nil {
+					call.File, _ = io.ReadAll(file)
+					file.Close()
+				}
+			}
+		}
+		f.calls = append(f.calls, call)
+	}
+
+	raw, err := json.Marshal(result)
+	if err != nil {
+		f.t.Errorf("%s: не удалось сериализовать ответ: %v", method, err)
+	}
+	_ = json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: raw})
+}
+
+// Ответное сообщение бота на вызов send*/edit*. Как и Telegram, возвращает текст
+// без HTML-разметки и кнопки сообщения.
+func (f *fakeTelegram) newMessage(params url.Values, photo bool) tgbotapi.Message {
+	chatID, _ := strconv.ParseInt(params.Get("chat_id"), 10, 64)
+	messageID, _ := strconv.Atoi(params.Get("message_id"))
+	if messageID == 0 {
+		f.nextMessageID++
+		messageID = f.nextMessageID
+	}
+	message := tgbotapi.Message{
+		MessageID: messageID,
+		From:      &tgbotapi.User{ID: 1000, IsBot: true, UserName: fakeBotName},
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: chatType(chatID)},
+		Date:      int(time.Now().Unix()),
+		Text:      params.Get("text"),
+		Caption:   params.Get("caption"),
+	}
+	if params.Get("parse_mode") == "HTML" {
+		message.Text = strings.TrimSpace(render.Plain(message.Text))
+		message.Caption = strings.TrimSpace(render.Plain(message.Caption))
+	}
+	if photo {
+		message.Photo = photoSizes(messageID)
+	}
+	if markup := params.Get("reply_markup"); markup != "" {
+		var keyboard tgbotapi.InlineKeyboardMarkup
+		if err := json.Unmarshal([]byte(markup), &keyboard); err == nil && len(keyboard.InlineKeyboard) > 0 {
+			message.ReplyMarkup = &keyboard
+		}
+	}
+	// Правка кнопок не меняет текст сообщения, правка текста не меняет фото
+	if prev, ok := f.messages[messageID]; ok {
+		if message.Text == "" && message.Caption == "" {
+			message.Text, message.Caption = prev.Text, prev.Caption
+		}
+		message.Photo = prev.Photo
+	}
+	f.messages[messageID] = message
+	return message
+}
+
+// Размеры загруженного фото: file_id последнего, самого большого, - "photo-<ID сообщения>"
+func photoSizes(messageID int) []tgbotapi.PhotoSize {
+	return []tgbotapi.PhotoSize{
+		{FileID: fmt.Sprintf("photo-%d-small", messageID), Width: 90, Height: 90},
+		{FileID: fmt.Sprintf("photo-%d", messageID), Width: 600, Height: 900},
+	}
+}
+
+// В Telegram ID групповых чатов отрицательные
+func chatType(chatID int64) string {
+	if chatID < 0 {
+		return "supergroup"
+	}
+	return "private"
+}
+
+// Файл, который можно скачать через getFile
+func (f *fakeTelegram) addFile(fileID string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.files[fileID] = data
+}
+
+// Назначение статуса участника чата: "creator", "administrator", "member"
+func (f *fakeTelegram) setMember(chatID, userID int64, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.members[strconv.FormatInt(chatID, 10)+":"+strconv.FormatInt(userID, 10)] = status
+}
+
+// Следующие вызовы получат ошибочные ответы в заданном порядке
+func (f *fakeTelegram) failNext(failures ...fakeFailure) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures = append(f.failures, failures...)
+}
+
+func (f *fakeTelegram) pushUpdate(update tgbotapi.Update) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	update.UpdateID = f.nextUpdateID
+	f.nextUpdateID++
+	f.updates = append(f.updates, update)
+}
+
+// Вызовы Bot API, накопленные с момента последнего takeCalls
+func (f *fakeTelegram) takeCalls() []telegramCall {
+	// Часть сообщений бот отправляет без ожидания, дожидаемся их доставки
+	sender.Flush()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	calls := f.calls
+	f.calls = nil
+	return calls
+}
+
+// Пользователь, который пишет боту в сценарии
+type testUser struct {
+	ID        int64
+	ChatID    int64
+	FirstName string
+	UserName  string
+}
+
+// Сценарий диалога с ботом через настоящий клиент tgbotapi и обработчики бота
+type conversation struct {
+	t         *testing.T
+	tg        *fakeTelegram
+	kinopoisk *fakeKinopoisk
+	bot       *tgbotapi.BotAPI
+	offset    int
+}
+
+func newConversation(t *testing.T) *conversation {
+	t.Helper()
+
+	t.Setenv("API_KEY", "test-key")
+	withoutPacing(t)
+	if err := storage.Open(""); err != nil {
+		t.Fatalf("не удалось открыть хранилище: %v", err)
+	}
+	kinopoisk := newFakeKinopoisk(t)
+	prev := api.BaseURL
+	api.BaseURL = kinopoisk.URL + "/v1.4"
+	t.Cleanup(func() { api.BaseURL = prev })
+
+	tg := newFakeTelegram(t)
+	prevFileURL := transfer.FileURL
+	transfer.FileURL = tg.server.URL + "/file/bot%s/%s"
+	t.Cleanup(func() { transfer.FileURL = prevFileURL })
+	return &conversation{t: t, tg: tg, kinopoisk: kinopoisk, bot: tg.newBot(), offset: 0}
+}
+
+// Отключение пауз очереди отправки, чтобы сценарии не ждали лимитов Telegram
+func withoutPacing(t *testing.T) {
+	prevChat, prevGlobal, prevRetry, prevUnit := sender.ChatInterval, sender.GlobalInterval, sender.RetryDelay, sender.RetryAfterUnit
+	sender.ChatInterval, sender.GlobalInterval, sender.RetryDelay, sender.RetryAfterUnit = 0, 0, 0, 0
+	t.Cleanup(func() {
+		sender.ChatInterval, sender.GlobalInterval, sender.RetryDelay, sender.RetryAfterUnit = prevChat, prevGlobal, prevRetry, prevUnit
+	})
+}
+
+// Получение обновлений через getUpdates и их обработка ботом
+func (c *conversation) process() []telegramCall {
+	c.t.Helper()
+
+	updates, err := c.bot.GetUpdates(tgbotapi.UpdateConfig{Offset: c.offset})
+	if err != nil {
+		c.t.Fatalf("getUpdates: %v", err)
+	}
+	for _, update := range updates {
+		c.offset = update.UpdateID + 1
+		handlers.HandleUpdate(c.bot, update)
+	}
+	return c.tg.takeCalls()
+}
+
+// Пользователь отправляет текстовое сообщение
+func (c *conversation) say(user testUser, text string) []telegramCall {
+	c.t.Helper()
+	return c.reply(user, text, nil)
+}
+
+// Пользователь отправляет сообщение в ответ на replyTo (nil - обычное сообщение)
+func (c *conversation) reply(user testUser, text string, replyTo *tgbotapi.Message) []telegramCall {
+	c.t.Helper()
+	return c.post(user, tgbotapi.Message{Text: text, Entities: commandEntities(text), ReplyToMessage: replyTo})
+}
+
+// Пользователь отправляет файл документом
+func (c *conversation) document(user testUser, fileName string, data []byte) []telegramCall {
+	c.t.Helper()
+	fileID := "file-" + fileName
+	c.tg.addFile(fileID, data)
+	return c.post(user, tgbotapi.Message{Document: &tgbotapi.Document{FileID: fileID, FileName: fileName, FileSize: len(data)}})
+}
+
+// Пользователь отправляет фото с подписью
+func (c *conversation) photo(user testUser, fileID string, caption string) []telegramCall {
+	c.t.Helper()
+	return c.post(user, tgbotapi.Message{Caption: caption, Photo: []tgbotapi.PhotoSize{
+		{FileID: fileID + "-small", Width: 90, Height: 90},
+		{FileID: fileID, Width: 1280, Height: 720},
+	}})
+}
+
+// Отправка сообщения от пользователя: ID, автор, чат и дата заполняются здесь
+func (c *conversation) post(user testUser, message tgbotapi.Message) []telegramCall {
+	c.t.Helper()
+
+	c.tg.mu.Lock()
+	c.tg.nextMessageID++
+	message.MessageID = c.tg.nextMessageID
+	c.tg.mu.Unlock()
+
+	message.From = &tgbotapi.User{ID: user.ID, FirstName: user.FirstName, UserName: user.UserName}
+	message.Chat = &tgbotapi.Chat{ID: user.ChatID, Type: chatType(user.ChatID)}
+	message.Date = int(time.Now().Unix())
+	c.tg.pushUpdate(tgbotapi.Update{Message: &message})
+	return c.process()
+}
+
+// Пользователь нажимает inline-кнопку под сообщением бота
+func (c *conversation) press(user testUser, messageID int, data string) []telegramCall {
+	c.t.Helper()
+
+	c.tg.mu.Lock()
+	message, ok := c.tg.messages[messageID]
+	c.tg.mu.Unlock()
+	if !ok {
+		message = tgbotapi.Message{
+			MessageID: messageID,
+			From:      &tgbotapi.User{ID: 1000, IsBot: true, UserName: fakeBotName},
+			Chat:      &tgbotapi.Chat{ID: user.ChatID, Type: chatType(user.ChatID)},
+		}
+	}
+
+	c.tg.pushUpdate(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      strconv.Itoa(messageID) + ":" + data,
+		From:    &tgbotapi.User{ID: user.ID, FirstName: user.FirstName, UserName: user.UserName},
+		Message: &message,
+		Data:    data,
+	}})
+	return c.process()
+}
+
+// Разметка команды в начале текста, как её присылает Telegram
+func commandEntities(text string) []tgbotapi.MessageEntity {
+	if !strings.HasPrefix(text, "/") {
+		return nil
+	}
+	command := strings.Fields(text)[0]
+	return []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(utf16.Encode([]rune(command)))}}
+}
+
+// Тексты отправленных сообщений по порядку
+func texts(calls []telegramCall) []string {
+	var result []string
+	for _, call := range calls {
+		if call.Method == "sendMessage" {
+			result = append(result, call.Params.Get("text"))
+		}
+	}
+	return result
+}
+
+// callback_data кнопки с заданным текстом из reply_markup
+func buttonData(t *testing.T, markup string, text string) string {
+	t.Helper()
+	var keyboard tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(markup), &keyboard); err != nil {
+		t.Fatalf("некорректный reply_markup: %v", err)
+	}
+	for _, row := range keyboard.InlineKeyboard {
+		for _, button := range row {
+			if button.Text == text && button.CallbackData != nil {
+				return *button.CallbackData
+			}
+		}
+	}
+	t.Fatalf("нет кнопки %q в %s", text, markup)
+	return ""
+}
+
+// Поиск вызова метода в списке
+func findCall(calls []telegramCall, method string) (telegramCall, bool) {
+	for _, call := range calls {
+		if call.Method == method {
+			return call, true
+		}
+	}
+	return telegramCall{}, false
+}
+
+// Локальная замена API Кинопоиска на фикстурах
+type fakeKinopoisk struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// Фильмы для запроса по списку ID, ключ - ID фильма
+	movies map[string]string
+	// Запросы по списку ID
+	batches [][]string
+	// Сезоны сериалов (JSON массива docs), ключ - ID сериала
+	seasons map[string]string
+	// Число запросов поиска людей
+	personSearches int
+}
+
+// Ответ на запрос сезонов сериала
+func (f *fakeKinopoisk) setSeasons(id string, docs string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seasons[id] = docs
+}
+
+// Ответ на запросы по ID для фильма (JSON документа)
+func (f *fakeKinopoisk) setMovie(id string, doc string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.movies[id] = doc
+}
+
+func (f *fakeKinopoisk) takeBatches() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	batches := f.batches
+	f.batches = nil
+	return batches
+}
+
+func newFakeKinopoisk(t *testing.T) *fakeKinopoisk {
+	t.Helper()
+
+	fake := &fakeKinopoisk{movies: make(map[string]string), seasons: make(map[string]string)}
+
+	search := loadFixture(t, "search")
+	empty := loadFixture(t, "search_empty")
+	details := loadFixture(t, "details")
+	personSearch := loadFixture(t, "person_search")
+	person := loadFixture(t, "person")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1.4/movie/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-KEY") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"statusCode":401,"message":"В запросе не указан токен!","error":"Unauthorized"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		query := strings.ToLower(r.URL.Query().Get("query"))
+		if strings.Contains(query, "интерстеллар") || strings.Contains(query, "interstellar") {
+			_, _ = w.Write([]byte(search.Response.Body))
+			return
+		}
+		_, _ = w.Write([]byte(empty.Response.Body))
+	})
+	// Подборка по фильтрам отвечает теми же фильмами, что и поиск,
+	// а запрос по списку ID Кинопоиска или IMDb - заданными через setMovie
+	mux.HandleFunc("/v1.4/movie", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if imdbIDs := r.URL.Query()["externalId.imdb"]; len(imdbIDs) > 0 {
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			fake.batches = append(fake.batches, imdbIDs)
+			var docs []string
+			for _, doc := range fake.movies {
+				for _, id := range imdbIDs {
+					if strings.Contains(doc, `"imdb":"`+id+`"`) {
+						docs = append(docs, doc)
+					}
+				}
+			}
+			_, _ = w.Write([]byte(`{"docs":[` + strings.Join(docs, ",") + `]}`))
+			return
+		}
+		ids := r.URL.Query()["id"]
+		if len(ids) == 0 {
+			_, _ = w.Write([]byte(search.Response.Body))
+			return
+		}
+
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		fake.batches = append(fake.batches, ids)
+		var docs []string
+		for _, id := range ids {
+			if doc, ok := fake.movies[id]; ok {
+				docs = append(docs, doc)
+			}
+		}
+		_, _ = w.Write([]byte(`{"docs":[` + strings.Join(docs, ",") + `]}`))
+	})
+	// Галерея: у «Интерстеллара» 12 изображений, у остальных фильмов - ни одного
+	mux.HandleFunc("/v1.4/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		total := 0
+		if r.URL.Query().Get("movieId") == "258687" {
+			total = 12
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		var docs []string
+		for i := (page - 1) * limit; i < total && i < page*limit; i++ {
+			docs = append(docs, fmt.Sprintf(`{"url":"https://images.example/258687/%d.jpg","type":"still"}`, i+1))
+		}
+		pages := (total + limit - 1) / limit
+		_, _ = fmt.Fprintf(w, `{"docs":[%s],"total":%d,"page":%d,"pages":%d}`, strings.Join(docs, ","), total, page, pages)
+	})
+	mux.HandleFunc("/v1.4/movie/258687", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(details.Response.Body))
+	})
+	// Люди: находится только Кристофер Нолан, подробно - тот, что режиссёр
+	mux.HandleFunc("/v1.4/person/search", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		fake.personSearches++
+		fake.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		query := strings.ToLower(r.URL.Query().Get("query"))
+		if strings.Contains(query, "нолан") || strings.Contains(query, "nolan") {
+			_, _ = w.Write([]byte(personSearch.Response.Body))
+			return
+		}
+		_, _ = w.Write([]byte(empty.Response.Body))
+	})
+	mux.HandleFunc("/v1.4/person/41477", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(person.Response.Body))
+	})
+	mux.HandleFunc("/v1.4/season", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fake.mu.Lock()
+		docs, ok := fake.seasons[r.URL.Query().Get("movieId")]
+		fake.mu.Unlock()
+		if !ok {
+			docs = "[]"
+		}
+		_, _ = fmt.Fprintf(w, `{"docs":%s,"total":0,"page":1,"pages":1}`, docs)
+	})
+	// Подробная информация о других фильмах - заданная через setMovie
+	mux.HandleFunc("/v1.4/movie/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fake.mu.Lock()
+		doc, ok := fake.movies[strings.TrimPrefix(r.URL.Path, "/v1.4/movie/")]
+		fake.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"statusCode":404,"message":"Фильм не найден","error":"Not Found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(doc))
+	})
+
+	fake.Server = httptest.NewServer(mux)
+	t.Cleanup(fake.Server.Close)
+	return fake
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/dialog"
+	"github.com/luzhnov-aleksei/kinobot/diary"
+	"github.com/luzhnov-aleksei/kinobot/transfer"
+	"github.com/luzhnov-aleksei/kinobot/watchlist"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransfer_Parse(t *testing.T) {
+	date := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		fileName string
+		data     string
+		format   string
+		rows     []transfer.Row
+	}{
+		{
+			name:     "Letterboxd ratings",
+			fileName: "ratings.csv",
+			data:     "\xef\xbb\xbfDate,Name,Year,Letterboxd URI,Rating\n2024-01-05,Interstellar,2014,https://boxd.it/1,4.5\n",
+			format:   transfer.FormatLetterboxd,
+			rows:     []transfer.Row{{Title: "Interstellar", Year: 2014, Score: 9, Date: date, Watched: true}},
+		},
+		{
+			name:     "Letterboxd watchlist",
+			fileName: "watchlist.csv",
+			data:     "Date,Name,Year,Letterboxd URI\n2024-01-05,Dune,2021,https://boxd.it/2\n",
+			format:   transfer.FormatLetterboxd,
+			rows:     []transfer.Row{{Title: "Dune", Year: 2021, Date: date}},
+		},
+		{
+			name:     "IMDb ratings",
+			fileName: "ratings.csv",
+			data:     "Const,Your Rating,Date Rated,Title,URL,Title Type,IMDb Rating,Year\ntt0816692,10,2024-01-05,Interstellar,https://imdb.com,Movie,8.7,2014\n",
+			format:   transfer.FormatIMDb,
+			rows:     []transfer.Row{{IMDbID: "tt0816692", Title: "Interstellar", Year: 2014, Score: 10, Date: date, Watched: true}},
+		},
+		{
+			name:     "IMDb watchlist",
+			fileName: "WATCHLIST.csv",
+			data:     "Position,Const,Created,Modified,Description,Title,Year\n1,tt1160419,2024-01-05,2024-01-05,,Dune,2021\n",
+			format:   transfer.FormatIMDb,
+			rows:     []transfer.Row{{IMDbID: "tt1160419", Title: "Dune", Year: 2021, Date: date}},
+		},
+		{
+			name:     "CSV дневника бота",
+			fileName: "kinobot_diary.csv",
+			data:     "kinopoisk_id,title,original_title,year,date,rating,note\n258687,Интерстеллар,Interstellar,2014,2024-01-05,9,\"IMAX, второй раз\"\n",
+			format:   transfer.FormatKinobot,
+			rows:     []transfer.Row{{KinopoiskID: 258687, Title: "Интерстеллар", Year: 2014, Score: 9, Date: date, Note: "IMAX, второй раз", Watched: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, rows, err := transfer.Parse(tt.fileName, []byte(tt.data))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.format, format)
+			assert.Equal(t, tt.rows, rows)
+		})
+	}
+
+	_, _, err := transfer.Parse("notes.csv", []byte("foo,bar\n1,2\n"))
+	assert.Error(t, err)
+	_, _, err = transfer.Parse("data.json", []byte(`{"foo": 1}`))
+	assert.Error(t, err)
+}
+
+func TestE2E_ExportImport(t *testing.T) {
+	c := newConversation(t)
+	owner := testUser{ID: 1901, ChatID: 1901, FirstName: "Лена"}
+	friend := testUser{ID: 1902, ChatID: 1902, FirstName: "Паша"}
+
+	interstellar := api.Cinema{ID: 258687, Name: "Интерстеллар", AlternativeName: "Interstellar", Year: 2014}
+	science := api.Cinema{ID: 1046206, Name: "Интерстеллар: Наука", Year: 2015}
+	rated := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+	_, err := diary.Import(owner.ID, []diary.Entry{{Movie: interstellar, Score: 9, Date: rated, Note: "IMAX"}})
+	assert.NoError(t, err)
+	_, err = watchlist.Import(owner.ChatID, []watchlist.Entry{{Movie: science, AddedBy: owner.ID, AddedAt: rated}})
+	assert.NoError(t, err)
+
+	calls := c.say(friend, "/export")
+	assert.Equal(t, "Экспортировать пока нечего: список /list и дневник /diary пусты.", calls[0].Params.Get("text"))
+
+	calls = c.say(owner, "/export")
+	assert.Contains(t, calls[0].Params.Get("text"), "📤 Экспорт: в списке 1, в дневнике 1.")
+	files := make(map[string]string)
+	for _, call := range calls {
+		if call.Method == "sendDocument" {
+			files[call.FileName] = string(call.File)
+		}
+	}
+	assert.Len(t, files, 4)
+	assert.Equal(t, "Title,Year,Rating10,WatchedDate,Review\nInterstellar,2014,9,2024-03-01,IMAX\n", files["letterboxd.csv"])
+	assert.Contains(t, files["kinobot_watchlist.csv"], "1046206,Интерстеллар: Наука,,2015,2024-03-01,false,")
+	assert.Contains(t, files["kinobot_diary.csv"], "258687,Интерстеллар,Interstellar,2014,2024-03-01,9,IMAX")
+	var export transfer.Export
+	assert.NoError(t, json.Unmarshal([]byte(files["kinobot.json"]), &export))
+	assert.Equal(t, 1, export.Version)
+
+	// Импорт JSON-экспорта другим пользователем: фильмы запрашиваются по ID
+	c.kinopoisk.setMovie("258687", `{"id":258687,"name":"Интерстеллар","year":2014}`)
+	c.kinopoisk.setMovie("1046206", `{"id":1046206,"name":"Интерстеллар: Наука","year":2015}`)
+	calls = c.say(friend, "/import")
+	assert.Contains(t, calls[0].Params.Get("text"), "📥 Отправьте файл CSV или JSON")
+	calls = c.say(friend, "интерстеллар")
+	assert.Equal(t, []string{"Пришлите файл CSV или JSON документом или отправьте /cancel."}, texts(calls))
+
+	calls = c.document(friend, "kinobot.json", []byte(files["kinobot.json"]))
+	assert.Equal(t, []string{"📥 Импорт «kinobot.json» (Kinobot):\n• в дневник: 1\n• в список: 1"}, texts(calls))
+	assert.Equal(t, [][]string{{"1046206", "258687"}}, c.kinopoisk.takeBatches())
+	entries := diary.Load(friend.ID).Entries
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, 9, entries[0].Score)
+		assert.Equal(t, "IMAX", entries[0].Note)
+		assert.True(t, entries[0].Date.Equal(rated))
+	}
+	assert.Len(t, watchlist.Load(friend.ChatID).Entries, 1)
+}
+
+func TestE2E_ImportAmbiguous(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 1903, ChatID: 1903, FirstName: "Оля"}
+
+	c.say(user, "/import")
+	ratings := "Date,Name,Year,Letterboxd URI,Rating\n" +
+		"2024-01-05,Interstellar,,https://boxd.it/1,4.5\n" +
+		"2024-01-06,Интерстеллар,2015,https://boxd.it/2,3\n" +
+		"2024-01-07,Несуществующий фильм,2000,https://boxd.it/3,5\n"
+	calls := c.document(user, "ratings.csv", []byte(ratings))
+	messages := texts(calls)
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, "📥 Импорт «ratings.csv» (Letterboxd):\n• в дневник: 1\n• в список: 0\n"+
+			"• не найдено: 1 - Несуществующий фильм (2000)\n\nНужно уточнить фильмов: 1.", messages[0])
+		assert.Equal(t, "❓ «Interstellar»: какой это фильм?", messages[1])
+	}
+	question := calls[len(calls)-1]
+	markup := question.Params.Get("reply_markup")
+	assert.Contains(t, markup, `"text":"Интерстеллар (2014)"`)
+	assert.Contains(t, markup, `"text":"Межзвёздный (2019)"`)
+	assert.NotContains(t, markup, "Наука")
+
+	// Выбор варианта добавляет фильм в дневник с оценкой из файла
+	pick := buttonData(t, markup, "Интерстеллар (2014)")
+	calls = c.press(user, question.MessageID, pick)
+	edit, ok := findCall(calls, "editMessageText")
+	assert.True(t, ok)
+	assert.Equal(t, "«Interstellar»: «Интерстеллар» добавлен в дневник", edit.Params.Get("text"))
+	assert.Equal(t, []string{"✅ Импорт завершён. Список: /list, дневник: /diary"}, texts(calls))
+
+	movie := diary.Load(user.ID).Find(258687)
+	if assert.NotNil(t, movie) {
+		assert.Equal(t, 9, movie.Score)
+	}
+	assert.Equal(t, 6, diary.Load(user.ID).Find(1046206).Score)
+
+	// Повторное нажатие ничего не добавляет
+	calls = c.press(user, question.MessageID, pick)
+	edit, _ = findCall(calls, "editMessageText")
+	assert.Contains(t, edit.Params.Get("text"), "На этот вопрос уже ответили.")
+}
+
+func TestE2E_ImportIMDbAndBudget(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 1904, ChatID: 1904, FirstName: "Оля"}
+
+	// Строки IMDb ищутся по ID, а не по названию
+	c.kinopoisk.setMovie("258687", `{"id":258687,"name":"Интерстеллар","year":2014,"externalId":{"imdb":"tt0816692"}}`)
+	c.say(user, "/import")
+	ratings := "Const,Your Rating,Date Rated,Title,URL,Title Type,IMDb Rating,Year\n" +
+		"tt0816692,10,2024-01-05,Interstellar,https://imdb.com,Movie,8.7,2014\n"
+	calls := c.document(user, "ratings.csv", []byte(ratings))
+	assert.Equal(t, []string{"📥 Импорт «ratings.csv» (IMDb):\n• в дневник: 1\n• в список: 0"}, texts(calls))
+	assert.Equal(t, [][]string{{"tt0816692"}}, c.kinopoisk.takeBatches())
+	assert.Equal(t, 10, diary.Load(user.ID).Find(258687).Score)
+
+	// Строк импортируется не больше, чем позволяет остаток лимита API
+	threshold := transfer.APIReserveThreshold
+	t.Cleanup(func() { transfer.APIReserveThreshold = threshold })
+	transfer.APIReserveThreshold = api.RequestsToday() + 1
+	c.say(user, "/import")
+	watchlistFile := "Date,Name,Year,Letterboxd URI\n2024-01-05,Интерстеллар: Наука,2015,https://boxd.it/1\n2024-01-06,Межзвёздный,2019,https://boxd.it/2\n"
+	calls = c.document(user, "watchlist.csv", []byte(watchlistFile))
+	assert.Equal(t, []string{"📥 Импорт «watchlist.csv» (Letterboxd):\n• в дневник: 0\n• в список: 1\n" +
+		"• не вошло в лимит: 1, отправьте их отдельным файлом"}, texts(calls))
+
+	transfer.APIReserveThreshold = api.RequestsToday()
+	c.say(user, "/import")
+	calls = c.document(user, "watchlist.csv", []byte(watchlistFile))
+	assert.Contains(t, texts(calls)[0], "Лимит запросов к Кинопоиску на сегодня почти исчерпан")
+}
+
+func TestE2E_ImportWaitsForPendingQuestions(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 1905, ChatID: 1905, FirstName: "Оля"}
+
+	c.say(user, "/import")
+	calls := c.document(user, "ratings.csv", []byte("Date,Name,Year,Letterboxd URI,Rating\n2024-01-05,Interstellar,,https://boxd.it/1,4.5\n"))
+	first := calls[len(calls)-1]
+	assert.Equal(t, "❓ «Interstellar»: какой это фильм?", first.Params.Get("text"))
+
+	// Новый импорт не начинается, пока не отвечены вопросы прежнего: бот повторяет вопрос
+	calls = c.say(user, "/import")
+	assert.Equal(t, []string{
+		"Сначала закончите предыдущий импорт: ответьте на вопрос ниже или нажмите «Пропустить все».",
+		"❓ «Interstellar»: какой это фильм?",
+	}, texts(calls))
+	_, ok := dialog.Active(user.ChatID, user.ID)
+	assert.False(t, ok)
+	pending, ok := transfer.LoadPending(user.ID)
+	if assert.True(t, ok) {
+		assert.Len(t, pending.Questions, 1)
+		assert.Equal(t, calls[1].MessageID, pending.MessageID)
+	}
+
+	// Кнопки старого вопроса больше не действуют
+	calls = c.press(user, first.MessageID, "im:stop")
+	edit, _ := findCall(calls, "editMessageText")
+	assert.Contains(t, edit.Params.Get("text"), "На этот вопрос уже ответили.")
+}
+
+func TestE2E_ImportCapsDownloadSize(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 1906, ChatID: 1906, FirstName: "Оля"}
+
+	// Размер файла в сообщении не указан: скачивание всё равно ограничено 1 МБ
+	c.say(user, "/import")
+	c.tg.addFile("file-big.csv", bytes.Repeat([]byte("a"), 1<<20+1))
+	calls := c.post(user, tgbotapi.Message{Document: &tgbotapi.Document{FileID: "file-big.csv", FileName: "big.csv"}})
+	assert.Equal(t, []string{"Не удалось скачать файл, попробуйте ещё раз."}, texts(calls))
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/stretchr/testify/assert"
+)
+
+// Все значения type и typeNumber, которые отдаёт Кинопоиск
+var providerTypes = []struct {
+	slug   string
+	number int
+	want   api.MovieType
+	name   string
+	series bool
+}{
+	{"movie", 1, api.TypeMovie, "Фильм", false},
+	{"tv-series", 2, api.TypeTVSeries, "Сериал", true},
+	{"cartoon", 3, api.TypeCartoon, "Мультфильм", false},
+	{"anime", 4, api.TypeAnime, "Аниме", false},
+	{"animated-series", 5, api.TypeAnimatedSeries, "Мультсериал", true},
+	{"tv-show", 6, api.TypeTVShow, "ТВ-шоу", true},
+}
+
+func TestMovieType_ProviderValues(t *testing.T) {
+	assert.Len(t, api.MovieTypes, len(providerTypes))
+	for _, tt := range providerTypes {
+		t.Run(tt.slug, func(t *testing.T) {
+			assert.Equal(t, tt.want, api.ParseType(tt.slug))
+			assert.Equal(t, tt.want, api.ParseType(strings.ToUpper(tt.slug)))
+			assert.Equal(t, tt.want, api.TypeFromNumber(tt.number))
+			assert.Equal(t, tt.slug, tt.want.Slug())
+			assert.Equal(t, tt.series, tt.want.IsSeries())
+			assert.Equal(t, tt.name, movies.TypeName(tt.want))
+			assert.NotEmpty(t, movies.TypeEmoji(tt.want))
+
+			// Тип определяется и по номеру, и по строке, если номера нет
+			var byNumber, bySlug api.Cinema
+			assert.NoError(t, json.Unmarshal([]byte(`{"id":1,"typeNumber":`+strconv.Itoa(tt.number)+`}`), &byNumber))
+			assert.NoError(t, json.Unmarshal([]byte(`{"id":1,"type":"`+tt.slug+`"}`), &bySlug))
+			assert.Equal(t, tt.want, byNumber.MovieType())
+			assert.Equal(t, tt.want, bySlug.MovieType())
+
+			linked := api.LinkedMovie{ID: 1, Type: tt.slug}.Cinema()
+			assert.Equal(t, tt.number, linked.TypeNumber)
+		})
+	}
+}
+
+func TestMovieType_Unknown(t *testing.T) {
+	for _, value := range []string{"", "mini-series", "0", "7", "-1"} {
+		assert.Equal(t, api.TypeUnknown, api.ParseType(value), value)
+	}
+	assert.Equal(t, api.TypeUnknown, api.TypeFromNumber(99))
+	assert.Equal(t, "", api.TypeUnknown.Slug())
+	assert.Equal(t, "", movies.TypeName(api.TypeUnknown))
+	assert.Equal(t, "🎞", movies.TypeEmoji(api.TypeUnknown))
+
+	// Неизвестный номер не мешает определить тип по строке
+	movie := api.Cinema{TypeNumber: 99, Type: "tv-show"}
+	assert.Equal(t, api.TypeTVShow, movie.MovieType())
+	assert.Equal(t, []string{"movie", "anime"}, api.TypeFilter(api.TypeMovie, api.TypeUnknown, api.TypeAnime))
+}
+
+func TestMovieType_Aliases(t *testing.T) {
+	assert.Equal(t, api.TypeTVSeries, api.ParseType(" Сериал "))
+	assert.Equal(t, api.TypeCartoon, api.ParseType("мультики"))
+	assert.Equal(t, api.TypeTVShow, api.ParseType("тв-шоу"))
+	assert.Equal(t, api.TypeAnime, api.ParseType("4"))
+}
+
+func TestMovieType_SearchFilter(t *testing.T) {
+	query, types := movies.SplitTypeFilter("Тьма Тип:сериал,аниме")
+	assert.Equal(t, "Тьма", query)
+	assert.Equal(t, []api.MovieType{api.TypeTVSeries, api.TypeAnime}, types)
+
+	// Нераспознанный фильтр остаётся в запросе
+	query, types = movies.SplitTypeFilter("тип:абв Матрица")
+	assert.Equal(t, "тип:абв Матрица", query)
+	assert.Empty(t, types)
+
+	found := []api.Cinema{{ID: 1, TypeNumber: 1}, {ID: 2, Type: "tv-series"}, {ID: 3}}
+	assert.Equal(t, found, movies.FilterByType(found, nil))
+	filtered := movies.FilterByType(found, []api.MovieType{api.TypeTVSeries})
+	if assert.Len(t, filtered, 1) {
+		assert.Equal(t, uint32(2), filtered[0].ID)
+	}
+}
+
+func TestMovieType_UnknownCard(t *testing.T) {
+	movie := &api.Cinema{ID: 7, Name: "Неведомое", Year: 2020, Type: "mini-series", TypeNumber: 12}
+	text, _, err := movies.FormatMovieInfo(movie)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(text, "Неведомое (2020)"), text)
+	assert.Equal(t, "", movies.CardData(movie).Type)
+	assert.False(t, movies.IsSeries(movie))
+
+	show := &api.Cinema{ID: 8, Name: "Шоу", Type: "tv-show"}
+	assert.True(t, movies.IsSeries(show))
+	assert.Equal(t, "ТВ-шоу", movies.CardData(show).Type)
+}
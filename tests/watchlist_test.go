@@ -0,0 +1,123 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/watchlist"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2E_SharedWatchlist(t *testing.T) {
+	c := newConversation(t)
+	const chatID = -2001
+	alice := testUser{ID: 1101, ChatID: chatID, FirstName: "Алиса", UserName: "alice"}
+	bob := testUser{ID: 1102, ChatID: chatID, FirstName: "Боб"}
+
+	calls := c.say(alice, "/list")
+	assert.Contains(t, calls[0].Params.Get("text"), "Список пуст")
+
+	// Карточка фильма предлагает добавить его в список
+	c.say(alice, "/film интерстеллар")
+	calls = c.press(alice, 0, "258687")
+	card, _ := findCall(calls, "sendPhoto")
+	assert.Contains(t, card.Params.Get("reply_markup"), `"callback_data":"wl:add:258687"`)
+
+	calls = c.press(alice, card.MessageID, "wl:add:258687")
+	added, _ := findCall(calls, "sendMessage")
+	assert.Contains(t, added.Params.Get("text"), "Интерстеллар добавлен в список")
+	edit, ok := findCall(calls, "editMessageReplyMarkup")
+	assert.True(t, ok)
+	assert.Contains(t, edit.Params.Get("reply_markup"), "wl:seen:258687")
+
+	// Повторное добавление не дублирует запись
+	calls = c.press(bob, card.MessageID, "wl:add:258687")
+	_, ok = findCall(calls, "sendMessage")
+	assert.False(t, ok)
+
+	calls = c.press(bob, 0, "1046206")
+	card, _ = findCall(calls, "sendPhoto")
+	c.press(bob, card.MessageID, "wl:add:1046206")
+
+	list := watchlist.Load(chatID)
+	assert.Len(t, list.Entries, 2)
+	assert.Equal(t, "@alice", list.Entries[0].AddedByName)
+	assert.Equal(t, "Боб", list.Entries[1].AddedByName)
+	assert.False(t, list.Entries[0].AddedAt.IsZero())
+
+	// Голос поднимает фильм Боба наверх списка
+	calls = c.say(alice, "/list")
+	listMsg := calls[0]
+	assert.Contains(t, listMsg.Params.Get("text"), "1. <b>Интерстеллар</b> (2014) · фантастика, драма · КП 8.7 - добавил(а) @alice")
+	assert.Equal(t, "HTML", listMsg.Params.Get("parse_mode"))
+
+	calls = c.press(alice, listMsg.MessageID, "wl:vote:1046206")
+	edit, _ = findCall(calls, "editMessageText")
+	assert.Contains(t, edit.Params.Get("text"), "1. <b>Интерстеллар: Наука</b> (2015) · документальный · КП 7.5 - добавил(а) Боб")
+	assert.Contains(t, edit.Params.Get("text"), "👍 1")
+
+	// Отметка просмотра убирает фильм из непросмотренных
+	calls = c.press(bob, listMsg.MessageID, "wl:seen:258687")
+	seen, _ := findCall(calls, "sendMessage")
+	assert.Contains(t, seen.Params.Get("text"), "Интерстеллар отмечен просмотренным")
+
+	calls = c.say(alice, "/list watched")
+	assert.Contains(t, calls[0].Params.Get("text"), "Просмотрено (1, страница 1 из 1)")
+
+	// /random выбирает только из непросмотренных
+	calls = c.say(bob, "/random")
+	assert.Contains(t, calls[0].Params.Get("text"), "Случайный выбор из списка (добавил(а) Боб)")
+	assert.Contains(t, calls[0].Params.Get("text"), "Интерстеллар: Наука")
+}
+
+func TestWatchlist_PrivateListsAreSeparate(t *testing.T) {
+	c := newConversation(t)
+	anna := testUser{ID: 1201, ChatID: 1201, FirstName: "Анна"}
+	boris := testUser{ID: 1202, ChatID: 1202, FirstName: "Борис"}
+
+	c.say(anna, "интерстеллар")
+	calls := c.press(anna, 0, "258687")
+	card, _ := findCall(calls, "sendPhoto")
+	c.press(anna, card.MessageID, "wl:add:258687")
+
+	assert.Len(t, watchlist.Load(anna.ChatID).Entries, 1)
+	assert.Empty(t, watchlist.Load(boris.ChatID).Entries)
+
+	calls = c.say(boris, "/random")
+	assert.Contains(t, calls[0].Params.Get("text"), "нет непросмотренных")
+}
+
+func TestE2E_WatchedListIsPaged(t *testing.T) {
+	c := newConversation(t)
+	user := testUser{ID: 1301, ChatID: 1301, FirstName: "Вера"}
+
+	var entries []watchlist.Entry
+	for i := 1; i <= 12; i++ {
+		entries = append(entries, watchlist.Entry{
+			Movie:       api.Cinema{ID: uint32(900000 + i), Name: fmt.Sprintf("Фильм %d", i), Year: 2000},
+			AddedByName: "Вера",
+			Watched:     true,
+			WatchedAt:   time.Now(),
+		})
+	}
+	_, err := watchlist.Import(user.ChatID, entries)
+	assert.NoError(t, err)
+
+	// По 5 фильмов на странице, как и в /list
+	calls := c.say(user, "/list watched")
+	text := calls[0].Params.Get("text")
+	assert.Contains(t, text, "Просмотрено (12, страница 1 из 3)")
+	assert.Contains(t, text, "5. ")
+	assert.NotContains(t, text, "6. ")
+	assert.Equal(t, "wl:wpage:1", buttonData(t, calls[0].Params.Get("reply_markup"), "▶️"))
+
+	calls = c.press(user, calls[0].MessageID, "wl:wpage:2")
+	edit, ok := findCall(calls, "editMessageText")
+	if assert.True(t, ok) {
+		assert.Contains(t, edit.Params.Get("text"), "страница 3 из 3")
+		assert.Contains(t, edit.Params.Get("text"), "12. ")
+		assert.Equal(t, "wl:wpage:1", buttonData(t, edit.Params.Get("reply_markup"), "◀️"))
+	}
+}
@@ -0,0 +1,207 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/diary"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/watchlist"
+)
+
+// Версия формата JSON-экспорта
+const exportVersion = 1
+
+// Формат даты в CSV
+const dateLayout = "2006-01-02"
+
+// JSON-экспорт: список просмотра и дневник пользователя
+type Export struct {
+	Version    int             `json:"version"`
+	ExportedAt time.Time       `json:"exportedAt"`
+	Watchlist  []WatchlistItem `json:"watchlist"`
+	Diary      []DiaryItem     `json:"diary"`
+}
+
+// Запись списка просмотра в экспорте
+type WatchlistItem struct {
+	KinopoiskID   uint32    `json:"kinopoiskId"`
+	Title         string    `json:"title"`
+	OriginalTitle string    `json:"originalTitle,omitempty"`
+	Year          uint16    `json:"year,omitempty"`
+	AddedAt       time.Time `json:"addedAt"`
+	Watched       bool      `json:"watched"`
+	WatchedAt     time.Time `json:"watchedAt,omitempty"`
+}
+
+// Запись дневника в экспорте
+type DiaryItem struct {
+	KinopoiskID   uint32    `json:"kinopoiskId"`
+	Title         string    `json:"title"`
+	OriginalTitle string    `json:"originalTitle,omitempty"`
+	Year          uint16    `json:"year,omitempty"`
+	Date          time.Time `json:"date"`
+	Score         int       `json:"score"`
+	Note          string    `json:"note,omitempty"`
+}
+
+// Сбор данных пользователя для экспорта: личный список (в личке ID чата совпадает с ID пользователя) и дневник
+func BuildExport(userID int64, now time.Time) Export {
+	export := Export{Version: exportVersion, ExportedAt: now, Watchlist: []WatchlistItem{}, Diary: []DiaryItem{}}
+	for _, entry := range watchlist.Load(userID).Entries {
+		export.Watchlist = append(export.Watchlist, WatchlistItem{
+			KinopoiskID:   entry.Movie.ID,
+			Title:         entry.Movie.Name,
+			OriginalTitle: entry.Movie.AlternativeName,
+			Year:          entry.Movie.Year,
+			AddedAt:       entry.AddedAt,
+			Watched:       entry.Watched,
+			WatchedAt:     entry.WatchedAt,
+		})
+	}
+	for _, entry := range diary.Load(userID).Timeline() {
+		export.Diary = append(export.Diary, DiaryItem{
+			KinopoiskID:   entry.Movie.ID,
+			Title:         entry.Movie.Name,
+			OriginalTitle: entry.Movie.AlternativeName,
+			Year:          entry.Movie.Year,
+			Date:          entry.Date,
+			Score:         entry.Score,
+			Note:          entry.Note,
+		})
+	}
+	return export
+}
+
+// Экспорт в JSON
+func (e Export) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при сериализации экспорта: %v", err)
+	}
+	return data, nil
+}
+
+// Список просмотра в CSV
+func (e Export) WatchlistCSV() ([]byte, error) {
+	records := [][]string{{"kinopoisk_id", "title", "original_title", "year", "added_at", "watched", "watched_at"}}
+	for _, item := range e.Watchlist {
+		watchedAt := ""
+		if item.Watched && !item.WatchedAt.IsZero() {
+			watchedAt = item.WatchedAt.Format(dateLayout)
+		}
+		records = append(records, []string{
+			strconv.FormatUint(uint64(item.KinopoiskID), 10), item.Title, item.OriginalTitle, formatYear(item.Year),
+			item.AddedAt.Format(dateLayout), strconv.FormatBool(item.Watched), watchedAt,
+		})
+	}
+	return writeCSV(records)
+}
+
+// Дневник в CSV
+func (e Export) DiaryCSV() ([]byte, error) {
+	records := [][]string{{"kinopoisk_id", "title", "original_title", "year", "date", "rating", "note"}}
+	for _, item := range e.Diary {
+		records = append(records, []string{
+			strconv.FormatUint(uint64(item.KinopoiskID), 10), item.Title, item.OriginalTitle, formatYear(item.Year),
+			item.Date.Format(dateLayout), strconv.Itoa(item.Score), item.Note,
+		})
+	}
+	return writeCSV(records)
+}
+
+// CSV в формате импорта Letterboxd: оценённые фильмы и просмотренные из списка.
+// Letterboxd сопоставляет фильмы по названию и году, поэтому берётся оригинальное название.
+func (e Export) LetterboxdCSV() ([]byte, error) {
+	records := [][]string{{"Title", "Year", "Rating10", "WatchedDate", "Review"}}
+	rated := make(map[uint32]bool)
+	for _, item := range e.Diary {
+		rated[item.KinopoiskID] = true
+		records = append(records, []string{
+			originalTitle(item.Title, item.OriginalTitle), formatYear(item.Year), strconv.Itoa(item.Score),
+			item.Date.Format(dateLayout), item.Note,
+		})
+	}
+	for _, item := range e.Watchlist {
+		if !item.Watched || rated[item.KinopoiskID] {
+			continue
+		}
+		watchedAt := ""
+		if !item.WatchedAt.IsZero() {
+			watchedAt = item.WatchedAt.Format(dateLayout)
+		}
+		records = append(records, []string{originalTitle(item.Title, item.OriginalTitle), formatYear(item.Year), "", watchedAt, ""})
+	}
+	return writeCSV(records)
+}
+
+func originalTitle(title, original string) string {
+	if original != "" {
+		return original
+	}
+	return title
+}
+
+func formatYear(year uint16) string {
+	if year == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(year))
+}
+
+func writeCSV(records [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.WriteAll(records); err != nil {
+		return nil, fmt.Errorf("ошибка при записи CSV: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Обработка команды /export: файлы CSV, JSON и CSV для Letterboxd
+func HandleExportCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	if !message.Chat.IsPrivate() {
+		sendMessage(bot, chatID, "Экспорт и импорт доступны в личке с ботом.")
+		return
+	}
+
+	export := BuildExport(message.From.ID, time.Now())
+	if len(export.Watchlist) == 0 && len(export.Diary) == 0 {
+		sendMessage(bot, chatID, "Экспортировать пока нечего: список /list и дневник /diary пусты.")
+		return
+	}
+
+	files := []struct {
+		name  string
+		build func() ([]byte, error)
+	}{
+		{"kinobot_watchlist.csv", export.WatchlistCSV},
+		{"kinobot_diary.csv", export.DiaryCSV},
+		{"kinobot.json", export.JSON},
+		{"letterboxd.csv", export.LetterboxdCSV},
+	}
+	sendMessage(bot, chatID, fmt.Sprintf("📤 Экспорт: в списке %d, в дневнике %d.\n"+
+		"letterboxd.csv можно загрузить на letterboxd.com/import, а любой из файлов - вернуть в бота через /import.",
+		len(export.Watchlist), len(export.Diary)))
+	for _, file := range files {
+		data, err := file.build()
+		if err != nil {
+			log.Println("Ошибка при подготовке экспорта:", err)
+			continue
+		}
+		document := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: file.name, Bytes: data})
+		sender.Post(bot, document)
+	}
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}
@@ -0,0 +1,482 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/dialog"
+	"github.com/luzhnov-aleksei/kinobot/diary"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+	"github.com/luzhnov-aleksei/kinobot/watchlist"
+)
+
+// Раздел хранилища с импортами, ожидающими уточнения, ключ - ID пользователя
+const pendingBucket = "imports"
+
+// Префикс callback-данных кнопок уточнения импорта
+const CallbackPrefix = "im:"
+
+const (
+	// Размер файла импорта
+	maxFileSize = 1 << 20
+	// Вариантов фильма в вопросе об уточнении
+	maxCandidates = 5
+	// Фильмов с ID Кинопоиска в одном запросе к API
+	batchSize = 10
+)
+
+var (
+	// Строк, импортируемых за раз: поиск по названию тратит запрос к API на строку
+	MaxRows = 50
+	// Сколько запросов к API за сутки может израсходовать импорт вместе с остальными,
+	// остаток лимита остаётся поиску пользователей
+	APIReserveThreshold = 150
+)
+
+// Адрес скачивания файлов Telegram, в тестах подменяется адресом локального сервера
+var FileURL = tgbotapi.FileEndpoint
+
+// HTTP-клиент для скачивания файлов: обработка обновлений ждёт скачивания, поэтому с таймаутом
+var Client = &http.Client{Timeout: 20 * time.Second}
+
+// Строка, для которой поиск нашёл несколько подходящих фильмов
+type Question struct {
+	Row        Row          `json:"row"`
+	Candidates []api.Cinema `json:"candidates"`
+}
+
+// Импорт, ожидающий ответов пользователя на вопросы
+type Pending struct {
+	UserID    int64      `json:"userId"`
+	ChatID    int64      `json:"chatId"`
+	FirstName string     `json:"firstName"`
+	Questions []Question `json:"questions"`
+	// Сообщение с текущим вопросом
+	MessageID int `json:"messageId"`
+}
+
+// Итоги импорта
+type Result struct {
+	Diary     int
+	Watchlist int
+	Existing  int
+	NotFound  []string
+	Questions []Question
+}
+
+func init() {
+	dialog.Register(&dialog.Dialog{
+		Name:       "import",
+		Start:      "file",
+		States:     map[string]dialog.Step{"file": handleFile},
+		CancelText: "Импорт отменён.",
+	})
+}
+
+// Обработка команды /import: бот ждёт файл следующим сообщением
+func HandleImportCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	if !message.Chat.IsPrivate() {
+		sendMessage(bot, chatID, "Экспорт и импорт доступны в личке с ботом.")
+		return
+	}
+	// Второй импорт заменил бы вопросы первого, поэтому сначала нужно закончить его
+	if pending, ok := LoadPending(message.From.ID); ok && len(pending.Questions) > 0 {
+		sendMessage(bot, chatID, "Сначала закончите предыдущий импорт: ответьте на вопрос ниже или нажмите «Пропустить все».")
+		ask(bot, pending)
+		return
+	}
+	if err := dialog.Begin(chatID, message.From.ID, "import", nil); err != nil {
+		log.Println(err)
+		return
+	}
+	sendMessage(bot, chatID, fmt.Sprintf("📥 Отправьте файл CSV или JSON: экспорт бота (/export), Letterboxd (ratings.csv, diary.csv, watchlist.csv) "+
+		"или IMDb (ratings.csv, watchlist.csv). За раз импортируется до %d записей.\n/cancel - отменить", MaxRows))
+}
+
+// Шаг диалога /import: файл с записями
+func handleFile(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *dialog.Session) string {
+	chatID := message.Chat.ID
+	document := message.Document
+	if document == nil {
+		sendMessage(bot, chatID, "Пришлите файл CSV или JSON документом или отправьте /cancel.")
+		return session.State
+	}
+	if document.FileSize > maxFileSize {
+		sendMessage(bot, chatID, "Файл слишком большой, максимум 1 МБ.")
+		return session.State
+	}
+
+	data, err := download(bot, document.FileID)
+	if err != nil {
+		log.Println(err)
+		sendMessage(bot, chatID, "Не удалось скачать файл, попробуйте ещё раз.")
+		return session.State
+	}
+	format, rows, err := Parse(document.FileName, data)
+	if err != nil {
+		sendMessage(bot, chatID, fmt.Sprintf("Не получилось прочитать файл: %s. Попробуйте другой файл или отправьте /cancel.", err))
+		return session.State
+	}
+
+	budget := APIReserveThreshold - api.RequestsToday()
+	if budget <= 0 {
+		sendMessage(bot, chatID, "Лимит запросов к Кинопоиску на сегодня почти исчерпан, попробуйте импортировать файл завтра.")
+		return dialog.End
+	}
+	skipped := 0
+	if len(rows) > MaxRows {
+		skipped = len(rows) - MaxRows
+		rows = rows[:MaxRows]
+	}
+	if n := withinBudget(rows, budget); n < len(rows) {
+		skipped += len(rows) - n
+		rows = rows[:n]
+	}
+	result := Import(message.From, rows)
+	sendMessage(bot, chatID, formatResult(document.FileName, format, result, skipped))
+
+	if len(result.Questions) > 0 {
+		ask(bot, &Pending{UserID: message.From.ID, ChatID: chatID, FirstName: message.From.FirstName, Questions: result.Questions})
+	}
+	return dialog.End
+}
+
+// Незавершённый импорт пользователя
+func LoadPending(userID int64) (*Pending, bool) {
+	pending := &Pending{}
+	ok := storage.Get(pendingBucket, strconv.FormatInt(userID, 10), pending)
+	return pending, ok
+}
+
+// Удаление незавершённого импорта пользователя
+func Forget(userID int64) error {
+	return storage.Delete(pendingBucket, strconv.FormatInt(userID, 10))
+}
+
+// Скачивание файла, присланного боту
+func download(bot *tgbotapi.BotAPI, fileID string) ([]byte, error) {
+	file, err := bot.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении файла: %v", err)
+	}
+	res, err := Client.Get(fmt.Sprintf(FileURL, bot.Token, file.FilePath))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при скачивании файла: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ошибка при скачивании файла: статус %d", res.StatusCode)
+	}
+	// Размер из сообщения мог быть не указан, поэтому ограничиваем и само чтение
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении файла: %v", err)
+	}
+	if len(data) > maxFileSize {
+		return nil, fmt.Errorf("файл больше %d МБ", maxFileSize>>20)
+	}
+	return data, nil
+}
+
+// Сколько первых строк можно импортировать, не потратив больше budget запросов к API:
+// строки с ID запрашиваются пачками, остальные - по одной
+func withinBudget(rows []Row, budget int) int {
+	kinopoisk, imdb, titles := 0, 0, 0
+	for i, row := range rows {
+		switch {
+		case row.KinopoiskID != 0:
+			kinopoisk++
+		case row.IMDbID != "":
+			imdb++
+		default:
+			titles++
+		}
+		cost := (kinopoisk+batchSize-1)/batchSize + (imdb+batchSize-1)/batchSize + titles
+		if cost > budget {
+			return i
+		}
+	}
+	return len(rows)
+}
+
+// Сопоставление строк с фильмами Кинопоиска и добавление найденных в дневник и список.
+// Строки с несколькими подходящими фильмами возвращаются вопросами.
+func Import(user *tgbotapi.User, rows []Row) Result {
+	var result Result
+
+	// Строки с ID Кинопоиска или IMDb запрашиваются пачками, остальные ищутся по названию
+	byID := make(map[uint32]api.Cinema)
+	byIMDb := make(map[string]api.Cinema)
+	var ids []uint32
+	var imdbIDs []string
+	for _, row := range rows {
+		switch {
+		case row.KinopoiskID != 0:
+			ids = append(ids, row.KinopoiskID)
+		case row.IMDbID != "":
+			imdbIDs = append(imdbIDs, row.IMDbID)
+		}
+	}
+	for start := 0; start < len(ids); start += batchSize {
+		found, err := api.RequestMoviesByIDs(api.BaseURL+"/movie", ids[start:min(start+batchSize, len(ids))])
+		if err != nil {
+			log.Println("Ошибка при запросе фильмов для импорта:", err)
+			continue
+		}
+		for _, movie := range found {
+			byID[movie.ID] = movie
+		}
+	}
+	for start := 0; start < len(imdbIDs); start += batchSize {
+		found, err := api.RequestMoviesByIMDbIDs(api.BaseURL+"/movie", imdbIDs[start:min(start+batchSize, len(imdbIDs))])
+		if err != nil {
+			log.Println("Ошибка при запросе фильмов IMDb для импорта:", err)
+			continue
+		}
+		for _, movie := range found {
+			byIMDb[movie.ExternalID.Imdb] = movie
+		}
+	}
+
+	for _, row := range rows {
+		var movie *api.Cinema
+		found, foundByIMDb := byIMDb[row.IMDbID]
+		switch {
+		case row.KinopoiskID != 0:
+			if found, ok := byID[row.KinopoiskID]; ok {
+				movie = &found
+			}
+		case row.IMDbID != "" && foundByIMDb:
+			movie = &found
+		case row.IMDbID != "" && api.RequestsToday() >= APIReserveThreshold:
+			// Запасной поиск по названию не входил в расчёт бюджета и откладывается до другого раза
+		default:
+			// Фильм IMDb, которого нет в базе Кинопоиска под этим ID, ищется по названию
+			var candidates []api.Cinema
+			var err error
+			movie, candidates, err = match(row)
+			if err != nil {
+				log.Println("Ошибка при поиске фильма для импорта:", err)
+			}
+			if len(candidates) > 0 {
+				result.Questions = append(result.Questions, Question{Row: row, Candidates: candidates})
+				continue
+			}
+		}
+
+		if movie == nil {
+			result.NotFound = append(result.NotFound, rowTitle(row))
+			continue
+		}
+		toDiary, added, err := add(user, row, movie)
+		switch {
+		case err != nil:
+			log.Println("Ошибка при импорте записи:", err)
+			result.NotFound = append(result.NotFound, rowTitle(row))
+		case !added:
+			result.Existing++
+		case toDiary:
+			result.Diary++
+		default:
+			result.Watchlist++
+		}
+	}
+	return result
+}
+
+// Поиск фильма по названию и году. Если подходит несколько фильмов, возвращаются варианты для уточнения.
+func match(row Row) (*api.Cinema, []api.Cinema, error) {
+	found, err := api.RequestMovies(api.BaseURL+"/movie/search", row.Title)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(found) == 0 {
+		return nil, nil, nil
+	}
+
+	// Год в разных базах может отличаться на единицу. Если год не совпал ни у одного фильма,
+	// выбирать из найденных должен пользователь.
+	candidates := found
+	yearMatched := true
+	if row.Year != 0 {
+		candidates = filterYear(found, row.Year, 0)
+		if len(candidates) == 0 {
+			candidates = filterYear(found, row.Year, 1)
+		}
+		if len(candidates) == 0 {
+			candidates, yearMatched = found, false
+		}
+	}
+	if len(candidates) == 1 && yearMatched {
+		return &candidates[0], nil, nil
+	}
+
+	var exact []api.Cinema
+	for _, movie := range candidates {
+		if strings.EqualFold(movie.Name, row.Title) || strings.EqualFold(movie.AlternativeName, row.Title) {
+			exact = append(exact, movie)
+		}
+	}
+	if len(exact) == 1 && yearMatched {
+		return &exact[0], nil, nil
+	}
+	if len(exact) > 1 {
+		candidates = exact
+	}
+	if len(candidates) > maxCandidates {
+		candidates = candidates[:maxCandidates]
+	}
+	return nil, candidates, nil
+}
+
+// Фильмы, год которых отличается от заданного не больше чем на delta
+func filterYear(movies []api.Cinema, year uint16, delta int) []api.Cinema {
+	var result []api.Cinema
+	for _, movie := range movies {
+		diff := int(movie.Year) - int(year)
+		if diff >= -delta && diff <= delta {
+			result = append(result, movie)
+		}
+	}
+	return result
+}
+
+// Добавление фильма строки в дневник (есть оценка) или в личный список
+func add(user *tgbotapi.User, row Row, movie *api.Cinema) (toDiary bool, added bool, err error) {
+	date := row.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	if row.Score >= 1 && row.Score <= 10 {
+		n, err := diary.Import(user.ID, []diary.Entry{{Movie: *movie, Score: row.Score, Date: date, Note: row.Note}})
+		return true, n > 0, err
+	}
+
+	entry := watchlist.Entry{Movie: *movie, AddedBy: user.ID, AddedByName: user.FirstName, AddedAt: date, Watched: row.Watched}
+	if row.Watched {
+		entry.WatchedAt = date
+	}
+	n, err := watchlist.Import(user.ID, []watchlist.Entry{entry})
+	return false, n > 0, err
+}
+
+func rowTitle(row Row) string {
+	if row.Year != 0 {
+		return fmt.Sprintf("%s (%d)", row.Title, row.Year)
+	}
+	return row.Title
+}
+
+func formatResult(fileName string, format string, result Result, skipped int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📥 Импорт «%s» (%s):\n", fileName, format))
+	sb.WriteString(fmt.Sprintf("• в дневник: %d\n", result.Diary))
+	sb.WriteString(fmt.Sprintf("• в список: %d\n", result.Watchlist))
+	if result.Existing > 0 {
+		sb.WriteString(fmt.Sprintf("• уже были: %d\n", result.Existing))
+	}
+	if len(result.NotFound) > 0 {
+		sb.WriteString(fmt.Sprintf("• не найдено: %d - %s\n", len(result.NotFound), strings.Join(result.NotFound, ", ")))
+	}
+	if skipped > 0 {
+		sb.WriteString(fmt.Sprintf("• не вошло в лимит: %d, отправьте их отдельным файлом\n", skipped))
+	}
+	if len(result.Questions) > 0 {
+		sb.WriteString(fmt.Sprintf("\nНужно уточнить фильмов: %d.", len(result.Questions)))
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// Вопрос о первой неуточнённой строке импорта, импорт сохраняется до ответа
+func ask(bot *tgbotapi.BotAPI, pending *Pending) {
+	question := pending.Questions[0]
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, movie := range question.Candidates {
+		label := movie.Name
+		if movie.Year != 0 {
+			label = fmt.Sprintf("%s (%d)", movie.Name, movie.Year)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, CallbackPrefix+"pick:"+strconv.Itoa(i)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Пропустить", CallbackPrefix+"skip"),
+		tgbotapi.NewInlineKeyboardButtonData("Пропустить все", CallbackPrefix+"stop"),
+	))
+
+	msg := tgbotapi.NewMessage(pending.ChatID, fmt.Sprintf("❓ «%s»: какой это фильм?", rowTitle(question.Row)))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	sent, err := sender.Send(bot, msg)
+	if err != nil {
+		log.Println("Ошибка при отправке вопроса импорта:", err)
+		return
+	}
+	pending.MessageID = sent.MessageID
+	if err := storage.Put(pendingBucket, strconv.FormatInt(pending.UserID, 10), pending); err != nil {
+		log.Println("Ошибка при сохранении импорта:", err)
+	}
+}
+
+// Обработка кнопок уточнения: im:pick:<вариант>, im:skip, im:stop
+func HandleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	key := strconv.FormatInt(callback.From.ID, 10)
+	pending := &Pending{}
+	if !storage.Get(pendingBucket, key, pending) || len(pending.Questions) == 0 || pending.MessageID != callback.Message.MessageID {
+		editQuestion(bot, callback.Message, callback.Message.Text+"\n\nНа этот вопрос уже ответили.")
+		return
+	}
+
+	question := pending.Questions[0]
+	pending.Questions = pending.Questions[1:]
+	action := strings.TrimPrefix(callback.Data, CallbackPrefix)
+	status := "пропущено"
+	switch {
+	case action == "stop":
+		status = fmt.Sprintf("пропущено, как и остальные %d", len(pending.Questions))
+		pending.Questions = nil
+	case strings.HasPrefix(action, "pick:"):
+		i, err := strconv.Atoi(strings.TrimPrefix(action, "pick:"))
+		if err != nil || i < 0 || i >= len(question.Candidates) {
+			return
+		}
+		movie := question.Candidates[i]
+		toDiary, added, err := add(callback.From, question.Row, &movie)
+		switch {
+		case err != nil:
+			log.Println("Ошибка при импорте записи:", err)
+			status = "не удалось сохранить"
+		case !added:
+			status = fmt.Sprintf("«%s» уже был", movie.Name)
+		case toDiary:
+			status = fmt.Sprintf("«%s» добавлен в дневник", movie.Name)
+		default:
+			status = fmt.Sprintf("«%s» добавлен в список", movie.Name)
+		}
+	}
+	editQuestion(bot, callback.Message, fmt.Sprintf("«%s»: %s", rowTitle(question.Row), status))
+
+	if len(pending.Questions) == 0 {
+		if err := storage.Delete(pendingBucket, key); err != nil {
+			log.Println("Ошибка при удалении импорта:", err)
+		}
+		sendMessage(bot, pending.ChatID, "✅ Импорт завершён. Список: /list, дневник: /diary")
+		return
+	}
+	ask(bot, pending)
+}
+
+func editQuestion(bot *tgbotapi.BotAPI, message *tgbotapi.Message, text string) {
+	edit := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, text)
+	sender.Post(bot, edit)
+}
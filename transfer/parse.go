@@ -0,0 +1,197 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Форматы импортируемых файлов
+const (
+	FormatKinobot    = "Kinobot"
+	FormatLetterboxd = "Letterboxd"
+	FormatIMDb       = "IMDb"
+)
+
+// Строка импорта. Фильм задан ID Кинопоиска или названием с годом.
+// Строки с оценкой попадают в дневник, остальные - в список просмотра.
+type Row struct {
+	KinopoiskID uint32    `json:"kinopoiskId,omitempty"`
+	IMDbID      string    `json:"imdbId,omitempty"`
+	Title       string    `json:"title"`
+	Year        uint16    `json:"year,omitempty"`
+	Score       int       `json:"score,omitempty"`
+	Date        time.Time `json:"date,omitempty"`
+	Note        string    `json:"note,omitempty"`
+	Watched     bool      `json:"watched,omitempty"`
+}
+
+// Разбор файла импорта: JSON-экспорт бота или CSV бота, Letterboxd, IMDb.
+// Формат CSV определяется по заголовку, списки просмотра Letterboxd - по имени файла.
+func Parse(fileName string, data []byte) (string, []Row, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if strings.HasSuffix(strings.ToLower(fileName), ".json") || bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		rows, err := parseJSON(data)
+		return FormatKinobot, rows, err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return "", nil, fmt.Errorf("ошибка при чтении CSV: %v", err)
+	}
+	if len(records) < 2 {
+		return "", nil, errors.New("в файле нет записей")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	table := csvTable{columns: columns}
+	watchlistFile := strings.Contains(strings.ToLower(fileName), "watchlist")
+
+	var format string
+	var parseRow func(record []string) Row
+	switch {
+	case table.has("kinopoisk_id"):
+		format = FormatKinobot
+		parseRow = table.kinobotRow
+	case table.has("const") && table.has("title"):
+		format = FormatIMDb
+		parseRow = table.imdbRow
+	case (table.has("name") || table.has("title")) && table.has("year"):
+		format = FormatLetterboxd
+		parseRow = func(record []string) Row { return table.letterboxdRow(record, watchlistFile) }
+	default:
+		return "", nil, errors.New("не удалось определить формат файла: нужен экспорт бота, Letterboxd или IMDb")
+	}
+
+	var rows []Row
+	for _, record := range records[1:] {
+		row := parseRow(record)
+		if row.KinopoiskID == 0 && row.Title == "" {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return format, rows, nil
+}
+
+func parseJSON(data []byte) ([]Row, error) {
+	var export Export
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("ошибка при разборе JSON: %v", err)
+	}
+	if export.Version == 0 {
+		return nil, errors.New("JSON не похож на экспорт бота: нет поля version")
+	}
+
+	var rows []Row
+	for _, item := range export.Watchlist {
+		rows = append(rows, Row{KinopoiskID: item.KinopoiskID, Title: item.Title, Year: item.Year,
+			Date: item.AddedAt, Watched: item.Watched})
+	}
+	for _, item := range export.Diary {
+		rows = append(rows, Row{KinopoiskID: item.KinopoiskID, Title: item.Title, Year: item.Year,
+			Score: item.Score, Date: item.Date, Note: item.Note, Watched: true})
+	}
+	return rows, nil
+}
+
+// Таблица CSV с колонками по именам в нижнем регистре
+type csvTable struct {
+	columns map[string]int
+}
+
+func (t csvTable) has(column string) bool {
+	_, ok := t.columns[column]
+	return ok
+}
+
+// Значение первой из колонок, которая есть в таблице
+func (t csvTable) get(record []string, columns ...string) string {
+	for _, column := range columns {
+		if i, ok := t.columns[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+	}
+	return ""
+}
+
+// Строка CSV-экспорта бота: дневник (есть колонка rating) или список просмотра
+func (t csvTable) kinobotRow(record []string) Row {
+	id, _ := strconv.ParseUint(t.get(record, "kinopoisk_id"), 10, 32)
+	row := Row{KinopoiskID: uint32(id), Title: t.get(record, "title"), Year: parseYear(t.get(record, "year"))}
+	if t.has("rating") {
+		row.Score, _ = strconv.Atoi(t.get(record, "rating"))
+		row.Date = parseDate(t.get(record, "date"))
+		row.Note = t.get(record, "note")
+		row.Watched = true
+		return row
+	}
+	row.Watched, _ = strconv.ParseBool(t.get(record, "watched"))
+	row.Date = parseDate(t.get(record, "added_at"))
+	return row
+}
+
+// Строка экспорта IMDb: оценки (Your Rating) или список просмотра
+func (t csvTable) imdbRow(record []string) Row {
+	row := Row{Title: t.get(record, "title", "original title"), Year: parseYear(t.get(record, "year"))}
+	if id := t.get(record, "const"); strings.HasPrefix(id, "tt") {
+		row.IMDbID = id
+	}
+	score, _ := strconv.Atoi(t.get(record, "your rating"))
+	if score > 0 {
+		row.Score = score
+		row.Watched = true
+		row.Date = parseDate(t.get(record, "date rated"))
+		return row
+	}
+	row.Date = parseDate(t.get(record, "created"))
+	return row
+}
+
+// Строка Letterboxd: оценки в звёздах (Rating, 0.5-5) или по десятибалльной шкале (Rating10)
+func (t csvTable) letterboxdRow(record []string, watchlistFile bool) Row {
+	row := Row{Title: t.get(record, "name", "title"), Year: parseYear(t.get(record, "year")), Note: t.get(record, "review")}
+	row.Date = parseDate(t.get(record, "watched date", "watcheddate", "date"))
+	if watchlistFile {
+		return row
+	}
+
+	row.Watched = true
+	if score, err := strconv.Atoi(t.get(record, "rating10")); err == nil {
+		row.Score = score
+	} else if stars, err := strconv.ParseFloat(t.get(record, "rating"), 64); err == nil {
+		row.Score = int(math.Round(stars * 2))
+	}
+	if row.Score < 0 || row.Score > 10 {
+		row.Score = 0
+	}
+	return row
+}
+
+func parseYear(value string) uint16 {
+	year, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return 0
+	}
+	return uint16(year)
+}
+
+func parseDate(value string) time.Time {
+	for _, layout := range []string{dateLayout, time.RFC3339} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date
+		}
+	}
+	return time.Time{}
+}
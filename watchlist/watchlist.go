@@ -0,0 +1,505 @@
+package watchlist
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/luzhnov-aleksei/kinobot/api"
+	"github.com/luzhnov-aleksei/kinobot/movies"
+	"github.com/luzhnov-aleksei/kinobot/render"
+	"github.com/luzhnov-aleksei/kinobot/sender"
+	"github.com/luzhnov-aleksei/kinobot/storage"
+	"github.com/luzhnov-aleksei/kinobot/streaming"
+)
+
+// Раздел хранилища со списками, ключ - ID чата.
+// В личке это личный список пользователя, в группе - общий список чата.
+const bucket = "watchlist"
+
+// Префикс callback-данных кнопок списка
+const CallbackPrefix = "wl:"
+
+// Записей на одной странице /list
+const pageSize = 5
+
+// Запись списка просмотра
+type Entry struct {
+	Movie       api.Cinema `json:"movie"`
+	AddedBy     int64      `json:"addedBy"`
+	AddedByName string     `json:"addedByName"`
+	AddedAt     time.Time  `json:"addedAt"`
+	Watched     bool       `json:"watched"`
+	WatchedAt   time.Time  `json:"watchedAt,omitempty"`
+	// Участники, проголосовавшие за то, чтобы посмотреть фильм раньше
+	Votes []int64 `json:"votes,omitempty"`
+	// Прогресс сериала: последняя просмотренная серия
+	Season  int `json:"season,omitempty"`
+	Episode int `json:"episode,omitempty"`
+}
+
+// Прогресс сериала в виде S02E05, пустая строка - серии не отмечались
+func (e *Entry) Progress() string {
+	if e.Season == 0 && e.Episode == 0 {
+		return ""
+	}
+	return fmt.Sprintf("S%02dE%02d", e.Season, e.Episode)
+}
+
+// Список просмотра чата
+type List struct {
+	ChatID  int64   `json:"chatId"`
+	Entries []Entry `json:"entries"`
+}
+
+// Списки читают и фоновые задачи, поэтому изменения идут под блокировкой
+var mu sync.Mutex
+
+func init() {
+	movies.AddCardRow(cardRow)
+}
+
+// Список чата (пустой, если его ещё нет)
+func Load(chatID int64) *List {
+	list := &List{ChatID: chatID}
+	storage.Get(bucket, strconv.FormatInt(chatID, 10), list)
+	return list
+}
+
+// ID всех чатов, у которых есть список
+func Chats() []int64 {
+	var chats []int64
+	for _, key := range storage.Keys(bucket) {
+		if chatID, err := strconv.ParseInt(key, 10, 64); err == nil {
+			chats = append(chats, chatID)
+		}
+	}
+	return chats
+}
+
+func save(list *List) error {
+	return storage.Put(bucket, strconv.FormatInt(list.ChatID, 10), list)
+}
+
+// Поиск записи по ID фильма
+func (l *List) Find(movieID uint32) *Entry {
+	for i := range l.Entries {
+		if l.Entries[i].Movie.ID == movieID {
+			return &l.Entries[i]
+		}
+	}
+	return nil
+}
+
+// Добавление записей (импорт), фильмы, которые уже есть в списке, пропускаются.
+// Возвращает число добавленных записей.
+func Import(chatID int64, entries []Entry) (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	list := Load(chatID)
+	added := 0
+	for _, entry := range entries {
+		if list.Find(entry.Movie.ID) != nil {
+			continue
+		}
+		list.Entries = append(list.Entries, entry)
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	if err := save(list); err != nil {
+		return 0, fmt.Errorf("ошибка при сохранении списка просмотра: %v", err)
+	}
+	return added, nil
+}
+
+// Отметка просмотра сериала до серии episode сезона season. Прогресс хранится только
+// у сериалов из списка: для остальных возвращается nil без ошибки.
+func SetProgress(chatID int64, movieID uint32, season, episode int) (*Entry, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	list := Load(chatID)
+	entry := list.Find(movieID)
+	if entry == nil {
+		return nil, nil
+	}
+	entry.Season, entry.Episode = season, episode
+	if err := save(list); err != nil {
+		return nil, fmt.Errorf("ошибка при сохранении прогресса сериала: %v", err)
+	}
+	return entry, nil
+}
+
+// Обновление онлайн-кинотеатров фильма в списке чата. Нужно, чтобы /list отмечал
+// фильмы, которые появились в подписках, после перепроверки фильма.
+func SetWatchability(chatID int64, movieID uint32, watchability api.Watchability) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	list := Load(chatID)
+	entry := list.Find(movieID)
+	if entry == nil {
+		return nil
+	}
+	entry.Movie.Watchability = watchability
+	if err := save(list); err != nil {
+		return fmt.Errorf("ошибка при сохранении онлайн-кинотеатров фильма: %v", err)
+	}
+	return nil
+}
+
+// Удаление личного списка пользователя. В списках групп записи остаются,
+// но имя добавившего и голоса пользователя удаляются.
+func Forget(userID int64) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, chatID := range Chats() {
+		if chatID == userID {
+			if err := storage.Delete(bucket, strconv.FormatInt(chatID, 10)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		list := Load(chatID)
+		changed := false
+		for i := range list.Entries {
+			entry := &list.Entries[i]
+			if entry.AddedBy == userID {
+				entry.AddedBy, entry.AddedByName = 0, ""
+				changed = true
+			}
+			for _, voter := range entry.Votes {
+				if voter == userID {
+					entry.Votes = toggleVote(entry.Votes, userID)
+					changed = true
+					break
+				}
+			}
+		}
+		if changed {
+			if err := save(list); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Непросмотренные записи: сначала с большим числом голосов, затем добавленные раньше
+func (l *List) Unwatched() []Entry {
+	var entries []Entry
+	for _, entry := range l.Entries {
+		if !entry.Watched {
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if len(entries[i].Votes) != len(entries[j].Votes) {
+			return len(entries[i].Votes) > len(entries[j].Votes)
+		}
+		return entries[i].AddedAt.Before(entries[j].AddedAt)
+	})
+	return entries
+}
+
+// Просмотренные записи, последние просмотренные первыми
+func (l *List) Watched() []Entry {
+	var entries []Entry
+	for _, entry := range l.Entries {
+		if entry.Watched {
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].WatchedAt.After(entries[j].WatchedAt)
+	})
+	return entries
+}
+
+// Кнопка списка под карточкой фильма
+func cardRow(chatID int64, movie *api.Cinema) []tgbotapi.InlineKeyboardButton {
+	entry := Load(chatID).Find(movie.ID)
+	id := strconv.FormatUint(uint64(movie.ID), 10)
+	switch {
+	case entry == nil:
+		return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ В список", CallbackPrefix+"add:"+id))
+	case !entry.Watched:
+		return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("✅ Посмотрели", CallbackPrefix+"seen:"+id))
+	default:
+		return nil
+	}
+}
+
+// Обработка команды /list [watched]
+func HandleListCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	list := Load(message.Chat.ID)
+	format := formatPage
+	if message.CommandArguments() == "watched" {
+		format = formatWatched
+	}
+
+	text, keyboard := format(list, 0)
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "HTML"
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+	sender.Post(bot, msg)
+}
+
+// Обработка кнопок списка: wl:add, wl:seen, wl:vote, wl:card, wl:page, wl:wpage
+func HandleCallback(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery) {
+	parts := strings.Split(strings.TrimPrefix(callback.Data, CallbackPrefix), ":")
+	if len(parts) != 2 {
+		return
+	}
+	action := parts[0]
+	value, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return
+	}
+
+	chatID := callback.Message.Chat.ID
+	switch action {
+	case "add":
+		addMovie(bot, callback, uint32(value))
+	case "seen":
+		updateEntry(bot, callback, uint32(value), func(entry *Entry) string {
+			if entry.Watched {
+				return ""
+			}
+			entry.Watched = true
+			entry.WatchedAt = time.Now()
+			return fmt.Sprintf("✅ %s отмечен просмотренным.", entry.Movie.Name)
+		})
+	case "vote":
+		updateEntry(bot, callback, uint32(value), func(entry *Entry) string {
+			entry.Votes = toggleVote(entry.Votes, callback.From.ID)
+			return ""
+		})
+	case "card":
+		if entry := Load(chatID).Find(uint32(value)); entry != nil {
+			movies.SendMovieCard(bot, chatID, &entry.Movie)
+		}
+	case "page":
+		text, keyboard := formatPage(Load(chatID), int(value))
+		editList(bot, callback.Message, text, keyboard)
+	case "wpage":
+		text, keyboard := formatWatched(Load(chatID), int(value))
+		editList(bot, callback.Message, text, keyboard)
+	}
+}
+
+func addMovie(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery, movieID uint32) {
+	movie, err := movies.GetMovie(movieID)
+	if err != nil {
+		sendMessage(bot, callback.Message.Chat.ID, fmt.Sprintf("Произошла ошибка: %s", err))
+		return
+	}
+
+	mu.Lock()
+	list := Load(callback.Message.Chat.ID)
+	exists := list.Find(movieID) != nil
+	if !exists {
+		list.Entries = append(list.Entries, Entry{
+			Movie:       *movie,
+			AddedBy:     callback.From.ID,
+			AddedByName: displayName(callback.From),
+			AddedAt:     time.Now(),
+		})
+		err = save(list)
+	}
+	mu.Unlock()
+
+	if err != nil {
+		log.Println("Ошибка при сохранении списка просмотра:", err)
+		sendMessage(bot, callback.Message.Chat.ID, "Не удалось сохранить список, попробуйте позже.")
+		return
+	}
+	if !exists {
+		sendMessage(bot, callback.Message.Chat.ID, fmt.Sprintf("➕ %s добавлен в список. Посмотреть список: /list", movie.Name))
+	}
+	refreshCard(bot, callback.Message, movie)
+}
+
+// Изменение записи списка. update возвращает текст уведомления для чата или пустую строку.
+func updateEntry(bot *tgbotapi.BotAPI, callback *tgbotapi.CallbackQuery, movieID uint32, update func(entry *Entry) string) {
+	chatID := callback.Message.Chat.ID
+
+	mu.Lock()
+	list := Load(chatID)
+	entry := list.Find(movieID)
+	if entry == nil {
+		mu.Unlock()
+		sendMessage(bot, chatID, "Этого фильма уже нет в списке.")
+		return
+	}
+	notice := update(entry)
+	movie := entry.Movie
+	err := save(list)
+	mu.Unlock()
+
+	if err != nil {
+		log.Println("Ошибка при сохранении списка просмотра:", err)
+		return
+	}
+	if notice != "" {
+		sendMessage(bot, chatID, notice)
+	}
+
+	// Кнопка нажата либо под карточкой фильма, либо под сообщением /list
+	if callback.Message.Photo == nil && !movies.IsCard(callback.Message) {
+		text, keyboard := formatPage(list, 0)
+		editList(bot, callback.Message, text, keyboard)
+	} else {
+		refreshCard(bot, callback.Message, &movie)
+	}
+}
+
+// Повторное нажатие снимает голос
+func toggleVote(votes []int64, userID int64) []int64 {
+	for i, id := range votes {
+		if id == userID {
+			return append(votes[:i], votes[i+1:]...)
+		}
+	}
+	return append(votes, userID)
+}
+
+// Обновление кнопок под карточкой фильма
+func refreshCard(bot *tgbotapi.BotAPI, message *tgbotapi.Message, movie *api.Cinema) {
+	keyboard := movies.CardKeyboard(message.Chat.ID, movie)
+	if keyboard == nil {
+		keyboard = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	}
+	edit := tgbotapi.NewEditMessageReplyMarkup(message.Chat.ID, message.MessageID, *keyboard)
+	sender.Post(bot, edit)
+}
+
+func editList(bot *tgbotapi.BotAPI, message *tgbotapi.Message, text string, keyboard *tgbotapi.InlineKeyboardMarkup) {
+	edit := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, text)
+	edit.ParseMode = "HTML"
+	edit.ReplyMarkup = keyboard
+	sender.Post(bot, edit)
+}
+
+// Страница непросмотренных фильмов с кнопками голосования и отметки просмотра
+func formatPage(list *List, page int) (string, *tgbotapi.InlineKeyboardMarkup) {
+	unwatched := list.Unwatched()
+	preferred := streaming.Preferred(list.ChatID)
+	if len(unwatched) == 0 {
+		return "📋 Список пуст. Добавьте фильм кнопкой «➕ В список» на его карточке.", nil
+	}
+
+	page, pages, start, end := pageBounds(len(unwatched), page)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📋 Что посмотреть (%d, страница %d из %d):\n\n", len(unwatched), page+1, pages))
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, entry := range unwatched[start:end] {
+		id := strconv.FormatUint(uint64(entry.Movie.ID), 10)
+		sb.WriteString(fmt.Sprintf("%d. %s - добавил(а) %s %s",
+			start+i+1, movies.CompactLine(&entry.Movie), render.Escape(entry.AddedByName), entry.AddedAt.Format("02.01.2006")))
+		if len(entry.Votes) > 0 {
+			sb.WriteString(fmt.Sprintf(", 👍 %d", len(entry.Votes)))
+		}
+		if progress := entry.Progress(); progress != "" {
+			sb.WriteString(", 📺 " + progress)
+		}
+		if available := streaming.Available(entry.Movie.Watchability, preferred); len(available) > 0 {
+			sb.WriteString(", 🍿 " + render.Escape(strings.Join(available, ", ")))
+		}
+		sb.WriteString("\n")
+
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🎬 %d", start+i+1), CallbackPrefix+"card:"+id),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("👍 %d", len(entry.Votes)), CallbackPrefix+"vote:"+id),
+			tgbotapi.NewInlineKeyboardButtonData("✅", CallbackPrefix+"seen:"+id),
+		))
+	}
+	sb.WriteString("\n🎬 - карточка, 👍 - посмотреть раньше, ✅ - уже посмотрели.\n/random - случайный фильм из списка, /list watched - просмотренные.")
+
+	if nav := navRow("page", page, pages); nav != nil {
+		rows = append(rows, nav)
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return sb.String(), &keyboard
+}
+
+// Страница просмотренных фильмов, по pageSize на странице, как и в /list
+func formatWatched(list *List, page int) (string, *tgbotapi.InlineKeyboardMarkup) {
+	watched := list.Watched()
+	if len(watched) == 0 {
+		return "Просмотренных фильмов пока нет.", nil
+	}
+
+	page, pages, start, end := pageBounds(len(watched), page)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("✅ Просмотрено (%d, страница %d из %d):\n\n", len(watched), page+1, pages))
+	for i, entry := range watched[start:end] {
+		sb.WriteString(fmt.Sprintf("%d. %s (%d) - %s, добавил(а) %s\n", start+i+1,
+			render.Escape(entry.Movie.Name), entry.Movie.Year, entry.WatchedAt.Format("02.01.2006"), render.Escape(entry.AddedByName)))
+	}
+
+	nav := navRow("wpage", page, pages)
+	if nav == nil {
+		return sb.String(), nil
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(nav)
+	return sb.String(), &keyboard
+}
+
+// Номер страницы (неверный - первая), число страниц и границы записей на ней
+func pageBounds(total, page int) (int, int, int, int) {
+	pages := (total + pageSize - 1) / pageSize
+	if page < 0 || page >= pages {
+		page = 0
+	}
+	start := page * pageSize
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+	return page, pages, start, end
+}
+
+// Кнопки перехода между страницами, action - действие в callback-данных. Одна страница - без кнопок.
+func navRow(action string, page, pages int) []tgbotapi.InlineKeyboardButton {
+	if pages <= 1 {
+		return nil
+	}
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️", CallbackPrefix+action+":"+strconv.Itoa(page-1)))
+	}
+	if page < pages-1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("▶️", CallbackPrefix+action+":"+strconv.Itoa(page+1)))
+	}
+	return nav
+}
+
+func displayName(user *tgbotapi.User) string {
+	if user.UserName != "" {
+		return "@" + user.UserName
+	}
+	if user.FirstName != "" {
+		return user.FirstName
+	}
+	return strconv.FormatInt(user.ID, 10)
+}
+
+func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	sender.Post(bot, msg)
+}